@@ -0,0 +1,120 @@
+package api
+
+import (
+	"errors"
+	"invite-code-service/dao"
+	"invite-code-service/pkg/utils"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// shortest timeline interval of batch stats, in seconds
+const minBindInterval = 60
+
+type RspAdminBatches struct {
+	List []BatchSummary `json:"list"`
+}
+
+type RspAdminBatchStats struct {
+	Batch    BatchSummary `json:"batch"`
+	Interval uint64       `json:"interval"`
+	Timeline []BindPoint  `json:"timeline"`
+}
+
+// BindPoint counts the codes first redeemed in [start, start+interval), the
+// totals are as of the end of the interval
+type BindPoint struct {
+	Start      uint64  `json:"start"`
+	BoundCodes uint64  `json:"bound_codes"`
+	TotalBound uint64  `json:"total_bound"`
+	BindRate   float64 `json:"bind_rate"` // total_bound / total_codes
+}
+
+// @Summary list batches
+// @Description Every batch with the summary of its codes
+// @Tags admin
+// @Produce json
+// @Security ApiKeyAuth
+// @Success 200 {object} utils.Rsp{data=RspAdminBatches}
+// @Router /admin/v1/batches [get]
+func (h *Handler) GetAdminBatches(c *gin.Context) {
+	batches, err := h.store.GetBatches()
+	if err != nil {
+		utils.Err(c, codeInternalErr, err.Error())
+		logrus.Errorf("GetBatches err %s", err)
+		return
+	}
+
+	rsp := RspAdminBatches{List: make([]BatchSummary, 0, len(batches))}
+	for _, b := range batches {
+		stats, err := h.store.GetBatchStats(b.ID, dao.DefaultBindInterval)
+		if err != nil {
+			utils.Err(c, codeInternalErr, err.Error())
+			logrus.Errorf("GetBatchStats err %s", err)
+			return
+		}
+		rsp.List = append(rsp.List, toBatchSummary(b, stats))
+	}
+	utils.Ok(c, rsp)
+}
+
+// @Summary get batch stats
+// @Description The summary of the codes of a batch and how they got bound over time
+// @Tags admin
+// @Produce json
+// @Security ApiKeyAuth
+// @Param name query string true "batch name"
+// @Param interval query int false "timeline interval in seconds, default 86400, min 60"
+// @Success 200 {object} utils.Rsp{data=RspAdminBatchStats}
+// @Router /admin/v1/batch/stats [get]
+func (h *Handler) GetAdminBatchStats(c *gin.Context) {
+	name := c.Query("name")
+	if len(name) == 0 {
+		utils.Err(c, codeParamErr, "")
+		return
+	}
+	interval := dao.DefaultBindInterval
+	if intervalStr := c.Query("interval"); len(intervalStr) > 0 {
+		i, err := strconv.ParseUint(intervalStr, 10, 64)
+		if err != nil || i < minBindInterval {
+			utils.Err(c, codeParamErr, "invalid interval")
+			return
+		}
+		interval = i
+	}
+
+	batch, err := h.store.GetBatch(name)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			utils.Err(c, codeBatchNotExistErr, "")
+			return
+		}
+		utils.Err(c, codeInternalErr, err.Error())
+		logrus.Errorf("GetBatch err %s", err)
+		return
+	}
+	stats, err := h.store.GetBatchStats(batch.ID, interval)
+	if err != nil {
+		utils.Err(c, codeInternalErr, err.Error())
+		logrus.Errorf("GetBatchStats err %s", err)
+		return
+	}
+
+	rsp := RspAdminBatchStats{
+		Batch:    toBatchSummary(batch, stats),
+		Interval: interval,
+		Timeline: make([]BindPoint, 0, len(stats.Timeline)),
+	}
+	for _, p := range stats.Timeline {
+		rsp.Timeline = append(rsp.Timeline, BindPoint{
+			Start:      p.Start,
+			BoundCodes: uint64(p.BoundCodes),
+			TotalBound: uint64(p.TotalBound),
+			BindRate:   bindRate(p.TotalBound, stats.TotalCodes),
+		})
+	}
+	utils.Ok(c, rsp)
+}
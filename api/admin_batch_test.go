@@ -0,0 +1,68 @@
+package api
+
+import (
+	"encoding/json"
+	"invite-code-service/dao"
+	"invite-code-service/pkg/config"
+	"invite-code-service/pkg/utils"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAdminBatchStats(t *testing.T) {
+	store := dao.NewMemStore()
+	cfg := &config.ConfigApi{AdminApiKeys: []config.AdminApiKey{{Name: "ops", Key: "secret"}}}
+	router := InitRouters(store, cfg)
+
+	batch, err := store.GetOrCreateBatch(dao.Batch{Name: "kol-a", Owner: "alice"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	codes, err := store.GenerateInviteCodes(dao.GenerateSpec{CodeType: dao.DirectInviteCode, Count: 4, BatchId: batch.ID}, dao.SystemEventMeta)
+	if err != nil {
+		t.Fatal(err)
+	}
+	address := "0xabc"
+	codes[0].UserAddress = &address
+	codes[0].BindTime = 1
+	if err := store.CheckBondAndUpdateInviteCode(codes[0], dao.EventBoundBind, dao.SystemEventMeta, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	get := func(url string, data any) utils.Rsp {
+		req := httptest.NewRequest(http.MethodGet, url, nil)
+		req.Header.Set(headerApiKey, "secret")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		rsp := utils.Rsp{Data: data}
+		if err := json.Unmarshal(w.Body.Bytes(), &rsp); err != nil {
+			t.Fatal(err)
+		}
+		return rsp
+	}
+
+	stats := &RspAdminBatchStats{}
+	rsp := get("/api/admin/v1/batch/stats?name=kol-a&interval=3600", stats)
+	if rsp.Status != "80000" {
+		t.Fatalf("unexpected rsp: %+v", rsp)
+	}
+	if stats.Batch.Owner != "alice" || stats.Batch.TotalCodes != 4 || stats.Batch.BoundCodes != 1 || stats.Batch.BindRate != 0.25 {
+		t.Fatalf("unexpected batch: %+v", stats.Batch)
+	}
+	if stats.Interval != 3600 || len(stats.Timeline) != 1 || stats.Timeline[0].TotalBound != 1 || stats.Timeline[0].BindRate != 0.25 {
+		t.Fatalf("unexpected timeline: %+v", stats)
+	}
+
+	batches := &RspAdminBatches{}
+	if rsp := get("/api/admin/v1/batches", batches); rsp.Status != "80000" || len(batches.List) != 1 {
+		t.Fatalf("unexpected rsp: %+v %+v", rsp, batches)
+	}
+
+	if rsp := get("/api/admin/v1/batch/stats?name=nope", nil); rsp.Status != codeBatchNotExistErr {
+		t.Fatalf("expect %s, got %+v", codeBatchNotExistErr, rsp)
+	}
+	if rsp := get("/api/admin/v1/batch/stats?name=kol-a&interval=1", nil); rsp.Status != codeParamErr {
+		t.Fatalf("expect %s, got %+v", codeParamErr, rsp)
+	}
+}
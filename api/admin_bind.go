@@ -0,0 +1,251 @@
+package api
+
+import (
+	"errors"
+	"invite-code-service/dao"
+	"invite-code-service/pkg/utils"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+type ReqAdminBind struct {
+	InviteCode  string `json:"invite_code"`
+	UserAddress string `json:"user_address"`
+	DiscordId   string `json:"discord_id"`
+	DiscordName string `json:"discord_name"`
+}
+
+type ReqAdminRebind struct {
+	InviteCode  string `json:"invite_code"`
+	UserAddress string `json:"user_address"`
+	DiscordId   string `json:"discord_id"`
+	DiscordName string `json:"discord_name"`
+	Reason      string `json:"reason"`
+}
+
+type RspAdminRebind struct {
+	InviteCode  string       `json:"invite_code"`
+	UserAddress string       `json:"user_address"`
+	Removed     []Redemption `json:"removed"`
+}
+
+func optionalString(s string) *string {
+	if len(s) == 0 {
+		return nil
+	}
+	return &s
+}
+
+// getAdminCode returns the code of an admin request if it is in the campaign
+func (h *Handler) getAdminCode(c *gin.Context, code string, campaign *dao.Campaign) (*dao.InviteCode, bool) {
+	inviteCode, err := h.store.GetInviteCode(code)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			utils.Err(c, codeInviteCodeNotExistErr, "")
+			return nil, false
+		}
+		utils.Err(c, codeInternalErr, err.Error())
+		logrus.Errorf("GetInviteCode err %s", err)
+		return nil, false
+	}
+	// codes of other campaigns don't exist in this one
+	if inviteCode.CampaignId != campaign.ID {
+		utils.Err(c, codeInviteCodeNotExistErr, "")
+		return nil, false
+	}
+	return inviteCode, true
+}
+
+// checkAdminUser fails if the user already redeemed a code of the campaign other than code
+func (h *Handler) checkAdminUser(c *gin.Context, campaignId int64, code, address, discordId string) bool {
+	bound, err := h.store.GetInviteCodeByUserAddress(campaignId, address)
+	if err == nil && bound.InviteCode != code {
+		utils.Err(c, codeUserAlreadyBoundErr, "")
+		return false
+	}
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		utils.Err(c, codeInternalErr, err.Error())
+		logrus.Errorf("GetInviteCodeByUserAddress err %s", err)
+		return false
+	}
+	if len(discordId) == 0 {
+		return true
+	}
+
+	bound, err = h.store.GetInviteCodeByDiscordId(campaignId, discordId)
+	if err == nil && bound.InviteCode != code {
+		utils.Err(c, codeDiscordAlreadyBoundErr, "")
+		return false
+	}
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		utils.Err(c, codeInternalErr, err.Error())
+		logrus.Errorf("GetInviteCodeByDiscordId err %s", err)
+		return false
+	}
+	return true
+}
+
+// @Summary bind invite code to user
+// @Description Binds a direct invite code to a user with the checks of the bind command,
+// @Description without a signature. The discord fields are optional.
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param campaign query string false "campaign name, the default campaign if empty"
+// @Param param body ReqAdminBind true "bind"
+// @Success 200 {object} utils.Rsp{}
+// @Router /admin/v1/invite/bind [post]
+func (h *Handler) HandlePostAdminBind(c *gin.Context) {
+	req := ReqAdminBind{}
+	err := c.Bind(&req)
+	if err != nil {
+		utils.Err(c, codeParamErr, err.Error())
+		logrus.Errorf("bind err %s", err)
+		return
+	}
+	if len(req.InviteCode) == 0 || len(req.UserAddress) == 0 {
+		utils.Err(c, codeParamErr, "")
+		return
+	}
+	req.UserAddress = strings.ToLower(req.UserAddress)
+
+	campaign, ok := h.getCampaign(c)
+	if !ok {
+		return
+	}
+	inviteCode, ok := h.getAdminCode(c, req.InviteCode, campaign)
+	if !ok {
+		return
+	}
+	if inviteCode.BindTime != 0 {
+		utils.Err(c, codeInviteCodeAlreadyBoundErr, "")
+		return
+	}
+	if inviteCode.IsRevoked() {
+		utils.Err(c, codeInviteCodeRevokedErr, "")
+		return
+	}
+	if inviteCode.CodeType != dao.DirectInviteCode {
+		utils.Err(c, codeInviteCodeTypeNotMatchErr, "")
+		return
+	}
+	if !h.checkAdminUser(c, campaign.ID, "", req.UserAddress, req.DiscordId) {
+		return
+	}
+
+	dao.CodeUser{
+		UserAddress: req.UserAddress,
+		DiscordId:   optionalString(req.DiscordId),
+		DiscordName: optionalString(req.DiscordName),
+	}.Set(inviteCode)
+	inviteCode.BindTime = uint64(time.Now().Unix())
+	meta := dao.EventMeta{
+		Actor:       adminActor(c),
+		SourceIp:    c.ClientIP(),
+		PayloadHash: utils.HashPayload(req),
+	}
+	err = h.store.CheckBondAndUpdateInviteCode(inviteCode, dao.EventBoundAdmin, meta, ReferralSpec(h.cfg, campaign.ID))
+	if err != nil {
+		switch {
+		case errors.Is(err, dao.ErrAlreadyBond):
+			utils.Err(c, codeInviteCodeAlreadyBoundErr, "")
+		case errors.Is(err, gorm.ErrDuplicatedKey):
+			utils.Err(c, codeUserAlreadyBoundErr, "")
+		default:
+			utils.Err(c, codeInternalErr, err.Error())
+			logrus.Errorf("CheckBondAndUpdateInviteCode err %s", err)
+		}
+		return
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"req":   req,
+		"admin": adminActor(c),
+	}).Info("admin bind success")
+
+	utils.Ok(c, nil)
+}
+
+// @Summary rebind invite code to another user
+// @Description Moves a bound single-use invite code to another user in one step.
+// @Description The previous binding is kept in history like an unbind. The discord fields are optional.
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param campaign query string false "campaign name, the default campaign if empty"
+// @Param param body ReqAdminRebind true "rebind"
+// @Success 200 {object} utils.Rsp{data=RspAdminRebind}
+// @Router /admin/v1/invite/rebind [post]
+func (h *Handler) HandlePostAdminRebind(c *gin.Context) {
+	req := ReqAdminRebind{}
+	err := c.Bind(&req)
+	if err != nil {
+		utils.Err(c, codeParamErr, err.Error())
+		logrus.Errorf("bind err %s", err)
+		return
+	}
+	if len(req.InviteCode) == 0 || len(req.UserAddress) == 0 {
+		utils.Err(c, codeParamErr, "")
+		return
+	}
+	req.UserAddress = strings.ToLower(req.UserAddress)
+
+	campaign, ok := h.getCampaign(c)
+	if !ok {
+		return
+	}
+	inviteCode, ok := h.getAdminCode(c, req.InviteCode, campaign)
+	if !ok {
+		return
+	}
+	if !h.checkAdminUser(c, campaign.ID, inviteCode.InviteCode, req.UserAddress, req.DiscordId) {
+		return
+	}
+
+	user := dao.CodeUser{
+		UserAddress: req.UserAddress,
+		DiscordId:   optionalString(req.DiscordId),
+		DiscordName: optionalString(req.DiscordName),
+	}
+	meta := dao.EventMeta{
+		Actor:       adminActor(c),
+		SourceIp:    c.ClientIP(),
+		PayloadHash: utils.HashPayload(req),
+	}
+	prev, removed, err := h.store.RebindInviteCode(inviteCode.InviteCode, user, req.Reason, meta, ReferralSpec(h.cfg, campaign.ID))
+	if err != nil {
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			utils.Err(c, codeInviteCodeNotExistErr, "")
+		case errors.Is(err, dao.ErrNotBound):
+			utils.Err(c, codeInviteCodeNotBoundErr, "")
+		case errors.Is(err, dao.ErrAlreadyRevoked):
+			utils.Err(c, codeInviteCodeRevokedErr, "")
+		case errors.Is(err, dao.ErrMultiUse):
+			utils.Err(c, codeInviteCodeMultiUseErr, "")
+		case errors.Is(err, gorm.ErrDuplicatedKey):
+			utils.Err(c, codeUserAlreadyBoundErr, "")
+		default:
+			utils.Err(c, codeInternalErr, err.Error())
+			logrus.Errorf("RebindInviteCode err %s", err)
+		}
+		return
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"req":   req,
+		"admin": adminActor(c),
+	}).Info("rebind success")
+
+	utils.Ok(c, RspAdminRebind{
+		InviteCode:  prev.InviteCode,
+		UserAddress: req.UserAddress,
+		Removed:     toRedemptions(removed),
+	})
+}
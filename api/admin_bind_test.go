@@ -0,0 +1,98 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"invite-code-service/dao"
+	"invite-code-service/pkg/config"
+	"invite-code-service/pkg/utils"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAdminBindAndRebind(t *testing.T) {
+	store := dao.NewMemStore()
+	cfg := &config.ConfigApi{AdminApiKeys: []config.AdminApiKey{{Name: "ops", Key: "secret"}}}
+	router := InitRouters(store, cfg)
+
+	for _, c := range []*dao.InviteCode{
+		{InviteCode: "DIRECT01", CodeType: dao.DirectInviteCode},
+		{InviteCode: "DIRECT02", CodeType: dao.DirectInviteCode},
+		{InviteCode: "WATER001", CodeType: dao.WaterInviteCode},
+	} {
+		if err := store.CreateInviteCode(c, dao.SystemEventMeta); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	post := func(path string, req any) (utils.Rsp, RspAdminRebind) {
+		body, _ := json.Marshal(req)
+		r := httptest.NewRequest(http.MethodPost, path, bytes.NewReader(body))
+		r.Header.Set("Content-Type", "application/json")
+		r.Header.Set(headerApiKey, "secret")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+
+		rebind := RspAdminRebind{}
+		rsp := utils.Rsp{Data: &rebind}
+		if err := json.Unmarshal(w.Body.Bytes(), &rsp); err != nil {
+			t.Fatal(err)
+		}
+		return rsp, rebind
+	}
+	bind := func(req ReqAdminBind) string {
+		rsp, _ := post("/api/admin/v1/invite/bind", req)
+		return rsp.Status
+	}
+
+	for _, tc := range []struct {
+		req    ReqAdminBind
+		status string
+	}{
+		{ReqAdminBind{InviteCode: "NOPE0001", UserAddress: "0x01"}, codeInviteCodeNotExistErr},
+		{ReqAdminBind{InviteCode: "WATER001", UserAddress: "0x01"}, codeInviteCodeTypeNotMatchErr},
+		{ReqAdminBind{InviteCode: "DIRECT01", UserAddress: "0xAB", DiscordId: "1"}, "80000"},
+		{ReqAdminBind{InviteCode: "DIRECT01", UserAddress: "0x02"}, codeInviteCodeAlreadyBoundErr},
+		{ReqAdminBind{InviteCode: "DIRECT02", UserAddress: "0xab"}, codeUserAlreadyBoundErr},
+		{ReqAdminBind{InviteCode: "DIRECT02", UserAddress: "0x02", DiscordId: "1"}, codeDiscordAlreadyBoundErr},
+	} {
+		if status := bind(tc.req); status != tc.status {
+			t.Fatalf("%+v: expect %s, got %s", tc.req, tc.status, status)
+		}
+	}
+
+	rsp, rebind := post("/api/admin/v1/invite/rebind", ReqAdminRebind{InviteCode: "DIRECT02", UserAddress: "0x02"})
+	if rsp.Status != codeInviteCodeNotBoundErr {
+		t.Fatalf("expect %s, got %s", codeInviteCodeNotBoundErr, rsp.Status)
+	}
+	// keeping the address and changing the discord id is allowed
+	rsp, rebind = post("/api/admin/v1/invite/rebind", ReqAdminRebind{InviteCode: "DIRECT01", UserAddress: "0xab", DiscordId: "2", Reason: "discord"})
+	if rsp.Status != "80000" || len(rebind.Removed) != 1 || rebind.Removed[0].DiscordId != "1" {
+		t.Fatalf("unexpected rsp: %+v %+v", rsp, rebind)
+	}
+	rsp, rebind = post("/api/admin/v1/invite/rebind", ReqAdminRebind{InviteCode: "DIRECT01", UserAddress: "0xCD", Reason: "wrong address"})
+	if rsp.Status != "80000" || rebind.UserAddress != "0xcd" || rebind.Removed[0].UserAddress != "0xab" {
+		t.Fatalf("unexpected rsp: %+v %+v", rsp, rebind)
+	}
+
+	inviteCode, err := store.GetInviteCodeByUserAddress(dao.DefaultCampaignId, "0xcd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if inviteCode.InviteCode != "DIRECT01" || inviteCode.DiscordId != nil {
+		t.Fatalf("unexpected code: %+v", inviteCode)
+	}
+	events, err := store.GetInviteCodeEvents("DIRECT01")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range events[1:] {
+		if e.Actor != "admin:ops" {
+			t.Fatalf("unexpected event actor: %+v", e)
+		}
+	}
+	if len(events) != 6 || events[1].EventType != dao.EventBoundAdmin || events[5].EventType != dao.EventBoundAdmin {
+		t.Fatalf("unexpected events: %+v", events)
+	}
+}
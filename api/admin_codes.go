@@ -0,0 +1,289 @@
+package api
+
+import (
+	"errors"
+	"invite-code-service/dao"
+	"invite-code-service/pkg/utils"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+type ReqAdminCodes struct {
+	Campaign    string `form:"campaign"`
+	Code        string `form:"code"`
+	Address     string `form:"address"`
+	DiscordId   string `form:"discord_id"`
+	DiscordName string `form:"discord_name"`
+	Type        string `form:"type"`
+	Bound       *bool  `form:"bound"`
+	Batch       string `form:"batch"`
+	BindFrom    uint64 `form:"bind_from"`
+	BindTo      uint64 `form:"bind_to"`
+	Sort        string `form:"sort"`
+	Order       string `form:"order"`
+	Page        int    `form:"page"`
+	PageSize    int    `form:"page_size"`
+}
+
+type RspAdminCodes struct {
+	Total    uint64      `json:"total"`
+	Page     int         `json:"page"`
+	PageSize int         `json:"page_size"`
+	List     []AdminCode `json:"list"`
+}
+
+// AdminCode is an invite code with everything stored about it
+type AdminCode struct {
+	InviteCode     string `json:"invite_code"`
+	Campaign       string `json:"campaign"`
+	CodeType       string `json:"code_type"`
+	Batch          string `json:"batch"`
+	UserAddress    string `json:"user_address"`
+	DiscordId      string `json:"discord_id"`
+	DiscordName    string `json:"discord_name"`
+	UserId         string `json:"user_id"`
+	InviterAddress string `json:"inviter_address"`
+	CreateTime     uint64 `json:"create_time"`
+	BindTime       uint64 `json:"bind_time"`
+	ValidFrom      uint64 `json:"valid_from"`
+	ExpiresAt      uint64 `json:"expires_at"`
+	RevokeTime     uint64 `json:"revoke_time"`
+	MaxUses        uint64 `json:"max_uses"`
+	UseCount       uint64 `json:"use_count"`
+}
+
+type RspAdminCode struct {
+	Code        AdminCode           `json:"code"`
+	Redemptions []Redemption        `json:"redemptions"`
+	Droplets    []DropletAssignment `json:"droplets"`
+}
+
+// DropletAssignment is a droplet a code is assigned to
+type DropletAssignment struct {
+	Round        uint8 `json:"round"`
+	DropletIndex uint8 `json:"droplet_index"`
+}
+
+// adminNames resolves the campaign and batch ids of codes to their names
+type adminNames struct {
+	campaigns map[int64]string
+	batches   map[int64]string
+}
+
+func (h *Handler) getAdminNames() (*adminNames, error) {
+	campaigns, err := h.store.GetCampaigns()
+	if err != nil {
+		return nil, err
+	}
+	batches, err := h.store.GetBatches()
+	if err != nil {
+		return nil, err
+	}
+	names := &adminNames{campaigns: make(map[int64]string), batches: make(map[int64]string)}
+	for _, c := range campaigns {
+		names.campaigns[c.ID] = c.Name
+	}
+	for _, b := range batches {
+		names.batches[b.ID] = b.Name
+	}
+	return names, nil
+}
+
+func (n *adminNames) toAdminCode(c *dao.InviteCode) AdminCode {
+	return AdminCode{
+		InviteCode:     c.InviteCode,
+		Campaign:       n.campaigns[c.CampaignId],
+		CodeType:       dao.CodeTypeName(c.CodeType),
+		Batch:          n.batches[c.BatchId],
+		UserAddress:    derefString(c.UserAddress),
+		DiscordId:      derefString(c.DiscordId),
+		DiscordName:    derefString(c.DiscordName),
+		UserId:         derefString(c.UserId),
+		InviterAddress: derefString(c.InviterAddress),
+		CreateTime:     uint64(c.CreatedAt),
+		BindTime:       c.BindTime,
+		ValidFrom:      c.ValidFrom,
+		ExpiresAt:      c.ExpiresAt,
+		RevokeTime:     c.RevokeTime,
+		MaxUses:        c.MaxUses,
+		UseCount:       c.UseCount,
+	}
+}
+
+func derefString(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
+
+// @Summary search invite codes
+// @Description Pages through the invite codes matching every given filter.
+// @Description address, discord_id and discord_name match any user who redeemed the code.
+// @Tags admin
+// @Produce json
+// @Security ApiKeyAuth
+// @Param campaign query string false "campaign name, every campaign if empty"
+// @Param code query string false "code prefix"
+// @Param address query string false "user address"
+// @Param discord_id query string false "discord id"
+// @Param discord_name query string false "part of the discord name"
+// @Param type query string false "code type: task, direct, water or referral"
+// @Param bound query bool false "redeemed at least once, or never"
+// @Param batch query string false "batch name"
+// @Param bind_from query int false "bind time from, unix seconds"
+// @Param bind_to query int false "bind time before, unix seconds"
+// @Param sort query string false "id (default), create_time, bind_time, use_count or expires_at"
+// @Param order query string false "asc (default) or desc"
+// @Param page query int false "page from 1, default 1"
+// @Param page_size query int false "page size, default 10, max 50"
+// @Success 200 {object} utils.Rsp{data=RspAdminCodes}
+// @Router /admin/v1/invite/codes [get]
+func (h *Handler) GetAdminCodes(c *gin.Context) {
+	req := ReqAdminCodes{}
+	if err := c.ShouldBindQuery(&req); err != nil {
+		utils.Err(c, codeParamErr, err.Error())
+		return
+	}
+	if req.Page == 0 {
+		req.Page = 1
+	}
+	if req.PageSize == 0 {
+		req.PageSize = utils.DefaultPageSize
+	}
+	if req.Page < 0 || req.PageSize < 0 {
+		utils.Err(c, codeParamErr, "invalid page")
+		return
+	}
+	req.PageSize = min(req.PageSize, utils.MaxPageSize)
+
+	order := dao.SearchSort{Field: "id"}
+	if len(req.Sort) > 0 {
+		order.Field = req.Sort
+	}
+	switch req.Order {
+	case "", "asc":
+	case "desc":
+		order.Desc = true
+	default:
+		utils.Err(c, codeParamErr, "invalid order")
+		return
+	}
+	if err := order.Check(); err != nil {
+		utils.Err(c, codeParamErr, err.Error())
+		return
+	}
+
+	filter := dao.SearchFilter{
+		ExportFilter: dao.ExportFilter{Bound: req.Bound, BindFrom: req.BindFrom, BindTo: req.BindTo},
+		CodePrefix:   strings.TrimSpace(req.Code),
+		UserAddress:  strings.ToLower(req.Address),
+		DiscordId:    req.DiscordId,
+		DiscordName:  req.DiscordName,
+	}
+	if len(req.Type) > 0 {
+		codeType, err := dao.ParseCodeType(req.Type)
+		if err != nil {
+			utils.Err(c, codeParamErr, err.Error())
+			return
+		}
+		filter.CodeTypes = []uint8{codeType}
+	}
+	if len(req.Campaign) > 0 {
+		campaign, ok := h.getCampaign(c)
+		if !ok {
+			return
+		}
+		filter.CampaignId = campaign.ID
+	}
+	if len(req.Batch) > 0 {
+		batch, err := h.store.GetBatch(req.Batch)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				utils.Err(c, codeBatchNotExistErr, "")
+				return
+			}
+			utils.Err(c, codeInternalErr, err.Error())
+			logrus.Errorf("GetBatch err %s", err)
+			return
+		}
+		filter.BatchId = batch.ID
+	}
+
+	codes, total, err := h.store.SearchInviteCodes(filter, order, (req.Page-1)*req.PageSize, req.PageSize)
+	if err != nil {
+		utils.Err(c, codeInternalErr, err.Error())
+		logrus.Errorf("SearchInviteCodes err %s", err)
+		return
+	}
+	names, err := h.getAdminNames()
+	if err != nil {
+		utils.Err(c, codeInternalErr, err.Error())
+		logrus.Errorf("getAdminNames err %s", err)
+		return
+	}
+
+	rsp := RspAdminCodes{Total: uint64(total), Page: req.Page, PageSize: req.PageSize, List: make([]AdminCode, 0, len(codes))}
+	for _, code := range codes {
+		rsp.List = append(rsp.List, names.toAdminCode(code))
+	}
+	utils.Ok(c, rsp)
+}
+
+// @Summary get invite code detail
+// @Description An invite code with its redemptions and the droplets it is assigned to
+// @Tags admin
+// @Produce json
+// @Security ApiKeyAuth
+// @Param code query string true "invite code"
+// @Success 200 {object} utils.Rsp{data=RspAdminCode}
+// @Router /admin/v1/invite/code [get]
+func (h *Handler) GetAdminCode(c *gin.Context) {
+	code := strings.TrimSpace(c.Query("code"))
+	if len(code) == 0 {
+		utils.Err(c, codeParamErr, "")
+		return
+	}
+
+	inviteCode, err := h.store.GetInviteCode(code)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			utils.Err(c, codeInviteCodeNotExistErr, "")
+			return
+		}
+		utils.Err(c, codeInternalErr, err.Error())
+		logrus.Errorf("GetInviteCode err %s", err)
+		return
+	}
+	redemptions, err := h.store.GetInviteCodeRedemptions(code)
+	if err != nil {
+		utils.Err(c, codeInternalErr, err.Error())
+		logrus.Errorf("GetInviteCodeRedemptions err %s", err)
+		return
+	}
+	droplets, err := h.store.GetInviteCodeDroplets(code)
+	if err != nil {
+		utils.Err(c, codeInternalErr, err.Error())
+		logrus.Errorf("GetInviteCodeDroplets err %s", err)
+		return
+	}
+	names, err := h.getAdminNames()
+	if err != nil {
+		utils.Err(c, codeInternalErr, err.Error())
+		logrus.Errorf("getAdminNames err %s", err)
+		return
+	}
+
+	rsp := RspAdminCode{
+		Code:        names.toAdminCode(inviteCode),
+		Redemptions: toRedemptions(redemptions),
+		Droplets:    make([]DropletAssignment, 0, len(droplets)),
+	}
+	for _, d := range droplets {
+		rsp.Droplets = append(rsp.Droplets, DropletAssignment{Round: d.Round, DropletIndex: d.DropletIndex})
+	}
+	utils.Ok(c, rsp)
+}
@@ -0,0 +1,83 @@
+package api
+
+import (
+	"encoding/json"
+	"invite-code-service/dao"
+	"invite-code-service/pkg/config"
+	"invite-code-service/pkg/utils"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAdminCodes(t *testing.T) {
+	store := dao.NewMemStore()
+	cfg := &config.ConfigApi{AdminApiKeys: []config.AdminApiKey{{Name: "ops", Key: "secret"}}}
+	router := InitRouters(store, cfg)
+
+	water, err := store.GenerateInviteCodes(dao.GenerateSpec{CodeType: dao.WaterInviteCode, Count: 25}, dao.SystemEventMeta)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.OpenDropletRound(dao.DefaultCampaignId, 0, dao.DropletShape{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.GenerateInviteCodes(dao.GenerateSpec{CodeType: dao.DirectInviteCode, Count: 3}, dao.SystemEventMeta); err != nil {
+		t.Fatal(err)
+	}
+	address := "0xabc"
+	bound := water[0]
+	bound.UserAddress = &address
+	bound.BindTime = 1
+	if err := store.CheckBondAndUpdateInviteCode(bound, dao.EventBoundBind, dao.SystemEventMeta, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	get := func(url, apiKey string, data any) (int, utils.Rsp) {
+		req := httptest.NewRequest(http.MethodGet, url, nil)
+		req.Header.Set(headerApiKey, apiKey)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		rsp := utils.Rsp{Data: data}
+		if w.Code == http.StatusOK {
+			if err := json.Unmarshal(w.Body.Bytes(), &rsp); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return w.Code, rsp
+	}
+
+	if code, _ := get("/api/admin/v1/invite/codes", "wrong", nil); code != http.StatusUnauthorized {
+		t.Fatalf("expect 401, got %d", code)
+	}
+
+	codes := &RspAdminCodes{}
+	_, rsp := get("/api/admin/v1/invite/codes?type=direct&page=2&page_size=2&order=desc", "secret", codes)
+	if rsp.Status != "80000" || codes.Total != 3 || len(codes.List) != 1 || codes.List[0].CodeType != "direct" || codes.List[0].Campaign != dao.DefaultCampaignName {
+		t.Fatalf("unexpected codes: %+v %+v", rsp, codes)
+	}
+
+	codes = &RspAdminCodes{}
+	_, rsp = get("/api/admin/v1/invite/codes?address=0xABC&bound=true", "secret", codes)
+	if rsp.Status != "80000" || codes.Total != 1 || codes.List[0].InviteCode != bound.InviteCode {
+		t.Fatalf("unexpected codes: %+v %+v", rsp, codes)
+	}
+
+	for _, query := range []string{"sort=discord_name", "order=up", "page=-1", "type=gold"} {
+		if _, rsp := get("/api/admin/v1/invite/codes?"+query, "secret", nil); rsp.Status != codeParamErr {
+			t.Fatalf("%s: expect %s, got %+v", query, codeParamErr, rsp)
+		}
+	}
+
+	detail := &RspAdminCode{}
+	_, rsp = get("/api/admin/v1/invite/code?code="+bound.InviteCode, "secret", detail)
+	if rsp.Status != "80000" || detail.Code.UserAddress != address || len(detail.Redemptions) != 1 {
+		t.Fatalf("unexpected detail: %+v %+v", rsp, detail)
+	}
+	if len(detail.Droplets) != 1 || detail.Droplets[0].Round != 0 {
+		t.Fatalf("unexpected droplets: %+v", detail.Droplets)
+	}
+	if _, rsp := get("/api/admin/v1/invite/code?code=NOPE0000", "secret", nil); rsp.Status != codeInviteCodeNotExistErr {
+		t.Fatalf("expect %s, got %+v", codeInviteCodeNotExistErr, rsp)
+	}
+}
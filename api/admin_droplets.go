@@ -0,0 +1,205 @@
+package api
+
+import (
+	"errors"
+	"invite-code-service/dao"
+	"invite-code-service/pkg/config"
+	"invite-code-service/pkg/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+type ReqAdminOpenDropletRound struct {
+	Droplets        uint8 `form:"droplets"`
+	CodesPerDroplet uint8 `form:"codes_per_droplet"`
+}
+
+type ReqAdminTierToken struct {
+	Tier      string `json:"tier"`
+	ExpiresAt uint64 `json:"expires_at"` // unix seconds, 0 never expires
+}
+
+type RspAdminTierToken struct {
+	Token string `json:"token"`
+}
+
+type RspAdminDropletRounds struct {
+	List []DropletRound `json:"list"`
+}
+
+// DropletRound is a droplet round of a campaign, the open one is served by GetDroplets
+type DropletRound struct {
+	Round           uint8  `json:"round"`
+	Open            bool   `json:"open"`
+	OpenTime        uint64 `json:"open_time"`
+	CloseTime       uint64 `json:"close_time"`
+	Droplets        uint8  `json:"droplets"`
+	CodesPerDroplet uint8  `json:"codes_per_droplet"`
+}
+
+func toDropletRound(r *dao.DropletRound) DropletRound {
+	return DropletRound{
+		Round:           r.Round,
+		Open:            r.IsOpen(),
+		OpenTime:        r.OpenTime,
+		CloseTime:       r.CloseTime,
+		Droplets:        r.Droplets,
+		CodesPerDroplet: r.CodesPerDroplet,
+	}
+}
+
+// @Summary list droplet rounds
+// @Description Every droplet round of a campaign, at most one is open
+// @Tags admin
+// @Produce json
+// @Security ApiKeyAuth
+// @Param campaign query string false "campaign name, the default campaign if empty"
+// @Success 200 {object} utils.Rsp{data=RspAdminDropletRounds}
+// @Router /admin/v1/droplet/rounds [get]
+func (h *Handler) GetAdminDropletRounds(c *gin.Context) {
+	campaign, ok := h.getCampaign(c)
+	if !ok {
+		return
+	}
+	rounds, err := h.store.GetDropletRounds(campaign.ID)
+	if err != nil {
+		utils.Err(c, codeInternalErr, err.Error())
+		logrus.Errorf("GetDropletRounds err %s", err)
+		return
+	}
+
+	rsp := RspAdminDropletRounds{List: make([]DropletRound, 0, len(rounds))}
+	for _, r := range rounds {
+		rsp.List = append(rsp.List, toDropletRound(r))
+	}
+	utils.Ok(c, rsp)
+}
+
+// @Summary open the next droplet round
+// @Description Closes the open droplet round of a campaign and opens the next one
+// @Description with droplets of available water codes. The shape defaults to the configured DropletShape.
+// @Tags admin
+// @Produce json
+// @Security ApiKeyAuth
+// @Param campaign query string false "campaign name, the default campaign if empty"
+// @Param droplets query int false "droplets of the round"
+// @Param codes_per_droplet query int false "codes of each droplet"
+// @Success 200 {object} utils.Rsp{data=DropletRound}
+// @Router /admin/v1/droplet/round/open [post]
+func (h *Handler) HandlePostAdminOpenDropletRound(c *gin.Context) {
+	req := ReqAdminOpenDropletRound{}
+	if err := c.ShouldBindQuery(&req); err != nil {
+		utils.Err(c, codeParamErr, err.Error())
+		return
+	}
+	campaign, ok := h.getCampaign(c)
+	if !ok {
+		return
+	}
+	rounds, err := h.store.GetDropletRounds(campaign.ID)
+	if err != nil {
+		utils.Err(c, codeInternalErr, err.Error())
+		logrus.Errorf("GetDropletRounds err %s", err)
+		return
+	}
+
+	shape := config.DropletShape{Droplets: req.Droplets, CodesPerDroplet: req.CodesPerDroplet}
+	shape = shape.Or(h.cfg.CampaignDropletShape(campaign.Name))
+	round, err := h.store.OpenDropletRound(campaign.ID, dao.NextDropletRound(rounds), dao.DropletShape(shape))
+	if err != nil {
+		switch {
+		case errors.Is(err, dao.ErrNotEnoughDropletCodes):
+			utils.Err(c, codeInviteCodeNotEnoughErr, err.Error())
+		case errors.Is(err, dao.ErrDropletRoundOrder), errors.Is(err, gorm.ErrDuplicatedKey):
+			// another admin opened a round meanwhile
+			utils.Err(c, codeInternalErr, "droplet round opened concurrently")
+		default:
+			utils.Err(c, codeInternalErr, err.Error())
+			logrus.Errorf("OpenDropletRound err %s", err)
+		}
+		return
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"campaign": campaign.Name,
+		"round":    round.Round,
+		"shape":    round.Shape(),
+		"admin":    adminActor(c),
+	}).Info("open droplet round success")
+
+	utils.Ok(c, toDropletRound(round))
+}
+
+// @Summary close the open droplet round
+// @Description Closes the open droplet round of a campaign, no droplets are served until the next one opens
+// @Tags admin
+// @Produce json
+// @Security ApiKeyAuth
+// @Param campaign query string false "campaign name, the default campaign if empty"
+// @Success 200 {object} utils.Rsp{data=DropletRound}
+// @Router /admin/v1/droplet/round/close [post]
+func (h *Handler) HandlePostAdminCloseDropletRound(c *gin.Context) {
+	campaign, ok := h.getCampaign(c)
+	if !ok {
+		return
+	}
+	round, err := h.store.CloseDropletRound(campaign.ID)
+	if err != nil {
+		if errors.Is(err, dao.ErrNoOpenDropletRound) {
+			utils.Err(c, codeNoOpenDropletRoundErr, "")
+			return
+		}
+		utils.Err(c, codeInternalErr, err.Error())
+		logrus.Errorf("CloseDropletRound err %s", err)
+		return
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"campaign": campaign.Name,
+		"round":    round.Round,
+		"admin":    adminActor(c),
+	}).Info("close droplet round success")
+
+	utils.Ok(c, toDropletRound(round))
+}
+
+// @Summary sign a droplet tier token
+// @Description A token selecting a tier of the droplets shown, passed as tier_token to GetDroplets.
+// @Description The tier must be configured in DropletReveal and signing needs its TierSecret.
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param param body ReqAdminTierToken true "tier"
+// @Success 200 {object} utils.Rsp{data=RspAdminTierToken}
+// @Router /admin/v1/droplet/tier/token [post]
+func (h *Handler) HandlePostAdminTierToken(c *gin.Context) {
+	req := ReqAdminTierToken{}
+	if err := c.Bind(&req); err != nil {
+		utils.Err(c, codeParamErr, err.Error())
+		return
+	}
+	reveal := DropletReveal(h.cfg)
+	if len(reveal.TierSecret) == 0 {
+		utils.Err(c, codeParamErr, "no TierSecret configured")
+		return
+	}
+	found := false
+	for _, tier := range reveal.Tiers {
+		found = found || tier.Name == req.Tier
+	}
+	if !found {
+		utils.Err(c, codeParamErr, "unknown tier")
+		return
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"tier":       req.Tier,
+		"expires_at": req.ExpiresAt,
+		"admin":      adminActor(c),
+	}).Info("sign tier token success")
+
+	utils.Ok(c, RspAdminTierToken{Token: utils.SignTierToken(reveal.TierSecret, req.Tier, req.ExpiresAt)})
+}
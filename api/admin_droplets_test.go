@@ -0,0 +1,83 @@
+package api
+
+import (
+	"encoding/json"
+	"invite-code-service/dao"
+	"invite-code-service/pkg/config"
+	"invite-code-service/pkg/utils"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAdminDropletRounds(t *testing.T) {
+	store := dao.NewMemStore()
+	cfg := &config.ConfigApi{
+		AdminApiKeys:  []config.AdminApiKey{{Name: "ops", Key: "secret"}},
+		DropletShape:  config.DropletShape{Droplets: 4},
+		DropletReveal: config.DropletReveal{TierSecret: "tiers"},
+	}
+	router := InitRouters(store, cfg)
+
+	do := func(method, path string, data any) string {
+		req := httptest.NewRequest(method, path, nil)
+		req.Header.Set(headerApiKey, "secret")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		rsp := utils.Rsp{Data: data}
+		if err := json.Unmarshal(w.Body.Bytes(), &rsp); err != nil {
+			t.Fatal(err)
+		}
+		return rsp.Status
+	}
+
+	if status := do(http.MethodPost, "/api/admin/v1/droplet/round/open", nil); status != codeInviteCodeNotEnoughErr {
+		t.Fatalf("expect %s, got %s", codeInviteCodeNotEnoughErr, status)
+	}
+	if _, err := store.GenerateInviteCodes(dao.GenerateSpec{CodeType: dao.WaterInviteCode, Count: 25}, dao.SystemEventMeta); err != nil {
+		t.Fatal(err)
+	}
+	for want, query := range []string{"", "?codes_per_droplet=3"} {
+		round := DropletRound{}
+		if status := do(http.MethodPost, "/api/admin/v1/droplet/round/open"+query, &round); status != "80000" || round.Round != uint8(want) || !round.Open {
+			t.Fatalf("unexpected open: %s %+v", status, round)
+		}
+		if round.Droplets != 4 || (want == 0 && round.CodesPerDroplet != 5) || (want == 1 && round.CodesPerDroplet != 3) {
+			t.Fatalf("unexpected shape: %+v", round)
+		}
+	}
+	if status := do(http.MethodPost, "/api/admin/v1/droplet/round/open?droplets=x", nil); status != codeParamErr {
+		t.Fatalf("expect %s, got %s", codeParamErr, status)
+	}
+
+	// the sp tier shows 3 droplets at least
+	sp := "/api/v1/invite/droplets?tier_token=" + utils.SignTierToken("tiers", "sp", 0)
+	droplets := RspDroplets{}
+	if status := do(http.MethodGet, sp, &droplets); status != "80000" || len(droplets.Droplets) == 0 || droplets.Droplets[0].Round != 1 {
+		t.Fatalf("unexpected droplets: %s %+v", status, droplets)
+	}
+	for _, d := range droplets.Droplets {
+		if d.TotalCount != 3 || d.AvailableCount != 3 {
+			t.Fatalf("unexpected droplet: %+v", d)
+		}
+	}
+
+	if status := do(http.MethodPost, "/api/admin/v1/droplet/round/close", nil); status != "80000" {
+		t.Fatalf("unexpected close: %s", status)
+	}
+	if status := do(http.MethodPost, "/api/admin/v1/droplet/round/close", nil); status != codeNoOpenDropletRoundErr {
+		t.Fatalf("expect %s, got %s", codeNoOpenDropletRoundErr, status)
+	}
+	droplets = RspDroplets{}
+	if status := do(http.MethodGet, sp, &droplets); status != "80000" || len(droplets.Droplets) != 0 {
+		t.Fatalf("expect no droplets while closed: %s %+v", status, droplets)
+	}
+
+	rounds := RspAdminDropletRounds{}
+	if status := do(http.MethodGet, "/api/admin/v1/droplet/rounds", &rounds); status != "80000" {
+		t.Fatalf("unexpected status: %s", status)
+	}
+	if len(rounds.List) != 2 || rounds.List[0].Open || rounds.List[1].Open || rounds.List[1].CloseTime == 0 {
+		t.Fatalf("unexpected rounds: %+v", rounds)
+	}
+}
@@ -0,0 +1,103 @@
+package api
+
+import (
+	"errors"
+	"invite-code-service/dao"
+	"invite-code-service/pkg/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+type ReqAdminUnbind struct {
+	InviteCode string `json:"invite_code"`
+	Revoke     bool   `json:"revoke"`
+	Reason     string `json:"reason"`
+}
+
+type RspAdminUnbind struct {
+	InviteCode string       `json:"invite_code"`
+	Revoked    bool         `json:"revoked"`
+	Removed    []Redemption `json:"removed"`
+}
+
+type Redemption struct {
+	UserAddress string `json:"user_address"`
+	DiscordId   string `json:"discord_id"`
+	DiscordName string `json:"discord_name"`
+	RedeemTime  uint64 `json:"redeem_time"`
+}
+
+func toRedemptions(list []*dao.InviteCodeRedemption) []Redemption {
+	redemptions := make([]Redemption, 0, len(list))
+	for _, r := range list {
+		redemption := Redemption{RedeemTime: r.RedeemTime}
+		if r.UserAddress != nil {
+			redemption.UserAddress = *r.UserAddress
+		}
+		if r.DiscordId != nil {
+			redemption.DiscordId = *r.DiscordId
+		}
+		if r.DiscordName != nil {
+			redemption.DiscordName = *r.DiscordName
+		}
+		redemptions = append(redemptions, redemption)
+	}
+	return redemptions
+}
+
+// @Summary unbind or revoke invite code
+// @Description Clears every redemption of an invite code so it can be bound again,
+// @Description or with revoke marks it permanently unusable. The removed redemptions are kept in history.
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Security ApiKeyAuth
+// @Param param body ReqAdminUnbind true "unbind"
+// @Success 200 {object} utils.Rsp{data=RspAdminUnbind}
+// @Router /admin/v1/invite/unbind [post]
+func (h *Handler) HandlePostAdminUnbind(c *gin.Context) {
+	req := ReqAdminUnbind{}
+	err := c.Bind(&req)
+	if err != nil {
+		utils.Err(c, codeParamErr, err.Error())
+		logrus.Errorf("bind err %s", err)
+		return
+	}
+	if len(req.InviteCode) == 0 {
+		utils.Err(c, codeParamErr, "")
+		return
+	}
+
+	prev, removed, err := h.store.UnbindInviteCode(req.InviteCode, req.Revoke, req.Reason, dao.EventMeta{
+		Actor:       adminActor(c),
+		SourceIp:    c.ClientIP(),
+		PayloadHash: utils.HashPayload(req),
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			utils.Err(c, codeInviteCodeNotExistErr, "")
+		case errors.Is(err, dao.ErrNotBound):
+			utils.Err(c, codeInviteCodeNotBoundErr, "")
+		case errors.Is(err, dao.ErrAlreadyRevoked):
+			utils.Err(c, codeInviteCodeRevokedErr, "")
+		default:
+			utils.Err(c, codeInternalErr, err.Error())
+			logrus.Errorf("UnbindInviteCode err %s", err)
+		}
+		return
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"req":   req,
+		"admin": adminActor(c),
+	}).Info("unbind success")
+
+	utils.Ok(c, RspAdminUnbind{
+		InviteCode: prev.InviteCode,
+		Revoked:    req.Revoke,
+		Removed:    toRedemptions(removed),
+	})
+}
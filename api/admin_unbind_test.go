@@ -0,0 +1,72 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"invite-code-service/dao"
+	"invite-code-service/pkg/config"
+	"invite-code-service/pkg/utils"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAdminUnbind(t *testing.T) {
+	store := dao.NewMemStore()
+	cfg := &config.ConfigApi{AdminApiKeys: []config.AdminApiKey{{Name: "ops", Key: "secret"}}}
+	router := InitRouters(store, cfg)
+
+	address := "0xabc"
+	if err := store.CreateInviteCode(&dao.InviteCode{InviteCode: "DIRECT01", CodeType: dao.DirectInviteCode}, dao.SystemEventMeta); err != nil {
+		t.Fatal(err)
+	}
+	inviteCode, _ := store.GetInviteCode("DIRECT01")
+	inviteCode.UserAddress = &address
+	inviteCode.BindTime = 1
+	if err := store.CheckBondAndUpdateInviteCode(inviteCode, dao.EventBoundCli, dao.SystemEventMeta, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	post := func(apiKey string) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(ReqAdminUnbind{InviteCode: "DIRECT01", Reason: "test"})
+		req := httptest.NewRequest(http.MethodPost, "/api/admin/v1/invite/unbind", bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		if len(apiKey) > 0 {
+			req.Header.Set(headerApiKey, apiKey)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	for _, key := range []string{"", "wrong"} {
+		if w := post(key); w.Code != http.StatusUnauthorized {
+			t.Fatalf("key %q: expect 401, got %d", key, w.Code)
+		}
+	}
+
+	w := post("secret")
+	rsp := utils.Rsp{}
+	if err := json.Unmarshal(w.Body.Bytes(), &rsp); err != nil {
+		t.Fatal(err)
+	}
+	if rsp.Status != "80000" {
+		t.Fatalf("unexpected rsp: %s", w.Body.String())
+	}
+
+	events, err := store.GetInviteCodeEvents(address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 2 || events[1].EventType != dao.EventUnbound || events[1].Actor != "admin:ops" {
+		t.Fatalf("unexpected events: %+v", events)
+	}
+
+	w = post("secret")
+	if err := json.Unmarshal(w.Body.Bytes(), &rsp); err != nil {
+		t.Fatal(err)
+	}
+	if rsp.Status != codeInviteCodeNotBoundErr {
+		t.Fatalf("expect %s, got %s", codeInviteCodeNotBoundErr, rsp.Status)
+	}
+}
@@ -29,6 +29,7 @@ type ReqBind struct {
 // @Tags v1
 // @Accept json
 // @Produce json
+// @Param campaign query string false "campaign name, the default campaign if empty"
 // @Param param body ReqBind true "bind"
 // @Success 200 {object} utils.Rsp{}
 // @Router /v1/invite/bind [post]
@@ -46,10 +47,26 @@ func (h *Handler) HandlePostBind(c *gin.Context) {
 	}
 	req.UserAddress = strings.ToLower(req.UserAddress)
 
+	// the signature covers the code as sent, lookups use the normalized one
+	code, err := h.normalizeCode(req.InviteCode)
+	if err != nil {
+		utils.Err(c, codeInviteCodeMalformedErr, "")
+		return
+	}
+
+	campaign, ok := h.getCampaign(c)
+	if !ok {
+		return
+	}
+	if !campaign.IsOpen(uint64(time.Now().Unix())) {
+		utils.Err(c, codeCampaignNotOpenErr, "")
+		return
+	}
+
 	sigBts := common.FromHex(req.Signature)
 	userAddress := common.HexToAddress(req.UserAddress)
 
-	_, err = dao.GetInviteCodeByUserAddress(h.db, req.UserAddress)
+	_, err = h.store.GetInviteCodeByUserAddress(campaign.ID, req.UserAddress)
 	if err != nil {
 		if err != gorm.ErrRecordNotFound {
 			utils.Err(c, codeInternalErr, err.Error())
@@ -62,7 +79,7 @@ func (h *Handler) HandlePostBind(c *gin.Context) {
 		return
 	}
 
-	_, err = dao.GetInviteCodeByDiscordId(h.db, req.DiscordId)
+	_, err = h.store.GetInviteCodeByDiscordId(campaign.ID, req.DiscordId)
 	if err != nil {
 		if err != gorm.ErrRecordNotFound {
 			utils.Err(c, codeInternalErr, err.Error())
@@ -89,8 +106,8 @@ func (h *Handler) HandlePostBind(c *gin.Context) {
 		return
 	}
 
-	// bind direct or water invite code
-	inviteCode, err := dao.GetInviteCode(h.db, req.InviteCode)
+	// bind direct, water or referral invite code
+	inviteCode, err := h.store.GetInviteCode(code)
 	if err != nil {
 		if err != gorm.ErrRecordNotFound {
 			utils.Err(c, codeInternalErr, err.Error())
@@ -106,10 +123,32 @@ func (h *Handler) HandlePostBind(c *gin.Context) {
 			return
 		}
 
-		if inviteCode.CodeType != dao.DirectInviteCode && inviteCode.CodeType != dao.WaterInviteCode {
+		if inviteCode.IsRevoked() {
+			utils.Err(c, codeInviteCodeRevokedErr, "")
+			return
+		}
+
+		// codes of other campaigns don't exist in this one
+		if inviteCode.CampaignId != campaign.ID {
+			utils.Err(c, codeInviteCodeNotExistErr, "")
+			return
+		}
+
+		if inviteCode.CodeType != dao.DirectInviteCode && inviteCode.CodeType != dao.WaterInviteCode &&
+			inviteCode.CodeType != dao.ReferralInviteCode {
 			utils.Err(c, codeInviteCodeTypeNotMatchErr, "")
 			return
 		}
+
+		now := uint64(time.Now().Unix())
+		if inviteCode.IsExpired(now) {
+			utils.Err(c, codeInviteCodeExpiredErr, "")
+			return
+		}
+		if inviteCode.IsNotYetValid(now) {
+			utils.Err(c, codeInviteCodeNotYetValidErr, "")
+			return
+		}
 		// pass
 	}
 
@@ -118,9 +157,14 @@ func (h *Handler) HandlePostBind(c *gin.Context) {
 	inviteCode.DiscordName = &req.DiscordName
 	inviteCode.BindTime = uint64(time.Now().Unix())
 
-	err = dao.CheckBondAndUpdateInviteCode(h.db, inviteCode)
+	meta := dao.EventMeta{
+		Actor:       req.UserAddress,
+		SourceIp:    c.ClientIP(),
+		PayloadHash: utils.HashPayload(req),
+	}
+	err = h.store.CheckBondAndUpdateInviteCode(inviteCode, dao.EventBoundBind, meta, ReferralSpec(h.cfg, campaign.ID))
 	if err != nil {
-		if errors.Is(err, dao.ErrAlreadyBond) {
+		if errors.Is(err, dao.ErrAlreadyBond) || errors.Is(err, gorm.ErrDuplicatedKey) {
 			utils.Err(c, codeUserAlreadyBoundErr, "")
 			return
 		}
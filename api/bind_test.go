@@ -0,0 +1,50 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"invite-code-service/dao"
+	"invite-code-service/pkg/config"
+	"invite-code-service/pkg/utils"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestBindMalformedCode(t *testing.T) {
+	store := dao.NewMemStore()
+	cfg := &config.ConfigApi{CodeFormat: config.CodeFormat{Alphabet: "unambiguous", CheckChar: true, DirectPrefix: "D"}}
+	router := InitRouters(store, cfg)
+
+	code, err := CodeFormat(cfg, dao.DirectInviteCode).Generate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	typo := []byte(code)
+	if typo[1] == 'A' {
+		typo[1] = 'C'
+	} else {
+		typo[1] = 'A'
+	}
+
+	post := func(inviteCode string) string {
+		body, _ := json.Marshal(ReqBind{UserAddress: "0xabc", DiscordId: "1", DiscordName: "user", InviteCode: inviteCode, Signature: "0x00"})
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/invite/bind", bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		rsp := utils.Rsp{}
+		if err := json.Unmarshal(w.Body.Bytes(), &rsp); err != nil {
+			t.Fatal(err)
+		}
+		return rsp.Status
+	}
+
+	if status := post(string(typo)); status != codeInviteCodeMalformedErr {
+		t.Fatalf("typo %s of %s: expect %s, got %s", typo, code, codeInviteCodeMalformedErr, status)
+	}
+	// a well formed code passes the format check and fails later on the signature
+	if status := post(code); status == codeInviteCodeMalformedErr {
+		t.Fatalf("code %s rejected as malformed", code)
+	}
+}
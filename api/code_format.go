@@ -0,0 +1,50 @@
+package api
+
+import (
+	"fmt"
+	"invite-code-service/dao"
+	"invite-code-service/pkg/config"
+	"invite-code-service/pkg/utils"
+)
+
+var codeTypes = []uint8{dao.TaskInviteCode, dao.DirectInviteCode, dao.WaterInviteCode, dao.ReferralInviteCode}
+
+// CodeFormat returns the configured format of codes of codeType
+func CodeFormat(cfg *config.ConfigApi, codeType uint8) utils.CodeFormat {
+	prefix := ""
+	switch codeType {
+	case dao.TaskInviteCode:
+		prefix = cfg.CodeFormat.TaskPrefix
+	case dao.DirectInviteCode:
+		prefix = cfg.CodeFormat.DirectPrefix
+	case dao.WaterInviteCode:
+		prefix = cfg.CodeFormat.WaterPrefix
+	case dao.ReferralInviteCode:
+		prefix = cfg.CodeFormat.ReferralPrefix
+	}
+	return cfg.CodeFormat.Format(prefix)
+}
+
+// CheckCodeFormats reports a configured format that can't generate valid codes
+func CheckCodeFormats(cfg *config.ConfigApi) error {
+	for _, codeType := range codeTypes {
+		if err := CodeFormat(cfg, codeType).Check(); err != nil {
+			return fmt.Errorf("code type %d: %w", codeType, err)
+		}
+	}
+	return nil
+}
+
+// normalizeCode normalizes a user supplied code and checks it has the format
+// of some code type, without touching the database
+func (h *Handler) normalizeCode(code string) (string, error) {
+	var err error
+	for _, codeType := range codeTypes {
+		format := CodeFormat(h.cfg, codeType)
+		normalized := format.Normalize(code)
+		if err = format.Validate(normalized); err == nil {
+			return normalized, nil
+		}
+	}
+	return "", err
+}
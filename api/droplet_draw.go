@@ -0,0 +1,99 @@
+package api
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	mathrand "math/rand"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const defaultDropletDrawSlot = time.Hour
+
+const (
+	dropletSessionCookie = "droplet_session"
+	dropletSessionMaxAge = 30 * 24 * 3600
+)
+
+// dropletDrawSlot returns the slot of now and when the next one starts, in unix seconds
+func (h *Handler) dropletDrawSlot(now time.Time) (slot, next uint64) {
+	length := uint64(h.cfg.DropletReveal.DrawSlot / time.Second)
+	if length == 0 {
+		length = uint64(defaultDropletDrawSlot / time.Second)
+	}
+	slot = uint64(now.Unix()) / length
+	return slot, (slot + 1) * length
+}
+
+// dropletDrawUser identifies the user of a draw by the signed session of the
+// droplet_session cookie or the session query param, a new one derived from
+// the client ip without a valid one. A session stays with its client when the
+// ip changes, and as its id comes from the ip a client gets no other draw by
+// dropping it. The token of the session is returned to be handed back.
+func (h *Handler) dropletDrawUser(c *gin.Context) (user, token string) {
+	secret := []byte(h.cfg.DropletReveal.DrawSecret)
+	token, _ = c.Cookie(dropletSessionCookie)
+	if len(token) == 0 {
+		token = c.Query("session")
+	}
+	id, ok := verifyDropletSession(secret, token)
+	if !ok {
+		id = dropletSessionId(secret, c.ClientIP())
+		token = signDropletSession(secret, id)
+	}
+	return "session:" + id, token
+}
+
+// dropletSessionId is the id of the session first issued to ip
+func dropletSessionId(secret []byte, ip string) string {
+	mac := hmac.New(sha256.New, secret)
+	fmt.Fprintf(mac, "session-id|%s", ip)
+	return hex.EncodeToString(mac.Sum(nil)[:16])
+}
+
+// signDropletSession returns the token of a session, id.base64url(hmac)
+func signDropletSession(secret []byte, id string) string {
+	return id + "." + base64.RawURLEncoding.EncodeToString(dropletSessionMac(secret, id))
+}
+
+// verifyDropletSession returns the session id of a token signed with secret
+func verifyDropletSession(secret []byte, token string) (string, bool) {
+	id, sig, ok := strings.Cut(token, ".")
+	if !ok || len(id) == 0 {
+		return "", false
+	}
+	mac, err := base64.RawURLEncoding.DecodeString(sig)
+	if err != nil || !hmac.Equal(mac, dropletSessionMac(secret, id)) {
+		return "", false
+	}
+	return id, true
+}
+
+func dropletSessionMac(secret []byte, id string) []byte {
+	mac := hmac.New(sha256.New, secret)
+	fmt.Fprintf(mac, "session|%s", id)
+	return mac.Sum(nil)
+}
+
+// setDropletSession hands the session token back as a cookie, clients that
+// can't keep cookies across origins pass the session field of the response
+func setDropletSession(c *gin.Context, token string) {
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(dropletSessionCookie, token, dropletSessionMaxAge, "/", "", c.Request.TLS != nil, true)
+}
+
+// dropletDrawRand returns the source of the draw of user in a round and slot,
+// the same one until the slot changes
+func dropletDrawRand(secret []byte, campaignId int64, round uint8, slot uint64, user string) *mathrand.Rand {
+	mac := hmac.New(sha256.New, secret)
+	fmt.Fprintf(mac, "%d|%d|%d|%s", campaignId, round, slot, user)
+	seed := binary.BigEndian.Uint64(mac.Sum(nil))
+	return mathrand.New(mathrand.NewSource(int64(seed)))
+}
@@ -0,0 +1,141 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"invite-code-service/dao"
+	"invite-code-service/pkg/config"
+	"invite-code-service/pkg/utils"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDropletDrawRand(t *testing.T) {
+	draw := func(secret string, round uint8, slot uint64, user string) int64 {
+		return dropletDrawRand([]byte(secret), dao.DefaultCampaignId, round, slot, user).Int63()
+	}
+	base := draw("seed", 1, 100, "session:a")
+	if again := draw("seed", 1, 100, "session:a"); again != base {
+		t.Fatalf("expect the same draw, got %d and %d", base, again)
+	}
+	for _, other := range []int64{
+		draw("other", 1, 100, "session:a"),
+		draw("seed", 2, 100, "session:a"),
+		draw("seed", 1, 101, "session:a"),
+		draw("seed", 1, 100, "session:b"),
+	} {
+		if other == base {
+			t.Fatalf("expect another draw than %d", base)
+		}
+	}
+
+	h := NewHandler(dao.NewMemStore(), &config.ConfigApi{DropletReveal: config.DropletReveal{DrawSlot: time.Minute}})
+	slot, next := h.dropletDrawSlot(time.Unix(125, 0))
+	if slot != 2 || next != 180 {
+		t.Fatalf("expect slot 2 until 180, got %d until %d", slot, next)
+	}
+}
+
+func TestGetDropletsSameDraw(t *testing.T) {
+	store := dao.NewMemStore()
+	// one slot for the whole test
+	cfg := &config.ConfigApi{DropletReveal: config.DropletReveal{DrawSecret: "seed", DrawSlot: 100 * 365 * 24 * time.Hour, Tiers: []config.RevealTier{
+		{Name: defaultRevealTier, Counts: []config.RevealCount{{Min: 0, Max: 5, Weight: 1}}},
+	}}}
+	router := InitRouters(store, cfg)
+	if _, err := store.GenerateInviteCodes(dao.GenerateSpec{CodeType: dao.WaterInviteCode, Count: 25}, dao.SystemEventMeta); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.OpenDropletRound(dao.DefaultCampaignId, 0, dao.DropletShape{}); err != nil {
+		t.Fatal(err)
+	}
+
+	get := func(query, cookie, ip string) RspDroplets {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/invite/droplets"+query, nil)
+		if len(cookie) > 0 {
+			req.AddCookie(&http.Cookie{Name: dropletSessionCookie, Value: cookie})
+		}
+		req.RemoteAddr = ip + ":1234"
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		droplets := RspDroplets{}
+		rsp := utils.Rsp{Data: &droplets}
+		if err := json.Unmarshal(w.Body.Bytes(), &rsp); err != nil {
+			t.Fatal(err)
+		}
+		if rsp.Status != "80000" {
+			t.Fatalf("unexpected rsp: %s", w.Body.String())
+		}
+		if cookies := w.Result().Cookies(); len(cookies) != 1 || cookies[0].Value != droplets.Session {
+			t.Fatalf("expect the session %s set as cookie, got %v", droplets.Session, cookies)
+		}
+		return droplets
+	}
+	draw := func(droplets RspDroplets) string {
+		s := ""
+		for _, d := range droplets.Droplets {
+			s += d.InviteCode + ","
+		}
+		return s
+	}
+
+	first := get("", "", "10.0.0.1")
+	if first.NextDrawTime <= uint64(time.Now().Unix()) {
+		t.Fatalf("expect the next draw later, got %d", first.NextDrawTime)
+	}
+	// refreshing, with or without the session, shows the same droplets
+	for _, tc := range []struct{ query, cookie string }{
+		{"", ""},
+		{"", first.Session},
+		{"?session=" + first.Session, ""},
+		{"?session=forged", ""},
+		{"?address=0xabc", ""},
+	} {
+		for i := 0; i < 10; i++ {
+			again := get(tc.query, tc.cookie, "10.0.0.1")
+			if draw(again) != draw(first) || again.Session != first.Session {
+				t.Fatalf("%+v: expect the same draw %s, got %s", tc, draw(first), draw(again))
+			}
+		}
+	}
+	// the session keeps its draw from another ip, a forged one doesn't
+	if again := get("", first.Session, "10.0.0.2"); draw(again) != draw(first) || again.Session != first.Session {
+		t.Fatalf("expect the draw of the session, got %s", draw(again))
+	}
+	id, _, _ := strings.Cut(first.Session, ".")
+	if again := get("", id+".AAAA", "10.0.0.2"); again.Session == first.Session {
+		t.Fatal("expect a forged session replaced")
+	}
+
+	// clients draw apart
+	draws := map[string]bool{}
+	for i := 0; i < 20; i++ {
+		draws[draw(get("", "", fmt.Sprintf("10.0.1.%d", i)))] = true
+	}
+	if len(draws) < 2 {
+		t.Fatalf("expect clients to draw apart, got %v", draws)
+	}
+}
+
+func TestDropletSession(t *testing.T) {
+	secret := []byte("seed")
+	id := dropletSessionId(secret, "10.0.0.1")
+	if id != dropletSessionId(secret, "10.0.0.1") || id == dropletSessionId(secret, "10.0.0.2") {
+		t.Fatalf("expect the session id to follow the ip, got %s", id)
+	}
+	token := signDropletSession(secret, id)
+	if got, ok := verifyDropletSession(secret, token); !ok || got != id {
+		t.Fatalf("expect session %s, got %s %v", id, got, ok)
+	}
+	for _, bad := range []string{"", id, "." + token[len(id)+1:], "x" + token, token[:len(token)-1]} {
+		if _, ok := verifyDropletSession(secret, bad); ok {
+			t.Fatalf("expect %q refused", bad)
+		}
+	}
+	if _, ok := verifyDropletSession([]byte("other"), token); ok {
+		t.Fatal("expect a session of another secret refused")
+	}
+}
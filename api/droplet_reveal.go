@@ -0,0 +1,108 @@
+package api
+
+import (
+	"fmt"
+	"invite-code-service/pkg/config"
+	"invite-code-service/pkg/utils"
+	"math/rand"
+	"regexp"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// defaultRevealTier is the tier of requests without a tier token
+const defaultRevealTier = "default"
+
+// defaultRevealTiers are used when no tier is configured
+var defaultRevealTiers = []config.RevealTier{
+	{Name: defaultRevealTier, Counts: []config.RevealCount{{Min: 0, Max: 3, Weight: 80}, {Min: 4, Max: 5, Weight: 20}}},
+	{Name: "sp", Counts: []config.RevealCount{{Min: 3, Max: 5, Weight: 1}}},
+}
+
+var revealTierName = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// DropletReveal returns the configured droplet reveal with the default tiers if none is set
+func DropletReveal(cfg *config.ConfigApi) config.DropletReveal {
+	reveal := cfg.DropletReveal
+	if len(reveal.Tiers) == 0 {
+		reveal.Tiers = defaultRevealTiers
+	}
+	return reveal
+}
+
+// CheckDropletReveal reports a missing draw secret and configured tiers that
+// can't be drawn from
+func CheckDropletReveal(cfg *config.ConfigApi) error {
+	reveal := DropletReveal(cfg)
+	if len(reveal.DrawSecret) == 0 {
+		return fmt.Errorf("droplet reveal: DrawSecret empty, it seeds the draws and signs the draw sessions")
+	}
+	hasDefault := false
+	for _, tier := range reveal.Tiers {
+		if !revealTierName.MatchString(tier.Name) {
+			return fmt.Errorf("droplet reveal tier %q: name not of letters, digits, '_' and '-'", tier.Name)
+		}
+		if tier.Name == defaultRevealTier && len(tier.Rounds) == 0 {
+			hasDefault = true
+		}
+		var total uint
+		for _, count := range tier.Counts {
+			if count.Min > count.Max {
+				return fmt.Errorf("droplet reveal tier %s: min %d over max %d", tier.Name, count.Min, count.Max)
+			}
+			total += count.Weight
+		}
+		if total == 0 {
+			return fmt.Errorf("droplet reveal tier %s: no weight", tier.Name)
+		}
+	}
+	if !hasDefault {
+		return fmt.Errorf("droplet reveal: no %s tier for every round", defaultRevealTier)
+	}
+	return nil
+}
+
+// sampleRevealCount draws how many droplets to show from the counts of tier
+func sampleRevealCount(tier *config.RevealTier, r *rand.Rand) int {
+	var total uint
+	for _, count := range tier.Counts {
+		total += count.Weight
+	}
+	if total == 0 {
+		return 0
+	}
+	n := uint(r.Int63n(int64(total)))
+	for _, count := range tier.Counts {
+		if n < count.Weight {
+			return int(count.Min) + r.Intn(int(count.Max-count.Min)+1)
+		}
+		n -= count.Weight
+	}
+	return 0
+}
+
+// getRevealTierName returns the tier of the tier_token of a request, the
+// default tier without one
+func (h *Handler) getRevealTierName(c *gin.Context) (string, bool) {
+	token := c.Query("tier_token")
+	if len(token) == 0 {
+		return defaultRevealTier, true
+	}
+	tier, err := utils.VerifyTierToken(h.cfg.DropletReveal.TierSecret, token, uint64(time.Now().Unix()))
+	if err != nil {
+		utils.Err(c, codeTierTokenErr, err.Error())
+		return "", false
+	}
+	return tier, true
+}
+
+// revealTier returns the tier called name for round, the default one if a
+// token outlived its tier
+func (h *Handler) revealTier(name string, round uint8) *config.RevealTier {
+	reveal := DropletReveal(h.cfg)
+	if tier := reveal.Tier(name, round); tier != nil {
+		return tier
+	}
+	return reveal.Tier(defaultRevealTier, round)
+}
@@ -0,0 +1,145 @@
+package api
+
+import (
+	"encoding/json"
+	"invite-code-service/dao"
+	"invite-code-service/pkg/config"
+	"invite-code-service/pkg/utils"
+	"math"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestSampleRevealCountDistribution(t *testing.T) {
+	for _, tier := range append([]config.RevealTier{
+		{Name: "skewed", Counts: []config.RevealCount{{Min: 0, Max: 0, Weight: 1}, {Min: 1, Max: 4, Weight: 6}, {Min: 2, Max: 3, Weight: 3}}},
+	}, defaultRevealTiers...) {
+		// the chance of each count from the configured weights
+		want := map[int]float64{}
+		var total float64
+		for _, c := range tier.Counts {
+			total += float64(c.Weight)
+		}
+		for _, c := range tier.Counts {
+			for n := int(c.Min); n <= int(c.Max); n++ {
+				want[n] += float64(c.Weight) / total / float64(c.Max-c.Min+1)
+			}
+		}
+
+		const samples = 200000
+		r := rand.New(rand.NewSource(1))
+		got := map[int]int{}
+		for i := 0; i < samples; i++ {
+			got[sampleRevealCount(&tier, r)]++
+		}
+
+		// every count within 4 standard deviations of its expected frequency
+		for n, p := range want {
+			expect := p * samples
+			if diff := math.Abs(float64(got[n]) - expect); diff > 4*math.Sqrt(expect*(1-p)) {
+				t.Fatalf("tier %s: count %d drawn %d times, expect about %.0f", tier.Name, n, got[n], expect)
+			}
+		}
+		for n := range got {
+			if _, ok := want[n]; !ok {
+				t.Fatalf("tier %s: drew count %d out of its ranges", tier.Name, n)
+			}
+		}
+	}
+}
+
+func TestCheckDropletReveal(t *testing.T) {
+	if err := CheckDropletReveal(&config.ConfigApi{DropletReveal: config.DropletReveal{DrawSecret: "seed"}}); err != nil {
+		t.Fatalf("expect the default tiers valid, got %v", err)
+	}
+	if err := CheckDropletReveal(&config.ConfigApi{}); err == nil {
+		t.Fatal("expect an empty draw secret rejected")
+	}
+	counts := []config.RevealCount{{Min: 1, Max: 2, Weight: 1}}
+	for _, tiers := range [][]config.RevealTier{
+		{{Name: "vip", Counts: counts}},
+		{{Name: defaultRevealTier, Rounds: []uint8{1}, Counts: counts}},
+		{{Name: defaultRevealTier, Counts: []config.RevealCount{{Min: 1, Max: 2}}}},
+		{{Name: defaultRevealTier, Counts: []config.RevealCount{{Min: 3, Max: 2, Weight: 1}}}},
+		{{Name: defaultRevealTier, Counts: counts}, {Name: "a.b", Counts: counts}},
+	} {
+		if err := CheckDropletReveal(&config.ConfigApi{DropletReveal: config.DropletReveal{DrawSecret: "seed", Tiers: tiers}}); err == nil {
+			t.Fatalf("expect %+v rejected", tiers)
+		}
+	}
+}
+
+func TestGetDropletsRevealTier(t *testing.T) {
+	store := dao.NewMemStore()
+	cfg := &config.ConfigApi{
+		AdminApiKeys: []config.AdminApiKey{{Name: "ops", Key: "secret"}},
+		DropletReveal: config.DropletReveal{TierSecret: "tiers", Tiers: []config.RevealTier{
+			{Name: defaultRevealTier, Counts: []config.RevealCount{{Min: 1, Max: 1, Weight: 1}}},
+			{Name: "all", Counts: []config.RevealCount{{Min: 9, Max: 9, Weight: 1}}},
+			{Name: "all", Rounds: []uint8{1}, Counts: []config.RevealCount{{Min: 2, Max: 2, Weight: 1}}},
+		}},
+	}
+	router := InitRouters(store, cfg)
+
+	do := func(method, path string, body string, data any) string {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set(headerApiKey, "secret")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		rsp := utils.Rsp{Data: data}
+		if err := json.Unmarshal(w.Body.Bytes(), &rsp); err != nil {
+			t.Fatal(err)
+		}
+		return rsp.Status
+	}
+	shown := func(query string) int {
+		droplets := RspDroplets{}
+		if status := do(http.MethodGet, "/api/v1/invite/droplets"+query, "", &droplets); status != "80000" {
+			t.Fatalf("unexpected status: %s", status)
+		}
+		return len(droplets.Droplets)
+	}
+
+	if _, err := store.GenerateInviteCodes(dao.GenerateSpec{CodeType: dao.WaterInviteCode, Count: 25}, dao.SystemEventMeta); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.OpenDropletRound(dao.DefaultCampaignId, 0, dao.DropletShape{}); err != nil {
+		t.Fatal(err)
+	}
+
+	token := RspAdminTierToken{}
+	if status := do(http.MethodPost, "/api/admin/v1/droplet/tier/token", `{"tier":"all"}`, &token); status != "80000" {
+		t.Fatalf("unexpected status: %s", status)
+	}
+	if status := do(http.MethodPost, "/api/admin/v1/droplet/tier/token", `{"tier":"sp"}`, nil); status != codeParamErr {
+		t.Fatalf("expect unknown tier %s, got %s", codeParamErr, status)
+	}
+
+	if n := shown(""); n != 1 {
+		t.Fatalf("expect the default tier to show 1 droplet, got %d", n)
+	}
+	// clamped to the droplets of the round
+	if n := shown("?tier_token=" + token.Token); n != 5 {
+		t.Fatalf("expect every droplet shown, got %d", n)
+	}
+	if _, err := store.OpenDropletRound(dao.DefaultCampaignId, 1, dao.DropletShape{}); err != nil {
+		t.Fatal(err)
+	}
+	if n := shown("?tier_token=" + token.Token); n != 2 {
+		t.Fatalf("expect the round 1 tier to show 2 droplets, got %d", n)
+	}
+	// a token of a tier no longer configured gets the default one
+	if n := shown("?tier_token=" + utils.SignTierToken("tiers", "gone", 0)); n != 1 {
+		t.Fatalf("expect the default tier, got %d", n)
+	}
+
+	for _, bad := range []string{"sp", utils.SignTierToken("other", "all", 0), utils.SignTierToken("tiers", "all", 1)} {
+		if status := do(http.MethodGet, "/api/v1/invite/droplets?tier_token="+bad, "", nil); status != codeTierTokenErr {
+			t.Fatalf("expect %s for %s, got %s", codeTierTokenErr, bad, status)
+		}
+	}
+}
@@ -3,7 +3,6 @@ package api
 import (
 	"invite-code-service/dao"
 	"invite-code-service/pkg/utils"
-	"math/rand"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -12,6 +11,14 @@ import (
 
 type RspDroplets struct {
 	Droplets []Droplet `json:"droplets"`
+	// NextRoundStartTime is the start time of the next scheduled round when no
+	// droplet has a code left, 0 if none is scheduled
+	NextRoundStartTime uint64 `json:"next_round_start_time"`
+	// NextDrawTime is when the droplets shown are drawn again, 0 if no round is open
+	NextDrawTime uint64 `json:"next_draw_time"`
+	// Session is the signed session the draw is for, to pass back as the session
+	// param where the droplet_session cookie isn't kept, empty if no round is open
+	Session string `json:"session"`
 }
 
 type Droplet struct {
@@ -22,96 +29,114 @@ type Droplet struct {
 }
 
 // @Summary get droplets
-// @Description get droplets
+// @Description get droplets of the open round. When none has a code left, next_round_start_time
+// @Description is when the next scheduled round opens, for a countdown. The droplets shown are
+// @Description drawn once per user and time slot, next_draw_time is when the slot ends. The user is the
+// @Description session of the droplet_session cookie or session param, issued from the client ip without one.
 // @Tags v1
 // @Accept json
 // @Produce json
-// @Param droplet query string false "droplet"
+// @Param campaign query string false "campaign name, the default campaign if empty"
+// @Param session query string false "session of an earlier response, used without the droplet_session cookie"
+// @Param tier_token query string false "signed tier token selecting the droplets shown, the default tier if empty"
 // @Success 200 {object} utils.Rsp{data=RspDroplets}
 // @Router /v1/invite/droplets [get]
 func (h *Handler) GetDroplets(c *gin.Context) {
-	droplet := c.Query("droplet")
-	dropletCodes, err := dao.GetLatestDropletCodesWithStatus(h.db)
+	campaign, ok := h.getCampaign(c)
+	if !ok {
+		return
+	}
+	tierName, ok := h.getRevealTierName(c)
+	if !ok {
+		return
+	}
+	round, dropletCodes, err := h.store.GetLatestDropletCodesWithStatus(campaign.ID)
 	if err != nil {
 		utils.Err(c, codeInternalErr, err.Error())
 		logrus.Errorf("GetWaterRotations err %s", err)
 		return
 	}
 
-	rsp := ConvertToRspDroplets(dropletCodes)
-
-	r := rand.New(rand.NewSource(time.Now().UnixNano()))
-	r.Shuffle(len(rsp.Droplets), func(i, j int) {
-		rsp.Droplets[i], rsp.Droplets[j] = rsp.Droplets[j], rsp.Droplets[i]
-	})
-
-	if droplet == "sp" {
-		// [3,5]
-		count := len(rsp.Droplets)
-		if count > 5 {
-			count = rand.Intn(3) + 3
-		} else if count >= 3 {
-			count = rand.Intn(count-2) + 3
+	rsp := ConvertToRspDroplets(round, dropletCodes)
+	if !hasAvailableDroplet(rsp.Droplets) {
+		rsp.NextRoundStartTime, err = h.nextDropletRoundStart(campaign)
+		if err != nil {
+			utils.Err(c, codeInternalErr, err.Error())
+			logrus.Errorf("nextDropletRoundStart err %s", err)
+			return
 		}
-		rsp.Droplets = rsp.Droplets[:count]
-	} else {
-		// [0,3] 80%
-		// [4,5] 20%
-		var finalCount int
-		p := rand.Float64()
-
-		if p < 0.8 {
-			max := min(3, len(rsp.Droplets))
-
-			finalCount = rand.Intn(max + 1)
-		} else {
-			minCount := min(4, len(rsp.Droplets))
-			maxCount := min(5, len(rsp.Droplets))
-
-			finalCount = rand.Intn(maxCount-minCount+1) + minCount
+	}
 
-		}
-		rsp.Droplets = rsp.Droplets[:finalCount]
+	if round != nil {
+		// the same user gets the same draw until the slot ends, refreshing doesn't redraw
+		slot, next := h.dropletDrawSlot(time.Now())
+		user, session := h.dropletDrawUser(c)
+		r := dropletDrawRand([]byte(h.cfg.DropletReveal.DrawSecret), campaign.ID, round.Round, slot, user)
+		r.Shuffle(len(rsp.Droplets), func(i, j int) {
+			rsp.Droplets[i], rsp.Droplets[j] = rsp.Droplets[j], rsp.Droplets[i]
+		})
+		count := sampleRevealCount(h.revealTier(tierName, round.Round), r)
+		rsp.Droplets = rsp.Droplets[:min(count, len(rsp.Droplets))]
+		rsp.NextDrawTime = next
+		rsp.Session = session
+		setDropletSession(c, session)
 	}
 
 	utils.Ok(c, rsp)
 
 }
 
-func ConvertToRspDroplets(data []*dao.DropletCodeWithStatus) RspDroplets {
-	// key: round + dropletIndex
-	type key struct {
-		Round        uint8
-		DropletIndex uint8
+func hasAvailableDroplet(droplets []Droplet) bool {
+	for _, d := range droplets {
+		if d.AvailableCount > 0 {
+			return true
+		}
 	}
+	return false
+}
 
-	groupMap := make(map[key][]*dao.DropletCodeWithStatus)
-
-	for _, item := range data {
-		k := key{Round: item.Round, DropletIndex: item.DropletIndex}
-		groupMap[k] = append(groupMap[k], item)
+// nextDropletRoundStart is the start time of the scheduled round after the
+// last round of a campaign, 0 if there is none
+func (h *Handler) nextDropletRoundStart(campaign *dao.Campaign) (uint64, error) {
+	schedule := h.cfg.CampaignDropletSchedule(campaign.Name)
+	if len(schedule.Rounds) == 0 {
+		return 0, nil
+	}
+	rounds, err := h.store.GetDropletRounds(campaign.ID)
+	if err != nil {
+		return 0, err
+	}
+	last := -1
+	if len(rounds) > 0 {
+		last = int(rounds[len(rounds)-1].Round)
+	}
+	next := schedule.Next(last, uint64(time.Now().Unix()))
+	if next == nil {
+		return 0, nil
 	}
+	return next.StartTime, nil
+}
 
-	var droplets []Droplet
-	for k, list := range groupMap {
-		var availableCount uint64
-		var selectedCode string
+// ConvertToRspDroplets groups the codes of round into the droplets of its
+// shape, in droplet index order
+func ConvertToRspDroplets(round *dao.DropletRound, data []*dao.DropletCodeWithStatus) RspDroplets {
+	if round == nil {
+		return RspDroplets{}
+	}
 
-		for _, d := range list {
-			if !d.Used {
-				availableCount++
-				if selectedCode == "" {
-					selectedCode = d.InviteCode
-				}
-			}
+	droplets := make([]Droplet, round.Droplets)
+	for i := range droplets {
+		droplets[i] = Droplet{TotalCount: uint64(round.CodesPerDroplet), Round: round.Round}
+	}
+	for _, d := range data {
+		if int(d.DropletIndex) >= len(droplets) || d.Used || d.Expired {
+			continue
+		}
+		droplet := &droplets[d.DropletIndex]
+		droplet.AvailableCount++
+		if droplet.InviteCode == "" {
+			droplet.InviteCode = d.InviteCode
 		}
-
-		droplets = append(droplets, Droplet{
-			TotalCount:     uint64(len(list)),
-			AvailableCount: availableCount,
-			Round:          k.Round,
-			InviteCode:     selectedCode,
-		})
 	}
 
 	return RspDroplets{Droplets: droplets}
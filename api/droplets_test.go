@@ -0,0 +1,51 @@
+package api
+
+import (
+	"encoding/json"
+	"invite-code-service/dao"
+	"invite-code-service/pkg/config"
+	"invite-code-service/pkg/utils"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestGetDropletsNextRoundStart(t *testing.T) {
+	store := dao.NewMemStore()
+	start := uint64(time.Now().Add(time.Hour).Unix())
+	cfg := &config.ConfigApi{DropletSchedule: config.DropletSchedule{Rounds: []config.ScheduledRound{
+		{Round: 0, StartTime: 1, EndTime: 2},
+		{Round: 1, StartTime: start},
+	}}}
+	router := InitRouters(store, cfg)
+
+	get := func() RspDroplets {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/invite/droplets", nil))
+		droplets := RspDroplets{}
+		rsp := utils.Rsp{Data: &droplets}
+		if err := json.Unmarshal(w.Body.Bytes(), &rsp); err != nil {
+			t.Fatal(err)
+		}
+		if rsp.Status != "80000" {
+			t.Fatalf("unexpected rsp: %s", w.Body.String())
+		}
+		return droplets
+	}
+
+	if droplets := get(); droplets.NextRoundStartTime != start {
+		t.Fatalf("expect next round at %d, got %+v", start, droplets)
+	}
+
+	if _, err := store.GenerateInviteCodes(dao.GenerateSpec{CodeType: dao.WaterInviteCode, Count: 25}, dao.SystemEventMeta); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.OpenDropletRound(dao.DefaultCampaignId, 1, dao.DropletShape{}); err != nil {
+		t.Fatal(err)
+	}
+	// the last scheduled round is open with codes left
+	if droplets := get(); droplets.NextRoundStartTime != 0 {
+		t.Fatalf("expect no next round, got %+v", droplets)
+	}
+}
@@ -30,6 +30,7 @@ type RspGen struct {
 // @Tags v1
 // @Accept json
 // @Produce json
+// @Param campaign query string false "campaign name, the default campaign if empty"
 // @Param param body ReqGen true "gen"
 // @Success 200 {object} utils.Rsp{data=RspGen}
 // @Router /v1/invite/genInviteCode [post]
@@ -47,10 +48,19 @@ func (h *Handler) HandlePostGenInviteCode(c *gin.Context) {
 	}
 	req.UserAddress = strings.ToLower(req.UserAddress)
 
+	campaign, ok := h.getCampaign(c)
+	if !ok {
+		return
+	}
+	if !campaign.IsOpen(uint64(time.Now().Unix())) {
+		utils.Err(c, codeCampaignNotOpenErr, "")
+		return
+	}
+
 	sigBts := common.FromHex(req.Signature)
 	userAddress := common.HexToAddress(req.UserAddress)
 
-	_, err = dao.GetInviteCodeByUserAddress(h.db, req.UserAddress)
+	_, err = h.store.GetInviteCodeByUserAddress(campaign.ID, req.UserAddress)
 	if err != nil {
 		if err != gorm.ErrRecordNotFound {
 			utils.Err(c, codeInternalErr, err.Error())
@@ -78,13 +88,13 @@ func (h *Handler) HandlePostGenInviteCode(c *gin.Context) {
 	}
 
 	// check task
-	tasks, err := h.getTasks()
+	tasks, err := h.getTasks(campaign)
 	if err != nil {
 		utils.Err(c, codeInternalErr, err.Error())
 		logrus.Errorf("getTasks err %s", err)
 		return
 	}
-	userTasks, err := h.getUserTasks(req.UserAddress)
+	userTasks, err := h.getUserTasks(campaign, req.UserAddress)
 	if err != nil {
 		utils.Err(c, codeInternalErr, err.Error())
 		logrus.Errorf("getUserTasks err %s", err)
@@ -96,21 +106,7 @@ func (h *Handler) HandlePostGenInviteCode(c *gin.Context) {
 		return
 	}
 
-	// bind task
-	inviteCode, err := dao.GetAvailableTaskInviteCode(h.db)
-	if err != nil {
-		if err != gorm.ErrRecordNotFound {
-			utils.Err(c, codeInternalErr, err.Error())
-			logrus.Errorf("GetAvailableTaskInviteCode err %s", err)
-			return
-		}
-
-		utils.Err(c, codeInviteCodeNotEnoughErr, err.Error())
-		logrus.Errorf("GetAvailableTaskInviteCode err %s", err)
-		return
-	}
-
-	userInfo, err := h.getUserInfo(req.UserAddress)
+	userInfo, err := h.getUserInfo(campaign, req.UserAddress)
 	if err != nil {
 		utils.Err(c, codeInternalErr, err.Error())
 		logrus.Errorf("getUserInfo err %s", err)
@@ -129,7 +125,7 @@ func (h *Handler) HandlePostGenInviteCode(c *gin.Context) {
 		return
 	}
 
-	_, err = dao.GetInviteCodeByDiscordId(h.db, userInfo.DiscordID)
+	_, err = h.store.GetInviteCodeByDiscordId(campaign.ID, userInfo.DiscordID)
 	if err != nil {
 		if err != gorm.ErrRecordNotFound {
 			utils.Err(c, codeInternalErr, err.Error())
@@ -142,21 +138,32 @@ func (h *Handler) HandlePostGenInviteCode(c *gin.Context) {
 		return
 	}
 
-	inviteCode.UserId = &userInfo.ID
-	inviteCode.DiscordId = &userInfo.DiscordID
-	inviteCode.DiscordName = &userInfo.DiscordHandle
-	inviteCode.UserAddress = &req.UserAddress
-	inviteCode.BindTime = uint64(time.Now().Unix())
-
-	err = dao.CheckBondAndUpdateInviteCode(h.db, inviteCode)
+	meta := dao.EventMeta{
+		Actor:       req.UserAddress,
+		SourceIp:    c.ClientIP(),
+		PayloadHash: utils.HashPayload(req),
+	}
+	// bind task, a code drawn by a concurrent request too is redrawn
+	inviteCode, err := h.store.ClaimTaskInviteCode(campaign.ID, dao.TaskCodeClaim{
+		UserAddress: req.UserAddress,
+		DiscordId:   userInfo.DiscordID,
+		DiscordName: userInfo.DiscordHandle,
+		UserId:      userInfo.ID,
+	}, meta, ReferralSpec(h.cfg, campaign.ID))
 	if err != nil {
-		if errors.Is(err, dao.ErrAlreadyBond) {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			utils.Err(c, codeInviteCodeNotEnoughErr, err.Error())
+			logrus.Errorf("ClaimTaskInviteCode err %s", err)
+			return
+		}
+		// the same user or discord id bound by a concurrent request
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
 			utils.Err(c, codeUserAlreadyBoundErr, "")
 			return
 		}
 
 		utils.Err(c, codeInternalErr, err.Error())
-		logrus.Errorf("UpOrInInviteCode err %s", err)
+		logrus.Errorf("ClaimTaskInviteCode err %s", err)
 		return
 	}
 
@@ -0,0 +1,114 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"invite-code-service/dao"
+	"invite-code-service/pkg/config"
+	"invite-code-service/pkg/db"
+	"invite-code-service/pkg/utils"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	ethCrypto "github.com/ethereum/go-ethereum/crypto"
+	"github.com/gin-gonic/gin"
+	"github.com/patrickmn/go-cache"
+)
+
+// TestGenInviteCodeConcurrent checks that concurrent requests each get a
+// distinct code. It runs on a sqlite file with several connections so the
+// requests bind in parallel, whether two of them draw the same code is up to
+// timing, the redraw is pinned by the dao claim tests.
+func TestGenInviteCodeConcurrent(t *testing.T) {
+	const users = 300
+	wrapDb, err := db.NewDB(&db.Config{Dialect: db.DialectSqlite, Path: filepath.Join(t.TempDir(), "gen.db"), Mode: "silent", MaxOpenConns: 8})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := dao.MigrateLatest(wrapDb); err != nil {
+		t.Fatal(err)
+	}
+	store := dao.NewDbStore(wrapDb)
+	if _, err := store.GenerateInviteCodes(dao.GenerateSpec{CodeType: dao.TaskInviteCode, Count: users}, dao.SystemEventMeta); err != nil {
+		t.Fatal(err)
+	}
+
+	handler := NewHandler(store, &config.ConfigApi{})
+	gin.SetMode(gin.ReleaseMode)
+	router := gin.New()
+	router.POST("/api/v1/invite/genInviteCode", handler.HandlePostGenInviteCode)
+
+	// zealy answers come from the cache
+	handler.cache.Set(taskKey(""), utils.QuestResponse{{ID: "q1", Published: true, Tasks: []utils.TaskDetail{{Type: "discord"}}}}, cache.NoExpiration)
+
+	reqs := make([][]byte, users)
+	for i := range reqs {
+		key, err := ethCrypto.GenerateKey()
+		if err != nil {
+			t.Fatal(err)
+		}
+		address := strings.ToLower(ethCrypto.PubkeyToAddress(key.PublicKey).Hex())
+		handler.cache.Set(userInfoKey("", address), &utils.UserResponse{
+			ID:            fmt.Sprintf("user%d", i),
+			DiscordID:     fmt.Sprintf("discord%d", i),
+			DiscordHandle: fmt.Sprintf("name%d", i),
+		}, cache.NoExpiration)
+		handler.cache.Set(userTaskKey("", address), &utils.ReviewResponse{
+			Items: []utils.ReviewItem{{Status: "success", Quest: utils.Quest{ID: "q1"}}},
+		}, cache.NoExpiration)
+
+		timestamp := uint64(time.Now().Unix())
+		message := utils.BuildGenMessage(timestamp)
+		hash := ethCrypto.Keccak256([]byte(fmt.Sprintf("\x19Ethereum Signed Message:\n%d%s", len(message), message)))
+		sig, err := ethCrypto.Sign(hash, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		reqs[i], _ = json.Marshal(ReqGen{UserAddress: address, Signature: hexutil.Encode(sig), Timestamp: timestamp})
+	}
+
+	rsps := make([]utils.Rsp, users)
+	var wg sync.WaitGroup
+	for i := range reqs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/invite/genInviteCode", bytes.NewReader(reqs[i]))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			rsps[i].Data = &RspGen{}
+			if err := json.Unmarshal(w.Body.Bytes(), &rsps[i]); err != nil {
+				t.Error(err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	codes := make(map[string]bool, users)
+	for i, rsp := range rsps {
+		if rsp.Status != "80000" {
+			t.Fatalf("request %d: unexpected rsp %+v", i, rsp)
+		}
+		code := rsp.Data.(*RspGen).InviteCode
+		if codes[code] {
+			t.Fatalf("code %s given out twice", code)
+		}
+		codes[code] = true
+	}
+
+	stats, err := store.GetTaskInviteCodeStats(dao.DefaultCampaignId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.RemainCodes != 0 || stats.Redemptions != users {
+		t.Fatalf("unexpected stats: %+v", stats)
+	}
+}
@@ -1,14 +1,17 @@
 package api
 
 import (
+	"errors"
 	"fmt"
+	"invite-code-service/dao"
 	"invite-code-service/pkg/config"
-	"invite-code-service/pkg/db"
 	"invite-code-service/pkg/utils"
 	"time"
 
+	"github.com/gin-gonic/gin"
 	"github.com/patrickmn/go-cache"
 	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
 )
 
 const (
@@ -22,35 +25,84 @@ const (
 	codeInviteCodeTypeNotMatchErr = "80008"
 	codeInviteCodeNotEnoughErr    = "80009"
 	codeDiscordAlreadyBoundErr    = "80010"
+	codeInviteCodeExpiredErr      = "80011"
+	codeInviteCodeNotYetValidErr  = "80012"
+	codeInviteCodeRevokedErr      = "80013"
+	codeInviteCodeNotBoundErr     = "80014"
+	codeCampaignNotExistErr       = "80015"
+	codeCampaignNotOpenErr        = "80016"
+	codeInviteCodeMalformedErr    = "80017"
+	codeBatchNotExistErr          = "80018"
+	codeInviteCodeMultiUseErr     = "80019"
+	codeNoOpenDropletRoundErr     = "80020"
+	codeTierTokenErr              = "80021"
 )
 
+// campaignParam selects the campaign of every /api/v1/invite route, empty means the default one
+const campaignParam = "campaign"
+
 const (
-	cacheKeyTask     = "cacheKeyTask"
-	cacheKeyUserInfo = "cacheKeyUserInfo_%s"
-	cacheKeyUserTask = "cacheKeyUserTask_%s"
+	cacheKeyTask     = "cacheKeyTask_%s"
+	cacheKeyUserInfo = "cacheKeyUserInfo_%s_%s"
+	cacheKeyUserTask = "cacheKeyUserTask_%s_%s"
 )
 
-func userInfoKey(addr string) string {
-	return fmt.Sprintf(cacheKeyUserInfo, addr)
+func taskKey(subdomain string) string {
+	return fmt.Sprintf(cacheKeyTask, subdomain)
+}
+func userInfoKey(subdomain, addr string) string {
+	return fmt.Sprintf(cacheKeyUserInfo, subdomain, addr)
 }
-func userTaskKey(addr string) string {
-	return fmt.Sprintf(cacheKeyUserTask, addr)
+func userTaskKey(subdomain, addr string) string {
+	return fmt.Sprintf(cacheKeyUserTask, subdomain, addr)
 }
 
 type Handler struct {
-	db    *db.WrapDb
+	store dao.Store
 	cfg   *config.ConfigApi
 	cache *cache.Cache
 }
 
-func NewHandler(db *db.WrapDb, cfg *config.ConfigApi) *Handler {
-	return &Handler{db: db, cfg: cfg, cache: cache.New(time.Minute*10, time.Minute*1)}
+func NewHandler(store dao.Store, cfg *config.ConfigApi) *Handler {
+	return &Handler{
+		store: store,
+		cfg:   cfg,
+		cache: cache.New(time.Minute*10, time.Minute*1),
+	}
+}
+
+// getCampaign resolves the campaign query param, on failure the error response is already written
+func (h *Handler) getCampaign(c *gin.Context) (*dao.Campaign, bool) {
+	name := c.Query(campaignParam)
+	if len(name) == 0 {
+		name = dao.DefaultCampaignName
+	}
+	campaign, err := h.store.GetCampaign(name)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			utils.Err(c, codeCampaignNotExistErr, "")
+			return nil, false
+		}
+		utils.Err(c, codeInternalErr, err.Error())
+		logrus.Errorf("GetCampaign err %s", err)
+		return nil, false
+	}
+	return campaign, true
+}
+
+// zealySubdomain is the zealy community of a campaign, the configured one by default
+func (h *Handler) zealySubdomain(campaign *dao.Campaign) string {
+	if len(campaign.ZealySubdomain) > 0 {
+		return campaign.ZealySubdomain
+	}
+	return h.cfg.ZealySubdomain
 }
 
-func (h *Handler) getTasks() ([]Task, error) {
-	cachedTask, found := h.cache.Get(cacheKeyTask)
+func (h *Handler) getTasks(campaign *dao.Campaign) ([]Task, error) {
+	subdomain := h.zealySubdomain(campaign)
+	cachedTask, found := h.cache.Get(taskKey(subdomain))
 	if !found {
-		res, err := utils.GetCommunityQuests(h.cfg.ZealyApiKey, h.cfg.ZealySubdomain)
+		res, err := utils.GetCommunityQuests(h.cfg.ZealyApiKey, subdomain)
 		if err != nil {
 			return nil, err
 
@@ -58,7 +110,7 @@ func (h *Handler) getTasks() ([]Task, error) {
 
 		cachedTask = res
 
-		h.cache.Set(cacheKeyTask, res, cache.DefaultExpiration)
+		h.cache.Set(taskKey(subdomain), res, cache.DefaultExpiration)
 	}
 
 	quests, ok := cachedTask.(utils.QuestResponse)
@@ -99,17 +151,18 @@ func questToTask(quests utils.QuestResponse) []Task {
 	return tasks
 }
 
-func (h *Handler) getUserInfo(address string) (*utils.UserResponse, error) {
-	cachedUserInfo, found := h.cache.Get(userInfoKey(address))
+func (h *Handler) getUserInfo(campaign *dao.Campaign, address string) (*utils.UserResponse, error) {
+	subdomain := h.zealySubdomain(campaign)
+	cachedUserInfo, found := h.cache.Get(userInfoKey(subdomain, address))
 	if !found {
-		user, err := utils.GetCommunityUser(h.cfg.ZealyApiKey, h.cfg.ZealySubdomain, address)
+		user, err := utils.GetCommunityUser(h.cfg.ZealyApiKey, subdomain, address)
 		if err != nil {
 			return nil, err
 		}
 
 		if len(user.DiscordID) > 0 {
 			cachedUserInfo = user
-			h.cache.Set(userInfoKey(address), user, cache.NoExpiration)
+			h.cache.Set(userInfoKey(subdomain, address), user, cache.NoExpiration)
 		}
 
 	}
@@ -126,22 +179,23 @@ func (h *Handler) getUserInfo(address string) (*utils.UserResponse, error) {
 	return user, nil
 }
 
-func (h *Handler) getUserTasks(address string) ([]Task, error) {
-	cachedUserTask, found := h.cache.Get(userTaskKey(address))
+func (h *Handler) getUserTasks(campaign *dao.Campaign, address string) ([]Task, error) {
+	subdomain := h.zealySubdomain(campaign)
+	cachedUserTask, found := h.cache.Get(userTaskKey(subdomain, address))
 	if !found {
-		userInfo, err := h.getUserInfo(address)
+		userInfo, err := h.getUserInfo(campaign, address)
 		if err != nil {
 			return nil, err
 		}
 
-		reviews, err := utils.GetCommunityReviews(h.cfg.ZealyApiKey, h.cfg.ZealySubdomain, userInfo.ID)
+		reviews, err := utils.GetCommunityReviews(h.cfg.ZealyApiKey, subdomain, userInfo.ID)
 		if err != nil {
 			return nil, err
 		}
 
 		cachedUserTask = reviews
 
-		h.cache.Set(userTaskKey(address), reviews, time.Second)
+		h.cache.Set(userTaskKey(subdomain, address), reviews, time.Second)
 	}
 
 	userTask, ok := cachedUserTask.(*utils.ReviewResponse)
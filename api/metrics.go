@@ -0,0 +1,20 @@
+package api
+
+import (
+	"expvar"
+
+	"github.com/gin-gonic/gin"
+)
+
+// @Summary runtime metrics
+// @Description The expvar variables of the server, invite_code_stock holds the remaining codes
+// @Description per campaign/type watched by the stock monitor, invite_code_low_stock is 1 while
+// @Description a pool is below its threshold and invite_code_stock_top_ups counts the codes it generated.
+// @Tags admin
+// @Produce json
+// @Security ApiKeyAuth
+// @Success 200 {object} map[string]interface{}
+// @Router /admin/v1/metrics [get]
+func (h *Handler) GetMetrics(c *gin.Context) {
+	expvar.Handler().ServeHTTP(c.Writer, c.Request)
+}
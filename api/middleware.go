@@ -1,11 +1,20 @@
 package api
 
 import (
-	"github.com/gin-gonic/gin"
-	"golang.org/x/time/rate"
+	"crypto/subtle"
+	"invite-code-service/pkg/config"
 	"net/http"
 	"sync"
 	"time"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/time/rate"
+)
+
+const (
+	headerApiKey     = "X-Api-Key"
+	ctxKeyAdminName  = "adminName"
+	adminActorPrefix = "admin:"
 )
 
 func Cors() gin.HandlerFunc {
@@ -13,7 +22,7 @@ func Cors() gin.HandlerFunc {
 		method := c.Request.Method
 
 		c.Header("Access-Control-Allow-Origin", "*")
-		c.Header("Access-Control-Allow-Headers", "Content-Type,AccessToken,X-CSRF-Token, Authorization, Token")
+		c.Header("Access-Control-Allow-Headers", "Content-Type,AccessToken,X-CSRF-Token, Authorization, Token, X-Api-Key")
 		c.Header("Access-Control-Allow-Methods", "POST, GET, OPTIONS")
 		c.Header("Access-Control-Expose-Headers", "Content-Length, Access-Control-Allow-Origin, Access-Control-Allow-Headers, Content-Type")
 		c.Header("Access-Control-Allow-Credentials", "true")
@@ -70,3 +79,26 @@ func MethodRateLimiter() gin.HandlerFunc {
 		c.Next()
 	}
 }
+
+// AdminAuth accepts requests carrying one of keys in the X-Api-Key header and
+// stores the key name for adminActor
+func AdminAuth(keys []config.AdminApiKey) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		apiKey := c.GetHeader(headerApiKey)
+		if len(apiKey) > 0 {
+			for _, k := range keys {
+				if len(k.Key) > 0 && subtle.ConstantTimeCompare([]byte(k.Key), []byte(apiKey)) == 1 {
+					c.Set(ctxKeyAdminName, k.Name)
+					c.Next()
+					return
+				}
+			}
+		}
+		c.AbortWithStatus(http.StatusUnauthorized)
+	}
+}
+
+// adminActor is the actor recorded for changes made through the admin routes
+func adminActor(c *gin.Context) string {
+	return adminActorPrefix + c.GetString(ctxKeyAdminName)
+}
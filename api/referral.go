@@ -0,0 +1,115 @@
+package api
+
+import (
+	"invite-code-service/dao"
+	"invite-code-service/pkg/config"
+	"invite-code-service/pkg/utils"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+type ReferralCode struct {
+	InviteCode string   `json:"invite_code"`
+	MaxUses    uint64   `json:"max_uses"`
+	UseCount   uint64   `json:"use_count"`
+	UsedBy     []string `json:"used_by"`
+}
+
+type RspReferralLeaderboard struct {
+	List []ReferralRank `json:"list"`
+}
+
+type ReferralRank struct {
+	InviterAddress string `json:"inviter_address"`
+	Invites        uint64 `json:"invites"`
+}
+
+// ReferralSpec describes the referral codes a user of the campaign is issued
+// when bound, nil if none are configured
+func ReferralSpec(cfg *config.ConfigApi, campaignId int64) *dao.GenerateSpec {
+	if cfg.ReferralCodeCount == 0 {
+		return nil
+	}
+	return &dao.GenerateSpec{
+		CampaignId: campaignId,
+		CodeType:   dao.ReferralInviteCode,
+		Count:      int64(cfg.ReferralCodeCount),
+		MaxUses:    cfg.ReferralCodeMaxUses,
+		NewCode:    CodeFormat(cfg, dao.ReferralInviteCode).Generate,
+	}
+}
+
+// getReferralCodes returns the referral codes of a bound user with who used
+// them. They are issued with the bind, users bound before are given theirs by
+// the issue-referrals command.
+func (h *Handler) getReferralCodes(campaignId int64, address string) ([]ReferralCode, error) {
+	codes, err := h.store.GetReferralCodes(campaignId, address)
+	if err != nil {
+		return nil, err
+	}
+
+	list := make([]ReferralCode, 0, len(codes))
+	for _, code := range codes {
+		redemptions, err := h.store.GetInviteCodeRedemptions(code.InviteCode)
+		if err != nil {
+			return nil, err
+		}
+		usedBy := make([]string, 0, len(redemptions))
+		for _, r := range redemptions {
+			if r.UserAddress != nil {
+				usedBy = append(usedBy, *r.UserAddress)
+			}
+		}
+		list = append(list, ReferralCode{
+			InviteCode: code.InviteCode,
+			MaxUses:    code.MaxUses,
+			UseCount:   code.UseCount,
+			UsedBy:     usedBy,
+		})
+	}
+	return list, nil
+}
+
+// @Summary get referral leaderboard
+// @Description inviters ranked by how many users redeemed their referral codes
+// @Tags v1
+// @Accept json
+// @Produce json
+// @Param campaign query string false "campaign name, the default campaign if empty"
+// @Param limit query int false "limit, default 10, max 50"
+// @Success 200 {object} utils.Rsp{data=RspReferralLeaderboard}
+// @Router /v1/invite/referralLeaderboard [get]
+func (h *Handler) GetReferralLeaderboard(c *gin.Context) {
+	limit := utils.DefaultPageSize
+	if limitStr := c.Query("limit"); len(limitStr) > 0 {
+		l, err := strconv.Atoi(limitStr)
+		if err != nil || l <= 0 {
+			utils.Err(c, codeParamErr, "invalid limit")
+			return
+		}
+		limit = min(l, utils.MaxPageSize)
+	}
+
+	campaign, ok := h.getCampaign(c)
+	if !ok {
+		return
+	}
+
+	ranks, err := h.store.GetReferralLeaderboard(campaign.ID, limit)
+	if err != nil {
+		utils.Err(c, codeInternalErr, err.Error())
+		logrus.Errorf("GetReferralLeaderboard err %s", err)
+		return
+	}
+
+	rsp := RspReferralLeaderboard{List: make([]ReferralRank, 0, len(ranks))}
+	for _, r := range ranks {
+		rsp.List = append(rsp.List, ReferralRank{
+			InviterAddress: r.InviterAddress,
+			Invites:        uint64(r.Invites),
+		})
+	}
+	utils.Ok(c, rsp)
+}
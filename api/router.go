@@ -1,8 +1,8 @@
 package api
 
 import (
+	"invite-code-service/dao"
 	"invite-code-service/pkg/config"
-	"invite-code-service/pkg/db"
 	"net/http"
 
 	_ "invite-code-service/docs"
@@ -12,7 +12,7 @@ import (
 	ginSwagger "github.com/swaggo/gin-swagger"
 )
 
-func InitRouters(db *db.WrapDb, cfg *config.ConfigApi) http.Handler {
+func InitRouters(store dao.Store, cfg *config.ConfigApi) http.Handler {
 	gin.SetMode(gin.ReleaseMode)
 	router := gin.Default()
 	router.MaxMultipartMemory = 8 << 20 // 8 MiB
@@ -21,13 +21,28 @@ func InitRouters(db *db.WrapDb, cfg *config.ConfigApi) http.Handler {
 
 	router.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
 
-	handler := NewHandler(db, cfg)
+	handler := NewHandler(store, cfg)
 	router.GET("/api/v1/invite/summary", handler.GetSummary)
 	router.GET("/api/v1/invite/userStatus", handler.GetUserStatus)
 	router.GET("/api/v1/invite/droplets", handler.GetDroplets)
+	router.GET("/api/v1/invite/referralLeaderboard", handler.GetReferralLeaderboard)
 
 	router.POST("/api/v1/invite/bind", handler.HandlePostBind)
 	router.POST("/api/v1/invite/genInviteCode", handler.HandlePostGenInviteCode)
 
+	admin := router.Group("/api/admin/v1", AdminAuth(cfg.AdminApiKeys))
+	admin.GET("/invite/codes", handler.GetAdminCodes)
+	admin.GET("/invite/code", handler.GetAdminCode)
+	admin.POST("/invite/bind", handler.HandlePostAdminBind)
+	admin.POST("/invite/rebind", handler.HandlePostAdminRebind)
+	admin.POST("/invite/unbind", handler.HandlePostAdminUnbind)
+	admin.GET("/droplet/rounds", handler.GetAdminDropletRounds)
+	admin.POST("/droplet/round/open", handler.HandlePostAdminOpenDropletRound)
+	admin.POST("/droplet/round/close", handler.HandlePostAdminCloseDropletRound)
+	admin.POST("/droplet/tier/token", handler.HandlePostAdminTierToken)
+	admin.GET("/batches", handler.GetAdminBatches)
+	admin.GET("/batch/stats", handler.GetAdminBatchStats)
+	admin.GET("/metrics", handler.GetMetrics)
+
 	return router
 }
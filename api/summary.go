@@ -11,11 +11,53 @@ import (
 type RspSummary struct {
 	TotalCodes         uint64 `json:"total_codes"`
 	RemainingCodes     uint64 `json:"remaining_codes"`
+	ExpiredCodes       uint64 `json:"expired_codes"`
 	TotalTaskCodes     uint64 `json:"total_task_codes"`
 	RemainingTaskCodes uint64 `json:"remaining_task_codes"`
+	ExpiredTaskCodes   uint64 `json:"expired_task_codes"`
+	Redemptions        uint64 `json:"redemptions"`
+	RemainingUses      uint64 `json:"remaining_uses"`
 	Tasks              []Task `json:"tasks"`
 }
 
+// BatchSummary is the summary of the codes of a batch, a code is bound once redeemed
+type BatchSummary struct {
+	Name           string  `json:"name"`
+	Owner          string  `json:"owner"`
+	Notes          string  `json:"notes"`
+	CreatedAt      uint64  `json:"created_at"`
+	TotalCodes     uint64  `json:"total_codes"`
+	BoundCodes     uint64  `json:"bound_codes"`
+	RemainingCodes uint64  `json:"remaining_codes"`
+	ExpiredCodes   uint64  `json:"expired_codes"`
+	RevokedCodes   uint64  `json:"revoked_codes"`
+	Redemptions    uint64  `json:"redemptions"`
+	BindRate       float64 `json:"bind_rate"` // bound_codes / total_codes
+}
+
+func toBatchSummary(b *dao.Batch, stats *dao.BatchStats) BatchSummary {
+	return BatchSummary{
+		Name:           b.Name,
+		Owner:          b.Owner,
+		Notes:          b.Notes,
+		CreatedAt:      uint64(b.CreatedAt),
+		TotalCodes:     uint64(stats.TotalCodes),
+		BoundCodes:     uint64(stats.BoundCodes),
+		RemainingCodes: uint64(stats.RemainCodes),
+		ExpiredCodes:   uint64(stats.ExpiredCodes),
+		RevokedCodes:   uint64(stats.RevokedCodes),
+		Redemptions:    uint64(stats.Redemptions),
+		BindRate:       bindRate(stats.BoundCodes, stats.TotalCodes),
+	}
+}
+
+func bindRate(bound, total int64) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(bound) / float64(total)
+}
+
 type Task struct {
 	Id          string `json:"id"`
 	Type        string `json:"type"`
@@ -28,23 +70,28 @@ type Task struct {
 // @Tags v1
 // @Accept json
 // @Produce json
+// @Param campaign query string false "campaign name, the default campaign if empty"
 // @Success 200 {object} utils.Rsp{data=RspSummary}
 // @Router /v1/invite/summary [get]
 func (h *Handler) GetSummary(c *gin.Context) {
-	tasks, err := h.getTasks()
+	campaign, ok := h.getCampaign(c)
+	if !ok {
+		return
+	}
+	tasks, err := h.getTasks(campaign)
 	if err != nil {
 		utils.Err(c, codeInternalErr, err.Error())
 		logrus.Errorf("getTasks err %s", err)
 		return
 	}
 
-	stats, err := dao.GetAllInviteCodeStats(h.db)
+	stats, err := h.store.GetAllInviteCodeStats(campaign.ID)
 	if err != nil {
 		utils.Err(c, codeInternalErr, err.Error())
 		logrus.Errorf("GetAllInviteCodeStats err %s", err)
 		return
 	}
-	taskStats, err := dao.GetTaskInviteCodeStats(h.db)
+	taskStats, err := h.store.GetTaskInviteCodeStats(campaign.ID)
 	if err != nil {
 		utils.Err(c, codeInternalErr, err.Error())
 		logrus.Errorf("GetTaskInviteCodeStats err %s", err)
@@ -54,8 +101,12 @@ func (h *Handler) GetSummary(c *gin.Context) {
 	utils.Ok(c, RspSummary{
 		TotalCodes:         uint64(stats.TotalCodes),
 		RemainingCodes:     uint64(stats.RemainCodes),
+		ExpiredCodes:       uint64(stats.ExpiredCodes),
 		TotalTaskCodes:     uint64(taskStats.TotalCodes),
 		RemainingTaskCodes: uint64(taskStats.RemainCodes),
+		ExpiredTaskCodes:   uint64(taskStats.ExpiredCodes),
+		Redemptions:        uint64(stats.Redemptions),
+		RemainingUses:      uint64(stats.RemainUses),
 		Tasks:              tasks,
 	})
 
@@ -1,7 +1,6 @@
 package api
 
 import (
-	"invite-code-service/dao"
 	"invite-code-service/pkg/utils"
 	"strings"
 
@@ -11,15 +10,19 @@ import (
 )
 
 type RspUserStatus struct {
-	InviteCode string `json:"invite_code"`
-	Tasks      []Task `json:"tasks"`
+	InviteCode    string         `json:"invite_code"`
+	MaxUses       uint64         `json:"max_uses"`
+	UseCount      uint64         `json:"use_count"`
+	ReferralCodes []ReferralCode `json:"referral_codes"`
+	Tasks         []Task         `json:"tasks"`
 }
 
 // @Summary get user status
-// @Description get user status
+// @Description get user status, a bound user also gets their referral codes and who used them
 // @Tags v1
 // @Accept json
 // @Produce json
+// @Param campaign query string false "campaign name, the default campaign if empty"
 // @Param address query string true "address"
 // @Success 200 {object} utils.Rsp{data=RspUserStatus}
 // @Router /v1/invite/userStatus [get]
@@ -31,8 +34,13 @@ func (h *Handler) GetUserStatus(c *gin.Context) {
 	}
 	address = strings.ToLower(address)
 
-	inviteCode := ""
-	codeInfo, err := dao.GetInviteCodeByUserAddress(h.db, address)
+	campaign, ok := h.getCampaign(c)
+	if !ok {
+		return
+	}
+
+	rsp := RspUserStatus{ReferralCodes: []ReferralCode{}}
+	codeInfo, err := h.store.GetInviteCodeByUserAddress(campaign.ID, address)
 	if err != nil {
 		if err != gorm.ErrRecordNotFound {
 			utils.Err(c, codeInternalErr, err.Error())
@@ -41,10 +49,19 @@ func (h *Handler) GetUserStatus(c *gin.Context) {
 		}
 		// pass
 	} else {
-		inviteCode = codeInfo.InviteCode
+		rsp.InviteCode = codeInfo.InviteCode
+		rsp.MaxUses = codeInfo.MaxUses
+		rsp.UseCount = codeInfo.UseCount
+
+		rsp.ReferralCodes, err = h.getReferralCodes(campaign.ID, address)
+		if err != nil {
+			utils.Err(c, codeInternalErr, err.Error())
+			logrus.Errorf("getReferralCodes err %s", err)
+			return
+		}
 	}
 
-	userTasks, err := h.getUserTasks(address)
+	userTasks, err := h.getUserTasks(campaign, address)
 	if err != nil {
 		if err != utils.ErrAddressNotFound {
 			utils.Err(c, codeInternalErr, err.Error())
@@ -55,10 +72,7 @@ func (h *Handler) GetUserStatus(c *gin.Context) {
 		}
 	}
 
-	rsp := RspUserStatus{
-		InviteCode: inviteCode,
-		Tasks:      userTasks,
-	}
+	rsp.Tasks = userTasks
 
 	utils.Ok(c, rsp)
 }
@@ -4,6 +4,7 @@ import (
 	"encoding/csv"
 	"encoding/json"
 	"fmt"
+	"invite-code-service/api"
 	"invite-code-service/dao"
 	"invite-code-service/pkg/config"
 	"invite-code-service/pkg/db"
@@ -15,6 +16,8 @@ import (
 	"gorm.io/gorm"
 )
 
+const flagCampaign = "campaign"
+
 func bindCmd() *cobra.Command {
 	var cmd = &cobra.Command{
 		Use:   "bind",
@@ -26,6 +29,10 @@ func bindCmd() *cobra.Command {
 				return err
 			}
 			fmt.Printf("Config path: %s\n", configPath)
+			campaignName, err := cmd.Flags().GetString(flagCampaign)
+			if err != nil {
+				return err
+			}
 
 			cfg, err := config.LoadConfig[config.ConfigBindCode](configPath)
 			if err != nil {
@@ -34,6 +41,18 @@ func bindCmd() *cobra.Command {
 			if len(cfg.FilePath) == 0 {
 				return fmt.Errorf("FilePath empty")
 			}
+			var apiCfg *config.ConfigApi
+			if len(cfg.ApiConfigPath) > 0 {
+				apiCfg, err = config.LoadConfig[config.ConfigApi](cfg.ApiConfigPath)
+				if err != nil {
+					return err
+				}
+				if err := api.CheckCodeFormats(apiCfg); err != nil {
+					return err
+				}
+			} else {
+				fmt.Println("ApiConfigPath empty, the bound users get no referral codes until issue-referrals is run")
+			}
 
 			file, err := os.Open(cfg.FilePath)
 			if err != nil {
@@ -68,22 +87,37 @@ func bindCmd() *cobra.Command {
 
 			//init db
 			db, err := db.NewDB(&db.Config{
-				Host:   cfg.Db.Host,
-				Port:   cfg.Db.Port,
-				User:   cfg.Db.User,
-				Pass:   cfg.Db.Pwd,
-				DBName: cfg.Db.Name,
-				Mode:   "silent"})
+				Host:    cfg.Db.Host,
+				Port:    cfg.Db.Port,
+				User:    cfg.Db.User,
+				Pass:    cfg.Db.Pwd,
+				DBName:  cfg.Db.Name,
+				Dialect: cfg.Db.Dialect,
+				Path:    cfg.Db.Path,
+				Mode:    "silent"})
 			if err != nil {
 				logrus.Errorf("db err: %s", err)
 				return err
 			}
-			err = dao.AutoMigrate(db)
+			err = dao.MigrateLatest(db)
 			if err != nil {
-				logrus.Errorf("dao autoMigrate err: %s", err)
+				logrus.Errorf("dao migrate err: %s", err)
 				return err
 			}
 			logrus.Infof("db connect success")
+			store := dao.NewDbStore(db)
+
+			campaign, err := store.GetCampaign(campaignName)
+			if err != nil {
+				if err != gorm.ErrRecordNotFound {
+					return err
+				}
+				return fmt.Errorf("campaign %s not exist", campaignName)
+			}
+			var referral *dao.GenerateSpec
+			if apiCfg != nil {
+				referral = api.ReferralSpec(apiCfg, campaign.ID)
+			}
 
 			for _, record := range records {
 				var address, discordId, code string
@@ -101,24 +135,27 @@ func bindCmd() *cobra.Command {
 				}
 
 				// check code
-				inviteCode, err := dao.GetInviteCode(db, code)
+				inviteCode, err := store.GetInviteCode(code)
 				if err != nil {
 					if err != gorm.ErrRecordNotFound {
 						return err
 					}
 					return fmt.Errorf("code %s not exist", code)
 				} else {
-					if inviteCode.UserAddress != nil {
-						fmt.Printf("code %s already bind address: %s, will skip\n", code, address)
+					if inviteCode.BindTime != 0 {
+						fmt.Printf("code %s already fully used, will skip address: %s\n", code, address)
 						continue
 					}
 				}
+				if inviteCode.CampaignId != campaign.ID {
+					return fmt.Errorf("code: %s not in campaign %s", inviteCode.InviteCode, campaign.Name)
+				}
 				if inviteCode.CodeType != dao.DirectInviteCode {
 					return fmt.Errorf("code: %s type: %d not match", inviteCode.InviteCode, inviteCode.CodeType)
 				}
 
 				// check address
-				inviteCodeByUser, err := dao.GetInviteCodeByUserAddress(db, address)
+				inviteCodeByUser, err := store.GetInviteCodeByUserAddress(campaign.ID, address)
 				if err != nil {
 					if err != gorm.ErrRecordNotFound {
 						return err
@@ -131,7 +168,7 @@ func bindCmd() *cobra.Command {
 
 				// check discord id
 				if len(discordId) > 0 {
-					inviteCodeByDiscordId, err := dao.GetInviteCodeByDiscordId(db, discordId)
+					inviteCodeByDiscordId, err := store.GetInviteCodeByDiscordId(campaign.ID, discordId)
 					if err != nil {
 						if err != gorm.ErrRecordNotFound {
 							return err
@@ -149,7 +186,7 @@ func bindCmd() *cobra.Command {
 					inviteCode.DiscordId = &discordId
 				}
 
-				err = dao.CheckBondAndUpdateInviteCode(db, inviteCode)
+				err = store.CheckBondAndUpdateInviteCode(inviteCode, dao.EventBoundCli, cliEventMeta(record), referral)
 				if err != nil {
 					return err
 				}
@@ -160,5 +197,6 @@ func bindCmd() *cobra.Command {
 		},
 	}
 	cmd.Flags().String(flagConfigPath, defaultConfigPath, "Config file path")
+	cmd.Flags().String(flagCampaign, dao.DefaultCampaignName, "Campaign of the codes and addresses")
 	return cmd
 }
@@ -0,0 +1,72 @@
+package cmd
+
+import (
+	"fmt"
+	"invite-code-service/dao"
+	"invite-code-service/pkg/config"
+	"invite-code-service/pkg/db"
+	"invite-code-service/pkg/utils"
+	"os/user"
+
+	"github.com/spf13/cobra"
+)
+
+// cliDb opens the database of the [db] section of the config flag, without
+// the interactive confirmation of the long running commands
+func cliDb(cmd *cobra.Command) (*db.WrapDb, error) {
+	configPath, err := cmd.Flags().GetString(flagConfigPath)
+	if err != nil {
+		return nil, err
+	}
+	fmt.Printf("Config path: %s\n", configPath)
+
+	cfg, err := config.LoadConfig[config.ConfigCli](configPath)
+	if err != nil {
+		return nil, err
+	}
+	return openCliDb(cfg.Db)
+}
+
+// cliApiConfig loads the api config of the config flag and opens its database,
+// for the commands that need the code settings too
+func cliApiConfig(cmd *cobra.Command) (*config.ConfigApi, *db.WrapDb, error) {
+	configPath, err := cmd.Flags().GetString(flagConfigPath)
+	if err != nil {
+		return nil, nil, err
+	}
+	fmt.Printf("Config path: %s\n", configPath)
+
+	cfg, err := config.LoadConfig[config.ConfigApi](configPath)
+	if err != nil {
+		return nil, nil, err
+	}
+	wrapDb, err := openCliDb(cfg.Db)
+	if err != nil {
+		return nil, nil, err
+	}
+	return cfg, wrapDb, nil
+}
+
+func openCliDb(cfg config.Db) (*db.WrapDb, error) {
+	return db.NewDB(&db.Config{
+		Host:    cfg.Host,
+		Port:    cfg.Port,
+		User:    cfg.User,
+		Pass:    cfg.Pwd,
+		DBName:  cfg.Name,
+		Dialect: cfg.Dialect,
+		Path:    cfg.Path,
+		Mode:    "silent"})
+}
+
+// cliEventMeta records the os user running a command as the actor
+func cliEventMeta(payload any) dao.EventMeta {
+	actor := "cli"
+	if u, err := user.Current(); err == nil {
+		actor = "cli:" + u.Username
+	}
+	return dao.EventMeta{
+		Actor:       actor,
+		PayloadHash: utils.HashPayload(payload),
+	}
+}
@@ -0,0 +1,122 @@
+package cmd
+
+import (
+	"fmt"
+	"invite-code-service/dao"
+	"invite-code-service/pkg/config"
+
+	"github.com/spf13/cobra"
+	"gorm.io/gorm"
+)
+
+const (
+	flagDroplets        = "droplets"
+	flagCodesPerDroplet = "codes-per-droplet"
+)
+
+func dropletRoundCmd() *cobra.Command {
+	var cmd = &cobra.Command{
+		Use:   "droplet-round <list|open|close>",
+		Short: "List the droplet rounds of a campaign, open the next one or close the open one",
+		Long: "List the droplet rounds of a campaign, open the next one or close the open one.\n" +
+			"Opening a round closes the open one and assigns available water codes to its droplets,\n" +
+			"--droplets and --codes-per-droplet override the configured DropletShape.",
+		Args:      cobra.MatchAll(cobra.ExactArgs(1), cobra.OnlyValidArgs),
+		ValidArgs: []string{"list", "open", "close"},
+
+		RunE: func(cmd *cobra.Command, args []string) error {
+			action := args[0]
+			campaignName, err := cmd.Flags().GetString(flagCampaign)
+			if err != nil {
+				return err
+			}
+			droplets, err := cmd.Flags().GetUint8(flagDroplets)
+			if err != nil {
+				return err
+			}
+			codesPerDroplet, err := cmd.Flags().GetUint8(flagCodesPerDroplet)
+			if err != nil {
+				return err
+			}
+
+			cfg, db, err := cliApiConfig(cmd)
+			if err != nil {
+				return err
+			}
+			if err := dao.CheckSchemaVersion(db); err != nil {
+				return err
+			}
+			store := dao.NewDbStore(db)
+
+			campaign, err := store.GetCampaign(campaignName)
+			if err != nil {
+				if err != gorm.ErrRecordNotFound {
+					return err
+				}
+				return fmt.Errorf("campaign %s not exist", campaignName)
+			}
+			rounds, err := store.GetDropletRounds(campaign.ID)
+			if err != nil {
+				return err
+			}
+			for _, r := range rounds {
+				status := "closed"
+				if r.IsOpen() {
+					status = "open"
+				}
+				fmt.Printf("round: %d, %s, open time: %d, close time: %d, droplets: %d x %d codes\n",
+					r.Round, status, r.OpenTime, r.CloseTime, r.Droplets, r.CodesPerDroplet)
+			}
+			if len(rounds) == 0 {
+				fmt.Printf("campaign %s has no droplet rounds\n", campaign.Name)
+			}
+			if action == "list" {
+				return nil
+			}
+
+			shape := config.DropletShape{Droplets: droplets, CodesPerDroplet: codesPerDroplet}
+			shape = shape.Or(cfg.CampaignDropletShape(campaign.Name))
+			openShape := dao.DropletShape(shape).OrDefault()
+			prompt := fmt.Sprintf("open droplet round %d of campaign %s with %d droplets of %d codes",
+				dao.NextDropletRound(rounds), campaign.Name, openShape.Droplets, openShape.CodesPerDroplet)
+			if action == "close" {
+				prompt = fmt.Sprintf("close the open droplet round of campaign %s", campaign.Name)
+			}
+		Out:
+			for {
+				fmt.Printf("\n%s, press (y/n) to continue:\n", prompt)
+				var input string
+				fmt.Scanln(&input)
+				switch input {
+				case "y":
+					break Out
+				case "n":
+					return nil
+				default:
+					fmt.Println("press `y` or `n`")
+					continue
+				}
+			}
+
+			if action == "close" {
+				round, err := store.CloseDropletRound(campaign.ID)
+				if err != nil {
+					return err
+				}
+				fmt.Printf("close droplet round: %d success\n", round.Round)
+				return nil
+			}
+			round, err := store.OpenDropletRound(campaign.ID, dao.NextDropletRound(rounds), openShape)
+			if err != nil {
+				return err
+			}
+			fmt.Printf("open droplet round: %d success\n", round.Round)
+			return nil
+		},
+	}
+	cmd.Flags().String(flagConfigPath, defaultConfigPath, "Config file path")
+	cmd.Flags().String(flagCampaign, dao.DefaultCampaignName, "Campaign of the droplet rounds")
+	cmd.Flags().Uint8(flagDroplets, 0, "Droplets of the opened round, the configured DropletShape if 0")
+	cmd.Flags().Uint8(flagCodesPerDroplet, 0, "Codes of each droplet of the opened round, the configured DropletShape if 0")
+	return cmd
+}
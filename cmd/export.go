@@ -0,0 +1,369 @@
+package cmd
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"invite-code-service/dao"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+const (
+	flagFormat   = "format"
+	flagBound    = "bound"
+	flagBindFrom = "bind-from"
+	flagBindTo   = "bind-to"
+	flagRound    = "round"
+
+	snapshotVersion = 1
+)
+
+// ExportedCode is a row of the csv and jsonl exports
+type ExportedCode struct {
+	InviteCode     string            `json:"invite_code"`
+	Campaign       string            `json:"campaign"`
+	CodeType       string            `json:"code_type"`
+	BatchId        int64             `json:"batch_id"`
+	MaxUses        uint64            `json:"max_uses"`
+	UseCount       uint64            `json:"use_count"`
+	UserAddress    string            `json:"user_address"`
+	DiscordId      string            `json:"discord_id"`
+	DiscordName    string            `json:"discord_name"`
+	UserId         string            `json:"user_id"`
+	BindTime       uint64            `json:"bind_time"`
+	ValidFrom      uint64            `json:"valid_from"`
+	ExpiresAt      uint64            `json:"expires_at"`
+	RevokeTime     uint64            `json:"revoke_time"`
+	InviterAddress string            `json:"inviter_address"`
+	Droplets       []ExportedDroplet `json:"droplets"`
+}
+
+type ExportedDroplet struct {
+	Round        uint8 `json:"round"`
+	DropletIndex uint8 `json:"droplet_index"`
+}
+
+// SnapshotHeader is the first line of a snapshot, the rows follow as one
+// SnapshotRow per line and a SnapshotEnd closes a complete snapshot
+type SnapshotHeader struct {
+	Snapshot      int               `json:"snapshot"`
+	SchemaVersion uint              `json:"schema_version"`
+	CreatedAt     int64             `json:"created_at"`
+	Filter        map[string]string `json:"filter"` // the filter flags set
+}
+
+type SnapshotRow struct {
+	Table string `json:"table"`
+	Row   any    `json:"row"`
+}
+
+type SnapshotEnd struct {
+	End map[string]int64 `json:"end"`
+}
+
+type codeWriter interface {
+	Write(c *dao.ExportedCode) error
+	Close() error
+}
+
+func exportCmd() *cobra.Command {
+	var cmd = &cobra.Command{
+		Use:   "export",
+		Short: "Export invite codes with their droplet assignments to csv, jsonl or a snapshot",
+
+		RunE: func(cmd *cobra.Command, args []string) error {
+			format, err := cmd.Flags().GetString(flagFormat)
+			if err != nil {
+				return err
+			}
+			out, err := cmd.Flags().GetString(flagOut)
+			if err != nil {
+				return err
+			}
+			filter, campaignName, err := exportFilterFlags(cmd)
+			if err != nil {
+				return err
+			}
+
+			db, err := cliDb(cmd)
+			if err != nil {
+				return err
+			}
+			if err := dao.CheckSchemaVersion(db); err != nil {
+				return err
+			}
+			store := dao.NewDbStore(db)
+
+			campaigns, err := store.GetCampaigns()
+			if err != nil {
+				return err
+			}
+			names := make(map[int64]string, len(campaigns))
+			for _, c := range campaigns {
+				names[c.ID] = c.Name
+				if c.Name == campaignName {
+					filter.CampaignId = c.ID
+				}
+			}
+			if len(campaignName) > 0 && filter.CampaignId == 0 {
+				return fmt.Errorf("campaign %s not exist", campaignName)
+			}
+
+			file, err := os.Create(out)
+			if err != nil {
+				return err
+			}
+			defer file.Close()
+			buf := bufio.NewWriter(file)
+
+			var writer codeWriter
+			switch format {
+			case "csv":
+				writer = newCsvCodeWriter(buf, names)
+			case "jsonl":
+				writer = &jsonlCodeWriter{encoder: json.NewEncoder(buf), names: names}
+			case "snapshot":
+				version, err := dao.GetSchemaVersion(db)
+				if err != nil {
+					return err
+				}
+				writer, err = newSnapshotWriter(buf, SnapshotHeader{
+					Snapshot:      snapshotVersion,
+					SchemaVersion: version,
+					CreatedAt:     time.Now().Unix(),
+					Filter:        filterFlags(cmd),
+				})
+				if err != nil {
+					return err
+				}
+			default:
+				return fmt.Errorf("unknown format %q", format)
+			}
+
+			count := 0
+			err = store.ExportInviteCodes(filter, func(c *dao.ExportedCode) error {
+				count++
+				if count%10000 == 0 {
+					fmt.Printf("exported %d codes\n", count)
+				}
+				return writer.Write(c)
+			})
+			if err != nil {
+				return err
+			}
+			if err := writer.Close(); err != nil {
+				return fmt.Errorf("write %s: %w", out, err)
+			}
+			if err := buf.Flush(); err != nil {
+				return fmt.Errorf("write %s: %w", out, err)
+			}
+			fmt.Printf("exported %d codes into %s\n", count, out)
+			return nil
+		},
+	}
+	cmd.Flags().String(flagConfigPath, defaultConfigPath, "Config file path")
+	cmd.Flags().String(flagFormat, "csv", "Output format: csv, jsonl or snapshot")
+	cmd.Flags().String(flagOut, "", "Output file")
+	cmd.Flags().String(flagCampaign, "", "Only codes of the campaign, every campaign if empty")
+	cmd.Flags().StringSlice(flagType, nil, "Only codes of the types: task, direct, water, referral")
+	cmd.Flags().String(flagBound, "", "Only codes redeemed at least once (true) or never redeemed (false)")
+	cmd.Flags().String(flagBindFrom, "", "Only codes bound at or after, unix seconds or RFC3339")
+	cmd.Flags().String(flagBindTo, "", "Only codes bound before, unix seconds or RFC3339")
+	cmd.Flags().Int(flagRound, -1, "Only codes assigned to a droplet of the round")
+	cmd.MarkFlagRequired(flagOut)
+	return cmd
+}
+
+// exportFilterFlags parses the filter flags, the campaign is resolved by the caller
+func exportFilterFlags(cmd *cobra.Command) (filter dao.ExportFilter, campaignName string, err error) {
+	campaignName, err = cmd.Flags().GetString(flagCampaign)
+	if err != nil {
+		return
+	}
+	typeNames, err := cmd.Flags().GetStringSlice(flagType)
+	if err != nil {
+		return
+	}
+	for _, name := range typeNames {
+		codeType, err := dao.ParseCodeType(name)
+		if err != nil {
+			return filter, "", err
+		}
+		filter.CodeTypes = append(filter.CodeTypes, codeType)
+	}
+
+	bound, err := cmd.Flags().GetString(flagBound)
+	if err != nil {
+		return
+	}
+	if len(bound) > 0 {
+		b, err := strconv.ParseBool(bound)
+		if err != nil {
+			return filter, "", fmt.Errorf("%s: %w", flagBound, err)
+		}
+		filter.Bound = &b
+	}
+
+	for flag, dst := range map[string]*uint64{flagBindFrom: &filter.BindFrom, flagBindTo: &filter.BindTo} {
+		value, err := cmd.Flags().GetString(flag)
+		if err != nil {
+			return filter, "", err
+		}
+		if *dst, err = parseTimeFlag(value); err != nil {
+			return filter, "", fmt.Errorf("%s: %w", flag, err)
+		}
+	}
+
+	round, err := cmd.Flags().GetInt(flagRound)
+	if err != nil {
+		return
+	}
+	if round >= 0 {
+		if round > 255 {
+			return filter, "", fmt.Errorf("%s over 255", flagRound)
+		}
+		r := uint8(round)
+		filter.DropletRound = &r
+	}
+	return filter, campaignName, nil
+}
+
+func filterFlags(cmd *cobra.Command) map[string]string {
+	flags := make(map[string]string)
+	cmd.Flags().Visit(func(f *pflag.Flag) {
+		switch f.Name {
+		case flagCampaign, flagType, flagBound, flagBindFrom, flagBindTo, flagRound:
+			flags[f.Name] = f.Value.String()
+		}
+	})
+	return flags
+}
+
+// parseTimeFlag takes unix seconds or RFC3339, empty is 0
+func parseTimeFlag(value string) (uint64, error) {
+	if len(value) == 0 {
+		return 0, nil
+	}
+	if seconds, err := strconv.ParseUint(value, 10, 64); err == nil {
+		return seconds, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return 0, err
+	}
+	return uint64(t.Unix()), nil
+}
+
+func exportedCode(c *dao.ExportedCode, names map[int64]string) *ExportedCode {
+	droplets := make([]ExportedDroplet, 0, len(c.Droplets))
+	for _, d := range c.Droplets {
+		droplets = append(droplets, ExportedDroplet{Round: d.Round, DropletIndex: d.DropletIndex})
+	}
+	return &ExportedCode{
+		InviteCode:     c.InviteCode.InviteCode,
+		Campaign:       names[c.CampaignId],
+		CodeType:       dao.CodeTypeName(c.CodeType),
+		BatchId:        c.BatchId,
+		MaxUses:        c.MaxUses,
+		UseCount:       c.UseCount,
+		UserAddress:    derefString(c.UserAddress),
+		DiscordId:      derefString(c.DiscordId),
+		DiscordName:    derefString(c.DiscordName),
+		UserId:         derefString(c.UserId),
+		BindTime:       c.BindTime,
+		ValidFrom:      c.ValidFrom,
+		ExpiresAt:      c.ExpiresAt,
+		RevokeTime:     c.RevokeTime,
+		InviterAddress: derefString(c.InviterAddress),
+		Droplets:       droplets,
+	}
+}
+
+type csvCodeWriter struct {
+	writer *csv.Writer
+	names  map[int64]string
+}
+
+func newCsvCodeWriter(w io.Writer, names map[int64]string) *csvCodeWriter {
+	writer := csv.NewWriter(w)
+	writer.Write([]string{"invite_code", "campaign", "code_type", "batch_id", "max_uses", "use_count",
+		"user_address", "discord_id", "discord_name", "user_id", "bind_time", "valid_from", "expires_at",
+		"revoke_time", "inviter_address", "droplets"})
+	return &csvCodeWriter{writer: writer, names: names}
+}
+
+// Write puts the droplets in one column as round:index pairs separated by ;
+func (w *csvCodeWriter) Write(c *dao.ExportedCode) error {
+	e := exportedCode(c, w.names)
+	droplets := make([]string, 0, len(e.Droplets))
+	for _, d := range e.Droplets {
+		droplets = append(droplets, fmt.Sprintf("%d:%d", d.Round, d.DropletIndex))
+	}
+	return w.writer.Write([]string{e.InviteCode, e.Campaign, e.CodeType, strconv.FormatInt(e.BatchId, 10),
+		strconv.FormatUint(e.MaxUses, 10), strconv.FormatUint(e.UseCount, 10),
+		e.UserAddress, e.DiscordId, e.DiscordName, e.UserId, strconv.FormatUint(e.BindTime, 10),
+		strconv.FormatUint(e.ValidFrom, 10), strconv.FormatUint(e.ExpiresAt, 10),
+		strconv.FormatUint(e.RevokeTime, 10), e.InviterAddress, strings.Join(droplets, ";")})
+}
+
+func (w *csvCodeWriter) Close() error {
+	w.writer.Flush()
+	return w.writer.Error()
+}
+
+type jsonlCodeWriter struct {
+	encoder *json.Encoder
+	names   map[int64]string
+}
+
+func (w *jsonlCodeWriter) Write(c *dao.ExportedCode) error {
+	return w.encoder.Encode(exportedCode(c, w.names))
+}
+
+func (w *jsonlCodeWriter) Close() error {
+	return nil
+}
+
+// snapshotWriter writes the table rows as they are stored, for restoring or
+// diffing without access to the database
+type snapshotWriter struct {
+	encoder *json.Encoder
+	counts  map[string]int64
+}
+
+func newSnapshotWriter(w io.Writer, header SnapshotHeader) (*snapshotWriter, error) {
+	encoder := json.NewEncoder(w)
+	if err := encoder.Encode(header); err != nil {
+		return nil, err
+	}
+	return &snapshotWriter{encoder: encoder, counts: make(map[string]int64)}, nil
+}
+
+func (w *snapshotWriter) Write(c *dao.ExportedCode) error {
+	if err := w.row(c.InviteCode.TableName(), c.InviteCode); err != nil {
+		return err
+	}
+	for _, d := range c.Droplets {
+		if err := w.row(d.TableName(), d); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (w *snapshotWriter) row(table string, row any) error {
+	w.counts[table]++
+	return w.encoder.Encode(SnapshotRow{Table: table, Row: row})
+}
+
+func (w *snapshotWriter) Close() error {
+	return w.encoder.Encode(SnapshotEnd{End: w.counts})
+}
@@ -0,0 +1,212 @@
+package cmd
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"invite-code-service/api"
+	"invite-code-service/dao"
+	svcapi "invite-code-service/services/api"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"gorm.io/gorm"
+)
+
+const (
+	flagType    = "type"
+	flagCount   = "count"
+	flagLabel   = "label"
+	flagOut     = "out"
+	flagOwner   = "owner"
+	flagNotes   = "notes"
+	flagMaxUses = "max-uses"
+)
+
+// GeneratedCode is a row of the gen-codes output
+type GeneratedCode struct {
+	InviteCode string `json:"invite_code"`
+	CodeType   string `json:"code_type"`
+	Campaign   string `json:"campaign"`
+	Batch      string `json:"batch"`
+	MaxUses    uint64 `json:"max_uses"`
+	ValidFrom  uint64 `json:"valid_from"`
+	ExpiresAt  uint64 `json:"expires_at"`
+}
+
+func genCodesCmd() *cobra.Command {
+	var cmd = &cobra.Command{
+		Use:   "gen-codes",
+		Short: "Generate invite codes into the pool and write them to a csv or json file",
+
+		RunE: func(cmd *cobra.Command, args []string) error {
+			typeName, err := cmd.Flags().GetString(flagType)
+			if err != nil {
+				return err
+			}
+			count, err := cmd.Flags().GetInt64(flagCount)
+			if err != nil {
+				return err
+			}
+			label, err := cmd.Flags().GetString(flagLabel)
+			if err != nil {
+				return err
+			}
+			out, err := cmd.Flags().GetString(flagOut)
+			if err != nil {
+				return err
+			}
+			campaignName, err := cmd.Flags().GetString(flagCampaign)
+			if err != nil {
+				return err
+			}
+			batchInfo, err := batchFlags(cmd)
+			if err != nil {
+				return err
+			}
+			maxUses, err := cmd.Flags().GetUint64(flagMaxUses)
+			if err != nil {
+				return err
+			}
+
+			codeType, err := dao.ParseCodeType(typeName)
+			if err != nil {
+				return err
+			}
+			if codeType == dao.ReferralInviteCode {
+				return fmt.Errorf("referral codes are issued to bound users, not generated")
+			}
+			if count <= 0 || count > svcapi.MaxGenCount {
+				return fmt.Errorf("count must be in [1, %d]", svcapi.MaxGenCount)
+			}
+			if len(label) > 64 {
+				return fmt.Errorf("label over 64 characters")
+			}
+			if maxUses > 0 && codeType != dao.DirectInviteCode {
+				return fmt.Errorf("--%s only applies to direct codes", flagMaxUses)
+			}
+
+			cfg, db, err := cliApiConfig(cmd)
+			if err != nil {
+				return err
+			}
+			if err := api.CheckCodeFormats(cfg); err != nil {
+				return err
+			}
+			if err := dao.CheckSchemaVersion(db); err != nil {
+				return err
+			}
+			store := dao.NewDbStore(db)
+
+			campaign, err := store.GetCampaign(campaignName)
+			if err != nil {
+				if err != gorm.ErrRecordNotFound {
+					return err
+				}
+				return fmt.Errorf("campaign %s not exist, start-api saves the configured campaigns", campaignName)
+			}
+
+			// fail on a bad path before touching the pool
+			file, err := os.Create(out)
+			if err != nil {
+				return err
+			}
+			defer file.Close()
+
+			spec := svcapi.NewGenerateSpec(cfg, campaign.ID, codeType, count)
+			if maxUses > 0 {
+				spec.MaxUses = maxUses
+			}
+			spec.Progress = func(done, total int64) {
+				fmt.Printf("generated %d/%d\n", done, total)
+			}
+			if len(label) > 0 {
+				batchInfo.Name = label
+				spec.Batch = &batchInfo
+			}
+
+			payload := map[string]any{"type": typeName, "count": count, "label": label, "campaign": campaign.Name, "max_uses": maxUses}
+			codes, genErr := store.GenerateInviteCodes(spec, cliEventMeta(payload))
+
+			// the codes committed before an error are written out too
+			rows := make([]GeneratedCode, 0, len(codes))
+			for _, c := range codes {
+				rows = append(rows, GeneratedCode{
+					InviteCode: c.InviteCode,
+					CodeType:   typeName,
+					Campaign:   campaign.Name,
+					Batch:      label,
+					MaxUses:    c.MaxUses,
+					ValidFrom:  c.ValidFrom,
+					ExpiresAt:  c.ExpiresAt,
+				})
+			}
+			if strings.EqualFold(filepath.Ext(out), ".json") {
+				err = writeGeneratedJson(file, rows)
+			} else {
+				err = writeGeneratedCsv(file, rows)
+			}
+			if err != nil {
+				return fmt.Errorf("write %s: %w", out, err)
+			}
+			if genErr != nil {
+				return fmt.Errorf("generated %d of %d codes: %w", len(codes), count, genErr)
+			}
+			fmt.Printf("generated %d %s codes into %s\n", len(codes), typeName, out)
+			return nil
+		},
+	}
+	cmd.Flags().String(flagConfigPath, defaultConfigPath, "Api config file path, for the database and the code settings")
+	cmd.Flags().String(flagType, "", "Code type: task, direct or water")
+	cmd.Flags().Int64(flagCount, 0, "Number of codes to generate")
+	cmd.Flags().String(flagLabel, "", "Batch the codes are labelled with, created if it doesn't exist")
+	cmd.Flags().String(flagOwner, "", "Owner of the batch, e.g. the partner the codes are for, only set on a new batch")
+	cmd.Flags().String(flagNotes, "", "Notes on the batch, only set on a new batch")
+	cmd.Flags().Uint64(flagMaxUses, 0, "Users that can redeem each direct code, DirectInviteCodeMaxUses if 0")
+	cmd.Flags().String(flagOut, "", "Output file, json if it ends with .json, csv otherwise")
+	cmd.Flags().String(flagCampaign, dao.DefaultCampaignName, "Campaign of the codes")
+	cmd.MarkFlagRequired(flagType)
+	cmd.MarkFlagRequired(flagCount)
+	cmd.MarkFlagRequired(flagOut)
+	return cmd
+}
+
+// batchFlags reads the owner and notes of the batches a command creates
+func batchFlags(cmd *cobra.Command) (dao.Batch, error) {
+	owner, err := cmd.Flags().GetString(flagOwner)
+	if err != nil {
+		return dao.Batch{}, err
+	}
+	notes, err := cmd.Flags().GetString(flagNotes)
+	if err != nil {
+		return dao.Batch{}, err
+	}
+	if len(owner) > 80 {
+		return dao.Batch{}, fmt.Errorf("owner over 80 characters")
+	}
+	if len(notes) > 255 {
+		return dao.Batch{}, fmt.Errorf("notes over 255 characters")
+	}
+	return dao.Batch{Owner: owner, Notes: notes}, nil
+}
+
+func writeGeneratedCsv(w io.Writer, rows []GeneratedCode) error {
+	writer := csv.NewWriter(w)
+	writer.Write([]string{"invite_code", "code_type", "campaign", "batch", "max_uses", "valid_from", "expires_at"})
+	for _, r := range rows {
+		writer.Write([]string{r.InviteCode, r.CodeType, r.Campaign, r.Batch,
+			strconv.FormatUint(r.MaxUses, 10), strconv.FormatUint(r.ValidFrom, 10), strconv.FormatUint(r.ExpiresAt, 10)})
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+func writeGeneratedJson(w io.Writer, rows []GeneratedCode) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(rows)
+}
@@ -0,0 +1,51 @@
+package cmd
+
+import (
+	"fmt"
+	"invite-code-service/dao"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+func historyCmd() *cobra.Command {
+	var cmd = &cobra.Command{
+		Use:   "history <code|address>",
+		Short: "Show the event history of an invite code, address or discord id",
+		Args:  cobra.ExactArgs(1),
+
+		RunE: func(cmd *cobra.Command, args []string) error {
+			key := args[0]
+			if strings.HasPrefix(key, "0x") || strings.HasPrefix(key, "0X") {
+				key = strings.ToLower(key)
+			}
+
+			db, err := cliDb(cmd)
+			if err != nil {
+				return err
+			}
+			if err := dao.CheckSchemaVersion(db); err != nil {
+				return err
+			}
+
+			events, err := dao.NewDbStore(db).GetInviteCodeEvents(key)
+			if err != nil {
+				return err
+			}
+			if len(events) == 0 {
+				fmt.Printf("no events of %s\n", key)
+				return nil
+			}
+
+			for _, e := range events {
+				fmt.Printf("%s  %-10s %-18s address: %s, discord id: %s, actor: %s, ip: %s, payload: %s\n",
+					time.Unix(int64(e.EventTime), 0).Format(time.RFC3339),
+					e.InviteCode, e.EventType, e.UserAddress, e.DiscordId, e.Actor, e.SourceIp, e.PayloadHash)
+			}
+			return nil
+		},
+	}
+	cmd.Flags().String(flagConfigPath, defaultConfigPath, "Config file path")
+	return cmd
+}
@@ -0,0 +1,343 @@
+package cmd
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"invite-code-service/api"
+	"invite-code-service/dao"
+	"invite-code-service/pkg/config"
+	"invite-code-service/pkg/utils"
+	svcapi "invite-code-service/services/api"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"gorm.io/gorm"
+)
+
+const (
+	flagFile           = "file"
+	flagDryRun         = "dry-run"
+	flagSkipDuplicates = "skip-duplicates"
+	flagReport         = "report"
+)
+
+const (
+	importNew       = "new"
+	importImported  = "imported"
+	importInvalid   = "invalid"
+	importDuplicate = "duplicate"
+)
+
+// importRow is a line of the import file and what became of it
+type importRow struct {
+	Line      int
+	Code      string
+	CodeType  uint8
+	TypeName  string
+	Label     string
+	ExpiresAt uint64
+	MaxUses   uint64
+
+	Status string
+	Reason string
+}
+
+func importCodesCmd() *cobra.Command {
+	var cmd = &cobra.Command{
+		Use:   "import-codes",
+		Short: "Import externally supplied invite codes from a csv of code,type[,label[,expires_at[,max_uses]]]",
+
+		RunE: func(cmd *cobra.Command, args []string) error {
+			path, err := cmd.Flags().GetString(flagFile)
+			if err != nil {
+				return err
+			}
+			campaignName, err := cmd.Flags().GetString(flagCampaign)
+			if err != nil {
+				return err
+			}
+			dryRun, err := cmd.Flags().GetBool(flagDryRun)
+			if err != nil {
+				return err
+			}
+			skipDuplicates, err := cmd.Flags().GetBool(flagSkipDuplicates)
+			if err != nil {
+				return err
+			}
+			reportPath, err := cmd.Flags().GetString(flagReport)
+			if err != nil {
+				return err
+			}
+			batchInfo, err := batchFlags(cmd)
+			if err != nil {
+				return err
+			}
+
+			cfg, db, err := cliApiConfig(cmd)
+			if err != nil {
+				return err
+			}
+			if err := api.CheckCodeFormats(cfg); err != nil {
+				return err
+			}
+			if err := dao.CheckSchemaVersion(db); err != nil {
+				return err
+			}
+			store := dao.NewDbStore(db)
+
+			campaign, err := store.GetCampaign(campaignName)
+			if err != nil {
+				if err != gorm.ErrRecordNotFound {
+					return err
+				}
+				return fmt.Errorf("campaign %s not exist, start-api saves the configured campaigns", campaignName)
+			}
+
+			file, err := os.Open(path)
+			if err != nil {
+				return err
+			}
+			defer file.Close()
+			rows, err := readImportRows(file, func(codeType uint8) utils.CodeFormat { return api.CodeFormat(cfg, codeType) })
+			if err != nil {
+				return fmt.Errorf("read %s: %w", path, err)
+			}
+
+			// the unique index is checked up front so duplicates can be reported, the
+			// insert still fails as a whole if a code is taken in the meantime
+			var fresh []string
+			for _, r := range rows {
+				if r.Status == importNew {
+					fresh = append(fresh, r.Code)
+				}
+			}
+			existing, err := store.GetExistingInviteCodes(fresh)
+			if err != nil {
+				return err
+			}
+			taken := make(map[string]bool, len(existing))
+			for _, code := range existing {
+				taken[code] = true
+			}
+			for _, r := range rows {
+				if r.Status == importNew && taken[r.Code] {
+					r.Status, r.Reason = importDuplicate, "already exists"
+				}
+			}
+
+			err = importCodes(store, cfg, campaign, batchInfo, rows, dryRun, skipDuplicates)
+			printImportSummary(rows, dryRun)
+			if len(reportPath) > 0 {
+				if reportErr := writeImportReport(reportPath, rows); reportErr != nil {
+					return errors.Join(err, fmt.Errorf("write %s: %w", reportPath, reportErr))
+				}
+			}
+			return err
+		},
+	}
+	cmd.Flags().String(flagConfigPath, defaultConfigPath, "Api config file path, for the database and the code settings")
+	cmd.Flags().String(flagFile, "", "Csv file of code,type[,label[,expires_at[,max_uses]]], expires_at in unix seconds or RFC3339")
+	cmd.Flags().String(flagCampaign, dao.DefaultCampaignName, "Campaign of the codes")
+	cmd.Flags().String(flagOwner, "", "Owner of the batches created for the labels, e.g. the partner the codes are for")
+	cmd.Flags().String(flagNotes, "", "Notes on the batches created for the labels")
+	cmd.Flags().Bool(flagDryRun, false, "Validate and report without importing")
+	cmd.Flags().Bool(flagSkipDuplicates, false, "Import the other codes when some already exist, instead of importing nothing")
+	cmd.Flags().String(flagReport, "", "Csv file to write the outcome of every line to")
+	cmd.MarkFlagRequired(flagFile)
+	return cmd
+}
+
+// readImportRows parses and validates the lines of an import file, an optional
+// header line starting with code or invite_code is skipped
+func readImportRows(r io.Reader, format func(codeType uint8) utils.CodeFormat) ([]*importRow, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	now := uint64(time.Now().Unix())
+	seen := make(map[string]int)
+	var rows []*importRow
+	for line := 1; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return rows, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if line == 1 && (strings.EqualFold(record[0], "code") || strings.EqualFold(record[0], "invite_code")) {
+			continue
+		}
+
+		row := &importRow{Line: line, Code: strings.TrimSpace(record[0]), Status: importNew}
+		rows = append(rows, row)
+		if len(record) > 1 {
+			row.TypeName = strings.ToLower(strings.TrimSpace(record[1]))
+		}
+		if len(record) > 2 {
+			row.Label = strings.TrimSpace(record[2])
+		}
+		if len(record) > 5 {
+			row.Status, row.Reason = importInvalid, "over 5 columns"
+			continue
+		}
+
+		row.CodeType, err = dao.ParseCodeType(row.TypeName)
+		if err != nil {
+			row.Status, row.Reason = importInvalid, err.Error()
+			continue
+		}
+		if row.CodeType == dao.ReferralInviteCode {
+			row.Status, row.Reason = importInvalid, "referral codes can't be imported"
+			continue
+		}
+
+		f := format(row.CodeType)
+		row.Code = f.Normalize(row.Code)
+		if len(row.Code) > utils.MaxCodeLength {
+			row.Status, row.Reason = importInvalid, fmt.Sprintf("over %d characters", utils.MaxCodeLength)
+			continue
+		}
+		if err := f.Validate(row.Code); err != nil {
+			row.Status, row.Reason = importInvalid, fmt.Sprintf("not in the %s code format", row.TypeName)
+			continue
+		}
+		if len(row.Label) > 64 {
+			row.Status, row.Reason = importInvalid, "label over 64 characters"
+			continue
+		}
+		if len(record) > 3 {
+			row.ExpiresAt, err = parseTimeFlag(strings.TrimSpace(record[3]))
+			if err != nil {
+				row.Status, row.Reason = importInvalid, fmt.Sprintf("expires_at: %s", err)
+				continue
+			}
+			if row.ExpiresAt != 0 && row.ExpiresAt <= now {
+				row.Status, row.Reason = importInvalid, "already expired"
+				continue
+			}
+		}
+		if len(record) > 4 && len(strings.TrimSpace(record[4])) > 0 {
+			row.MaxUses, err = strconv.ParseUint(strings.TrimSpace(record[4]), 10, 64)
+			if err != nil || row.MaxUses == 0 {
+				row.Status, row.Reason = importInvalid, "max_uses not a positive integer"
+				continue
+			}
+			if row.MaxUses > 1 && row.CodeType != dao.DirectInviteCode {
+				row.Status, row.Reason = importInvalid, "max_uses over 1 only applies to direct codes"
+				continue
+			}
+		}
+
+		if first, ok := seen[row.Code]; ok {
+			row.Status, row.Reason = importDuplicate, fmt.Sprintf("same as line %d", first)
+			continue
+		}
+		seen[row.Code] = line
+	}
+}
+
+// importCodes inserts the new rows in one transaction, unless a row is invalid
+// or a duplicate that isn't skipped. The batches of new labels are created from
+// batchInfo in that transaction.
+func importCodes(store dao.Store, cfg *config.ConfigApi, campaign *dao.Campaign, batchInfo dao.Batch, rows []*importRow, dryRun, skipDuplicates bool) error {
+	var invalid, duplicates int
+	for _, r := range rows {
+		switch r.Status {
+		case importInvalid:
+			invalid++
+		case importDuplicate:
+			duplicates++
+		}
+	}
+	if invalid > 0 {
+		return fmt.Errorf("%d invalid lines, nothing imported", invalid)
+	}
+	if duplicates > 0 && !skipDuplicates {
+		return fmt.Errorf("%d duplicate codes, nothing imported, use --%s to import the rest", duplicates, flagSkipDuplicates)
+	}
+	if dryRun {
+		return nil
+	}
+
+	codes := make([]dao.ImportedCode, 0, len(rows))
+	for _, r := range rows {
+		if r.Status != importNew {
+			continue
+		}
+		spec := svcapi.NewGenerateSpec(cfg, campaign.ID, r.CodeType, 1)
+		expiresAt := spec.ExpiresAt
+		if r.ExpiresAt != 0 {
+			expiresAt = r.ExpiresAt
+		}
+		maxUses := spec.MaxUses
+		if r.MaxUses != 0 {
+			maxUses = r.MaxUses
+		}
+		codes = append(codes, dao.ImportedCode{
+			Code: &dao.InviteCode{
+				InviteCode: r.Code,
+				CampaignId: campaign.ID,
+				CodeType:   r.CodeType,
+				MaxUses:    maxUses,
+				ValidFrom:  spec.ValidFrom,
+				ExpiresAt:  expiresAt,
+			},
+			Batch: r.Label,
+		})
+	}
+
+	err := store.ImportInviteCodes(codes, batchInfo, cliEventMeta(map[string]any{"campaign": campaign.Name, "codes": len(codes)}))
+	if err != nil {
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			return fmt.Errorf("a code was taken while importing, nothing imported, run again")
+		}
+		return err
+	}
+	for _, r := range rows {
+		if r.Status == importNew {
+			r.Status = importImported
+		}
+	}
+	return nil
+}
+
+func printImportSummary(rows []*importRow, dryRun bool) {
+	counts := make(map[string]int)
+	for _, r := range rows {
+		counts[r.Status]++
+		if r.Status == importInvalid || r.Status == importDuplicate {
+			fmt.Printf("line %d: %s %s: %s\n", r.Line, r.Status, r.Code, r.Reason)
+		}
+	}
+	if dryRun {
+		fmt.Printf("dry run, nothing imported\n")
+	}
+	fmt.Printf("lines: %d, new: %d, imported: %d, invalid: %d, duplicate: %d\n",
+		len(rows), counts[importNew], counts[importImported], counts[importInvalid], counts[importDuplicate])
+}
+
+func writeImportReport(path string, rows []*importRow) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	writer := csv.NewWriter(file)
+	writer.Write([]string{"line", "invite_code", "code_type", "label", "expires_at", "max_uses", "status", "reason"})
+	for _, r := range rows {
+		writer.Write([]string{strconv.Itoa(r.Line), r.Code, r.TypeName, r.Label,
+			strconv.FormatUint(r.ExpiresAt, 10), strconv.FormatUint(r.MaxUses, 10), r.Status, r.Reason})
+	}
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return err
+	}
+	return file.Close()
+}
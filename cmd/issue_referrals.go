@@ -0,0 +1,85 @@
+package cmd
+
+import (
+	"fmt"
+	"invite-code-service/api"
+	"invite-code-service/dao"
+
+	"github.com/spf13/cobra"
+	"gorm.io/gorm"
+)
+
+func issueReferralsCmd() *cobra.Command {
+	var cmd = &cobra.Command{
+		Use:   "issue-referrals",
+		Short: "Issue the configured referral codes to the users bound before they were configured",
+
+		RunE: func(cmd *cobra.Command, args []string) error {
+			campaignName, err := cmd.Flags().GetString(flagCampaign)
+			if err != nil {
+				return err
+			}
+
+			cfg, db, err := cliApiConfig(cmd)
+			if err != nil {
+				return err
+			}
+			if err := api.CheckCodeFormats(cfg); err != nil {
+				return err
+			}
+			if err := dao.CheckSchemaVersion(db); err != nil {
+				return err
+			}
+			store := dao.NewDbStore(db)
+
+			campaign, err := store.GetCampaign(campaignName)
+			if err != nil {
+				if err != gorm.ErrRecordNotFound {
+					return err
+				}
+				return fmt.Errorf("campaign %s not exist, start-api saves the configured campaigns", campaignName)
+			}
+			spec := api.ReferralSpec(cfg, campaign.ID)
+			if spec == nil {
+				return fmt.Errorf("ReferralCodeCount is 0, no referral codes to issue")
+			}
+
+			addresses, err := store.GetUnreferredUserAddresses(campaign.ID, spec.Count)
+			if err != nil {
+				return err
+			}
+			if len(addresses) == 0 {
+				fmt.Printf("every bound user has %d referral codes\n", spec.Count)
+				return nil
+			}
+		Out:
+			for {
+				fmt.Printf("\nissue up to %d referral codes to %d users, press (y/n) to continue:\n", spec.Count, len(addresses))
+				var input string
+				fmt.Scanln(&input)
+				switch input {
+				case "y":
+					break Out
+				case "n":
+					return nil
+				default:
+					fmt.Println("press `y` or `n`")
+					continue
+				}
+			}
+
+			for i, address := range addresses {
+				payload := map[string]any{"campaign": campaign.Name, "address": address}
+				codes, err := store.IssueReferralCodes(address, *spec, cliEventMeta(payload))
+				if err != nil {
+					return fmt.Errorf("issue referral codes of %s: %w", address, err)
+				}
+				fmt.Printf("%d/%d address: %s, referral codes: %d\n", i+1, len(addresses), address, len(codes))
+			}
+			return nil
+		},
+	}
+	cmd.Flags().String(flagConfigPath, defaultConfigPath, "Api config file path, for the database and the referral settings")
+	cmd.Flags().String(flagCampaign, dao.DefaultCampaignName, "Campaign of the users")
+	return cmd
+}
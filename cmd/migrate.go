@@ -0,0 +1,143 @@
+package cmd
+
+import (
+	"fmt"
+	"invite-code-service/dao"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+const (
+	flagTo    = "to"
+	flagSteps = "steps"
+)
+
+func migrateCmd() *cobra.Command {
+	var cmd = &cobra.Command{
+		Use:   "migrate",
+		Short: "Manage database schema migrations",
+	}
+	cmd.AddCommand(
+		migrateUpCmd(),
+		migrateDownCmd(),
+		migrateStatusCmd(),
+	)
+	return cmd
+}
+
+func migrateUpCmd() *cobra.Command {
+	var cmd = &cobra.Command{
+		Use:   "up",
+		Short: "Apply pending migrations",
+
+		RunE: func(cmd *cobra.Command, args []string) error {
+			to, err := cmd.Flags().GetUint(flagTo)
+			if err != nil {
+				return err
+			}
+			db, err := cliDb(cmd)
+			if err != nil {
+				return err
+			}
+
+			done, err := dao.MigrateUp(db, to)
+			for _, m := range done {
+				fmt.Printf("applied %d %s\n", m.Version, m.Name)
+			}
+			if err != nil {
+				return err
+			}
+			if len(done) == 0 {
+				fmt.Println("no pending migrations")
+			}
+			return nil
+		},
+	}
+	cmd.Flags().String(flagConfigPath, defaultConfigPath, "Config file path")
+	cmd.Flags().Uint(flagTo, 0, "Target version, 0 means latest")
+	return cmd
+}
+
+func migrateDownCmd() *cobra.Command {
+	var cmd = &cobra.Command{
+		Use:   "down",
+		Short: "Revert applied migrations",
+
+		RunE: func(cmd *cobra.Command, args []string) error {
+			steps, err := cmd.Flags().GetInt(flagSteps)
+			if err != nil {
+				return err
+			}
+			if steps <= 0 {
+				return fmt.Errorf("steps must be positive")
+			}
+			db, err := cliDb(cmd)
+			if err != nil {
+				return err
+			}
+
+		Out:
+			for {
+				fmt.Printf("\nRevert the latest %d migration(s), data may be lost, press (y/n) to continue:\n", steps)
+				var input string
+				fmt.Scanln(&input)
+				switch input {
+				case "y":
+					break Out
+				case "n":
+					return nil
+				default:
+					fmt.Println("press `y` or `n`")
+					continue
+				}
+			}
+
+			done, err := dao.MigrateDown(db, steps)
+			for _, m := range done {
+				fmt.Printf("reverted %d %s\n", m.Version, m.Name)
+			}
+			return err
+		},
+	}
+	cmd.Flags().String(flagConfigPath, defaultConfigPath, "Config file path")
+	cmd.Flags().Int(flagSteps, 1, "Number of migrations to revert")
+	return cmd
+}
+
+func migrateStatusCmd() *cobra.Command {
+	var cmd = &cobra.Command{
+		Use:   "status",
+		Short: "Show migration status",
+
+		RunE: func(cmd *cobra.Command, args []string) error {
+			db, err := cliDb(cmd)
+			if err != nil {
+				return err
+			}
+
+			version, err := dao.GetSchemaVersion(db)
+			if err != nil {
+				return err
+			}
+			fmt.Printf("database version: %d, binary version: %d\n", version, dao.LatestSchemaVersion())
+
+			list, err := dao.GetMigrationStatus(db)
+			if err != nil {
+				return err
+			}
+			for _, s := range list {
+				appliedAt := "pending"
+				if s.Dirty {
+					appliedAt = "dirty"
+				} else if s.Applied {
+					appliedAt = time.Unix(int64(s.AppliedAt), 0).Format(time.RFC3339)
+				}
+				fmt.Printf("%4d  %-40s %s\n", s.Version, s.Name, appliedAt)
+			}
+			return nil
+		},
+	}
+	cmd.Flags().String(flagConfigPath, defaultConfigPath, "Config file path")
+	return cmd
+}
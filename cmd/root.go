@@ -34,6 +34,14 @@ func NewRootCmd() *cobra.Command {
 		startApiCmd(),
 		startDiscordBotCmd(),
 		bindCmd(),
+		migrateCmd(),
+		historyCmd(),
+		unbindCmd(),
+		genCodesCmd(),
+		exportCmd(),
+		importCodesCmd(),
+		dropletRoundCmd(),
+		issueReferralsCmd(),
 	)
 
 	return rootCmd
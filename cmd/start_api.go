@@ -69,26 +69,28 @@ func startApiCmd() *cobra.Command {
 
 			//init db
 			db, err := db.NewDB(&db.Config{
-				Host:   cfg.Db.Host,
-				Port:   cfg.Db.Port,
-				User:   cfg.Db.User,
-				Pass:   cfg.Db.Pwd,
-				DBName: cfg.Db.Name,
-				Mode:   "info"})
+				Host:    cfg.Db.Host,
+				Port:    cfg.Db.Port,
+				User:    cfg.Db.User,
+				Pass:    cfg.Db.Pwd,
+				DBName:  cfg.Db.Name,
+				Dialect: cfg.Db.Dialect,
+				Path:    cfg.Db.Path,
+				Mode:    "info"})
 			if err != nil {
 				logrus.Errorf("db err: %s", err)
 				return err
 			}
-			err = dao.AutoMigrate(db)
+			err = dao.MigrateLatest(db)
 			if err != nil {
-				logrus.Errorf("dao autoMigrate err: %s", err)
+				logrus.Errorf("dao migrate err: %s", err)
 				return err
 			}
 			logrus.Infof("db connect success")
 
 			ctx := utils.ShutdownListener()
 
-			t, err := api.NewService(cfg, db)
+			t, err := api.NewService(cfg, dao.NewDbStore(db))
 			if err != nil {
 				return err
 			}
@@ -0,0 +1,93 @@
+package cmd
+
+import (
+	"fmt"
+	"invite-code-service/dao"
+
+	"github.com/spf13/cobra"
+)
+
+const (
+	flagRevoke = "revoke"
+	flagReason = "reason"
+)
+
+func unbindCmd() *cobra.Command {
+	var cmd = &cobra.Command{
+		Use:   "unbind <code>",
+		Short: "Unbind an invite code, or revoke it permanently",
+		Args:  cobra.ExactArgs(1),
+
+		RunE: func(cmd *cobra.Command, args []string) error {
+			code := args[0]
+			revoke, err := cmd.Flags().GetBool(flagRevoke)
+			if err != nil {
+				return err
+			}
+			reason, err := cmd.Flags().GetString(flagReason)
+			if err != nil {
+				return err
+			}
+
+			db, err := cliDb(cmd)
+			if err != nil {
+				return err
+			}
+			if err := dao.CheckSchemaVersion(db); err != nil {
+				return err
+			}
+			store := dao.NewDbStore(db)
+
+			inviteCode, err := store.GetInviteCode(code)
+			if err != nil {
+				return fmt.Errorf("get code %s: %w", code, err)
+			}
+			action := "unbind"
+			if revoke {
+				action = "revoke"
+			}
+			redemptions, err := store.GetInviteCodeRedemptions(code)
+			if err != nil {
+				return err
+			}
+			fmt.Printf("code: %s, uses: %d/%d\n", inviteCode.InviteCode, inviteCode.UseCount, inviteCode.MaxUses)
+			for _, r := range redemptions {
+				fmt.Printf("  address: %s, discord id: %s, redeem time: %d\n",
+					derefString(r.UserAddress), derefString(r.DiscordId), r.RedeemTime)
+			}
+		Out:
+			for {
+				fmt.Printf("\n%s code %s, press (y/n) to continue:\n", action, code)
+				var input string
+				fmt.Scanln(&input)
+				switch input {
+				case "y":
+					break Out
+				case "n":
+					return nil
+				default:
+					fmt.Println("press `y` or `n`")
+					continue
+				}
+			}
+
+			_, _, err = store.UnbindInviteCode(code, revoke, reason, cliEventMeta(args))
+			if err != nil {
+				return err
+			}
+			fmt.Printf("%s code: %s success\n", action, code)
+			return nil
+		},
+	}
+	cmd.Flags().String(flagConfigPath, defaultConfigPath, "Config file path")
+	cmd.Flags().Bool(flagRevoke, false, "Revoke the code permanently instead of making it bindable again")
+	cmd.Flags().String(flagReason, "", "Reason kept in the binding history")
+	return cmd
+}
+
+func derefString(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
@@ -0,0 +1,103 @@
+package dao
+
+import (
+	"invite-code-service/pkg/db"
+
+	"gorm.io/gorm"
+)
+
+// Batch labels a run of codes handed out together, e.g. to one partner
+type Batch struct {
+	db.BaseModel
+
+	Name string `gorm:"type:varchar(64);not null;default:'';column:name;uniqueIndex"`
+	// Owner is who the codes were handed to
+	Owner string `gorm:"type:varchar(80);not null;default:'';column:owner"`
+	Notes string `gorm:"type:varchar(255);not null;default:'';column:notes"`
+}
+
+func (f Batch) TableName() string {
+	return "invite_code_batches"
+}
+
+func GetBatch(db *db.WrapDb, name string) (info *Batch, err error) {
+	info = &Batch{}
+	err = db.Take(info, "name = ?", name).Error
+	return
+}
+
+func GetBatches(db *db.WrapDb) (list []*Batch, err error) {
+	err = db.Order("id ASC").Find(&list).Error
+	return
+}
+
+// GetOrCreateBatch returns the batch called b.Name, creating it from b if needed.
+// The owner and notes of an existing batch are kept.
+func GetOrCreateBatch(db *db.WrapDb, b Batch) (*Batch, error) {
+	return getOrCreateBatch(db.DB, b)
+}
+
+func getOrCreateBatch(tx *gorm.DB, b Batch) (info *Batch, err error) {
+	info = &Batch{}
+	err = tx.Where(Batch{Name: b.Name}).Attrs(Batch{Owner: b.Owner, Notes: b.Notes}).FirstOrCreate(info).Error
+	return
+}
+
+// BatchStats are the InviteCodeStats of a batch and how its codes got bound over time
+type BatchStats struct {
+	InviteCodeStats
+	// BoundCodes were redeemed at least once
+	BoundCodes int64 `json:"boundCodes"`
+	// Timeline has the intervals in which codes were first redeemed, in time order
+	Timeline []BindInterval `json:"timeline"`
+}
+
+// BindInterval counts the codes first redeemed in [Start, Start+interval)
+type BindInterval struct {
+	Start      uint64 `json:"start"`
+	BoundCodes int64  `json:"boundCodes"`
+	// TotalBound is the running total of BoundCodes up to this interval
+	TotalBound int64 `json:"totalBound"`
+}
+
+// DefaultBindInterval is the timeline interval of batch stats, a day in seconds
+const DefaultBindInterval = uint64(86400)
+
+// GetBatchStats counts the codes of a batch, the timeline groups them by the
+// interval, in seconds, of their first current redemption, 0 means a day
+func GetBatchStats(db *db.WrapDb, batchId int64, interval uint64) (*BatchStats, error) {
+	if interval == 0 {
+		interval = DefaultBindInterval
+	}
+	scope := func(tx *gorm.DB) *gorm.DB {
+		return tx.Where("batch_id = ?", batchId)
+	}
+	stats, err := getInviteCodeStats(db, scope)
+	if err != nil {
+		return nil, err
+	}
+	batchStats := &BatchStats{InviteCodeStats: *stats}
+	err = db.Model(&InviteCode{}).Scopes(scope).Where("use_count > 0").Count(&batchStats.BoundCodes).Error
+	if err != nil {
+		return nil, err
+	}
+
+	err = db.Raw(`SELECT first_time - first_time % ? AS start, COUNT(*) AS bound_codes FROM (
+		SELECT MIN(r.redeem_time) AS first_time FROM invite_code_redemptions r
+		JOIN invite_codes c ON c.invite_code = r.invite_code
+		WHERE c.batch_id = ? GROUP BY r.invite_code) firsts
+		GROUP BY start ORDER BY start`, interval, batchId).Scan(&batchStats.Timeline).Error
+	if err != nil {
+		return nil, err
+	}
+	runningTotal(batchStats.Timeline)
+	return batchStats, nil
+}
+
+func runningTotal(timeline []BindInterval) {
+	total := int64(0)
+	for i := range timeline {
+		total += timeline[i].BoundCodes
+		timeline[i].TotalBound = total
+	}
+}
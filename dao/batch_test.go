@@ -0,0 +1,72 @@
+package dao_test
+
+import (
+	"fmt"
+	"invite-code-service/dao"
+	"testing"
+)
+
+func TestGetOrCreateBatchKeepsOwner(t *testing.T) {
+	store := dao.NewMemStore()
+	batch, err := store.GetOrCreateBatch(dao.Batch{Name: "kol-a", Owner: "alice", Notes: "first wave"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, err := store.GetOrCreateBatch(dao.Batch{Name: "kol-a", Owner: "bob"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again.ID != batch.ID || again.Owner != "alice" || again.Notes != "first wave" || again.CreatedAt == 0 {
+		t.Fatalf("unexpected batch: %+v", again)
+	}
+
+	batches, err := store.GetBatches()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(batches) != 1 || batches[0].Name != "kol-a" {
+		t.Fatalf("unexpected batches: %+v", batches)
+	}
+}
+
+func TestGetBatchStats(t *testing.T) {
+	store := dao.NewMemStore()
+	batch, err := store.GetOrCreateBatch(dao.Batch{Name: "kol-a"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	codes, err := store.GenerateInviteCodes(dao.GenerateSpec{CodeType: dao.DirectInviteCode, Count: 4, BatchId: batch.ID}, dao.SystemEventMeta)
+	if err != nil {
+		t.Fatal(err)
+	}
+	multi, err := store.GenerateInviteCodes(dao.GenerateSpec{CodeType: dao.DirectInviteCode, Count: 1, MaxUses: 3, BatchId: batch.ID}, dao.SystemEventMeta)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// not in the batch
+	if _, err := store.GenerateInviteCodes(dao.GenerateSpec{CodeType: dao.DirectInviteCode, Count: 2}, dao.SystemEventMeta); err != nil {
+		t.Fatal(err)
+	}
+
+	redeem := []*dao.InviteCode{codes[0], codes[1], multi[0], multi[0]}
+	for i, c := range redeem {
+		address := fmt.Sprintf("0x%d", i)
+		c.UserAddress = &address
+		c.BindTime = 1
+		if err := store.CheckBondAndUpdateInviteCode(c, dao.EventBoundBind, dao.SystemEventMeta, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	stats, err := store.GetBatchStats(batch.ID, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.TotalCodes != 5 || stats.BoundCodes != 3 || stats.Redemptions != 4 {
+		t.Fatalf("unexpected stats: %+v", stats)
+	}
+	if len(stats.Timeline) != 1 || stats.Timeline[0].BoundCodes != 3 || stats.Timeline[0].TotalBound != 3 ||
+		stats.Timeline[0].Start%dao.DefaultBindInterval != 0 {
+		t.Fatalf("unexpected timeline: %+v", stats.Timeline)
+	}
+}
@@ -0,0 +1,71 @@
+package dao
+
+import (
+	"errors"
+	"invite-code-service/pkg/db"
+
+	"gorm.io/gorm"
+)
+
+const (
+	// DefaultCampaignId is created by the campaigns migration, codes that existed
+	// before campaigns belong to it
+	DefaultCampaignId   = int64(1)
+	DefaultCampaignName = "default"
+)
+
+// Campaign namespaces invite codes and droplets, a user can bind once per campaign
+type Campaign struct {
+	db.BaseModel
+
+	Name           string `gorm:"type:varchar(64);not null;default:'';column:name;uniqueIndex"`
+	ZealySubdomain string `gorm:"type:varchar(80);not null;default:'';column:zealy_subdomain"`
+
+	// codes can be bound inside the window in unix seconds, 0 means unbounded
+	StartTime uint64 `gorm:"type:int(11);unsigned;not null;default:0;column:start_time"`
+	EndTime   uint64 `gorm:"type:int(11);unsigned;not null;default:0;column:end_time"`
+
+	TaskInviteCodeCount   uint64 `gorm:"type:int(11);unsigned;not null;default:0;column:task_invite_code_count"`
+	DirectInviteCodeCount uint64 `gorm:"type:int(11);unsigned;not null;default:0;column:direct_invite_code_count"`
+	DropletRound          uint8  `gorm:"type:tinyint(1);unsigned;not null;default:0;column:droplet_round"`
+}
+
+func (f Campaign) TableName() string {
+	return "campaigns"
+}
+
+func (f Campaign) IsOpen(now uint64) bool {
+	return f.StartTime <= now && (f.EndTime == 0 || f.EndTime > now)
+}
+
+// campaignOrDefault maps the zero value to the default campaign
+func campaignOrDefault(id int64) int64 {
+	if id == 0 {
+		return DefaultCampaignId
+	}
+	return id
+}
+
+func GetCampaign(db *db.WrapDb, name string) (info *Campaign, err error) {
+	info = &Campaign{}
+	err = db.Take(info, "name = ?", name).Error
+	return
+}
+
+func GetCampaigns(db *db.WrapDb) (list []*Campaign, err error) {
+	err = db.Order("id ASC").Find(&list).Error
+	return
+}
+
+// SaveCampaign creates c or updates the campaign with the same name
+func SaveCampaign(db *db.WrapDb, c *Campaign) error {
+	old, err := GetCampaign(db, c.Name)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+	if err == nil {
+		c.ID = old.ID
+		c.CreatedAt = old.CreatedAt
+	}
+	return db.Save(c).Error
+}
@@ -0,0 +1,103 @@
+package dao
+
+import (
+	"errors"
+	"fmt"
+	"invite-code-service/pkg/db"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"gorm.io/gorm"
+)
+
+func TestClaimTaskInviteCodeCollision(t *testing.T) {
+	const claimers = 20
+	// a file takes several connections, so the binds race for the write lock
+	// instead of queueing on a single connection
+	wrapDb, err := db.NewDB(&db.Config{Dialect: db.DialectSqlite, Path: filepath.Join(t.TempDir(), "claim.db"), Mode: "silent", MaxOpenConns: claimers})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := MigrateLatest(wrapDb); err != nil {
+		t.Fatal(err)
+	}
+	store := NewDbStore(wrapDb)
+
+	if _, err := store.GenerateInviteCodes(GenerateSpec{CodeType: TaskInviteCode, Count: claimers}, SystemEventMeta); err != nil {
+		t.Fatal(err)
+	}
+	first, err := store.GetAvailableTaskInviteCode(DefaultCampaignId)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// every claimer draws the same code before any of them binds
+	var drawn sync.WaitGroup
+	drawn.Add(claimers)
+	var lost atomic.Int64
+	codes := make([]string, claimers)
+	errs := make([]error, claimers)
+	var wg sync.WaitGroup
+	for i := 0; i < claimers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			draws := 0
+			claim := TaskCodeClaim{
+				UserAddress: fmt.Sprintf("0x%d", i),
+				DiscordId:   fmt.Sprintf("discord%d", i),
+				DiscordName: fmt.Sprintf("name%d", i),
+				UserId:      fmt.Sprintf("user%d", i),
+			}
+			c, err := claimTaskInviteCode(claim,
+				func() (*InviteCode, error) {
+					draws++
+					if draws == 1 {
+						drawn.Done()
+						drawn.Wait()
+						cp := *first
+						return &cp, nil
+					}
+					return store.GetAvailableTaskInviteCode(DefaultCampaignId)
+				},
+				func(c *InviteCode) error {
+					err := store.CheckBondAndUpdateInviteCode(c, EventBoundGen, SystemEventMeta, nil)
+					if errors.Is(err, ErrAlreadyBond) {
+						lost.Add(1)
+					}
+					return err
+				})
+			errs[i] = err
+			if c != nil {
+				codes[i] = c.InviteCode
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	seen := make(map[string]bool, claimers)
+	for i := range codes {
+		if errs[i] != nil {
+			t.Fatalf("claimer %d: %s", i, errs[i])
+		}
+		if seen[codes[i]] {
+			t.Fatalf("code %s claimed twice", codes[i])
+		}
+		seen[codes[i]] = true
+	}
+	if !seen[first.InviteCode] {
+		t.Fatalf("the contended code %s went to nobody", first.InviteCode)
+	}
+	// all but the winner of the contended code lost its bind and drew again
+	if n := lost.Load(); n < claimers-1 {
+		t.Fatalf("expect at least %d lost binds, got %d", claimers-1, n)
+	}
+
+	// the pool is empty now
+	_, err = store.ClaimTaskInviteCode(DefaultCampaignId, TaskCodeClaim{UserAddress: "0xlate"}, SystemEventMeta, nil)
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Fatalf("expect record not found, got %v", err)
+	}
+}
@@ -0,0 +1,19 @@
+package dao_test
+
+import (
+	"invite-code-service/dao"
+	"invite-code-service/pkg/db"
+	"testing"
+)
+
+func newTestDb(t testing.TB) *db.WrapDb {
+	t.Helper()
+	wrapDb, err := db.NewDB(&db.Config{Dialect: db.DialectSqlite, Path: db.SqliteMemory, Mode: "silent"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := dao.MigrateLatest(wrapDb); err != nil {
+		t.Fatal(err)
+	}
+	return wrapDb
+}
@@ -1,9 +1,10 @@
 package dao
 
 import (
+	"errors"
 	"fmt"
 	"invite-code-service/pkg/db"
-	"invite-code-service/pkg/utils"
+	"time"
 
 	"gorm.io/gorm"
 )
@@ -12,7 +13,8 @@ type DropletCode struct {
 	db.BaseModel
 
 	InviteCode   string `gorm:"type:varchar(10);not null;default:'';column:invite_code;uniqueIndex:code_round_index"`
-	Round        uint8  `gorm:"type:tinyint(1);unsigned;not null;default:0;column:round;uniqueIndex:code_round_index"`
+	CampaignId   int64  `gorm:"not null;default:0;column:campaign_id;index:campaign_round_index"`
+	Round        uint8  `gorm:"type:tinyint(1);unsigned;not null;default:0;column:round;uniqueIndex:code_round_index;index:campaign_round_index"`
 	DropletIndex uint8  `gorm:"type:tinyint(1);unsigned;not null;default:0;column:droplet_index;uniqueIndex:code_round_index"`
 }
 
@@ -20,31 +22,42 @@ func (f DropletCode) TableName() string {
 	return "droplet_codes"
 }
 
+var ErrNotEnoughDropletCodes = errors.New("not enough available droplet invite codes")
+
 type DropletCodeWithStatus struct {
 	InviteCode   string
 	Round        uint8
 	DropletIndex uint8
-	Used         bool
+	Used         bool // bound or revoked
+	Expired      bool
+}
+
+// GetInviteCodeDroplets returns the droplets a code is assigned to, by round
+func GetInviteCodeDroplets(db *db.WrapDb, code string) (list []*DropletCode, err error) {
+	err = db.Where("invite_code = ?", code).Order("round ASC, droplet_index ASC").Find(&list).Error
+	return
 }
 
-func GetLatestDropletCodesWithStatus(db *db.WrapDb) ([]*DropletCodeWithStatus, error) {
-	var maxRound uint8
-	err := db.Model(&DropletCode{}).
-		Select("MAX(round)").
-		Scan(&maxRound).Error
+// GetLatestDropletCodesWithStatus returns the open round of a campaign with its
+// droplet codes, a nil round if no round is open
+func GetLatestDropletCodesWithStatus(db *db.WrapDb, campaignId int64) (*DropletRound, []*DropletCodeWithStatus, error) {
+	openRound, err := GetOpenDropletRound(db, campaignId)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get max round: %w", err)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil, nil
+		}
+		return nil, nil, fmt.Errorf("failed to get open round: %w", err)
 	}
 
 	var dropletCodes []DropletCode
-	err = db.Where("round = ?", maxRound).
+	err = db.Scopes(inCampaign(campaignId)).Where("round = ?", openRound.Round).
 		Find(&dropletCodes).Error
 	if err != nil {
-		return nil, fmt.Errorf("failed to get droplet codes: %w", err)
+		return nil, nil, fmt.Errorf("failed to get droplet codes: %w", err)
 	}
 
 	if len(dropletCodes) == 0 {
-		return nil, nil
+		return openRound, nil, nil
 	}
 
 	inviteCodes := make([]string, 0, len(dropletCodes))
@@ -55,34 +68,51 @@ func GetLatestDropletCodesWithStatus(db *db.WrapDb) ([]*DropletCodeWithStatus, e
 	var usedCodes []string
 	err = db.Model(&InviteCode{}).
 		Where("invite_code IN ?", inviteCodes).
-		Where("bind_time > 0").
+		Where("bind_time > 0 OR revoke_time > 0").
 		Pluck("invite_code", &usedCodes).Error
 	if err != nil {
-		return nil, fmt.Errorf("failed to query invite code usage: %w", err)
+		return nil, nil, fmt.Errorf("failed to query invite code usage: %w", err)
+	}
+
+	var expiredCodes []string
+	err = db.Model(&InviteCode{}).
+		Where("invite_code IN ?", inviteCodes).
+		Scopes(expiredAt(uint64(time.Now().Unix()))).
+		Pluck("invite_code", &expiredCodes).Error
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to query invite code expiry: %w", err)
 	}
 
 	usedSet := make(map[string]struct{}, len(usedCodes))
 	for _, code := range usedCodes {
 		usedSet[code] = struct{}{}
 	}
+	expiredSet := make(map[string]struct{}, len(expiredCodes))
+	for _, code := range expiredCodes {
+		expiredSet[code] = struct{}{}
+	}
 
 	var result []*DropletCodeWithStatus
 	for _, dc := range dropletCodes {
 		_, used := usedSet[dc.InviteCode]
+		_, expired := expiredSet[dc.InviteCode]
 		result = append(result, &DropletCodeWithStatus{
 			InviteCode:   dc.InviteCode,
 			Round:        dc.Round,
 			DropletIndex: dc.DropletIndex,
 			Used:         used,
+			Expired:      expired,
 		})
 	}
 
-	return result, nil
+	return openRound, result, nil
 }
 
-func GenerateDropletCodes(db *db.WrapDb, round uint8) error {
+// assignDropletCodes assigns water codes of a campaign to the droplets of
+// round, unless the round already has droplets
+func assignDropletCodes(tx *gorm.DB, campaignId int64, round uint8, shape DropletShape, now uint64) error {
 	var dropletCodes []DropletCode
-	err := db.Where("round = ?", round).
+	err := tx.Scopes(inCampaign(campaignId)).Where("round = ?", round).
 		Find(&dropletCodes).Error
 	if err != nil {
 		return fmt.Errorf("failed to get droplet codes: %w", err)
@@ -91,41 +121,43 @@ func GenerateDropletCodes(db *db.WrapDb, round uint8) error {
 		return nil
 	}
 
-	return db.Transaction(func(tx *gorm.DB) error {
-
-		// Fetch enough InviteCodes
-		totalNeeded := utils.DropletCount * utils.CodesPerDroplet
-		var availableCodes []InviteCode
-		if err := tx.
-			Where("code_type = 2 AND bind_time = 0").
-			Order("id ASC").
-			Limit(totalNeeded).
-			Find(&availableCodes).Error; err != nil {
-			return fmt.Errorf("failed to fetch droplet invite codes: %w", err)
-		}
+	// Fetch enough InviteCodes
+	totalNeeded := shape.Codes()
+	var availableCodes []InviteCode
+	if err := tx.
+		Scopes(inCampaign(campaignId), validAt(now)).
+		Where("code_type = 2 AND bind_time = 0 AND revoke_time = 0").
+		Order("id ASC").
+		Limit(totalNeeded).
+		Find(&availableCodes).Error; err != nil {
+		return fmt.Errorf("failed to fetch droplet invite codes: %w", err)
+	}
 
-		if len(availableCodes) < totalNeeded {
-			return fmt.Errorf("not enough available droplet invite codes")
-		}
+	if len(availableCodes) < totalNeeded {
+		return ErrNotEnoughDropletCodes
+	}
 
-		// Assign codes and create droplets
-		cursor := 0
-		for dropletIdx := uint8(0); dropletIdx < utils.DropletCount; dropletIdx++ {
-			for i := 0; i < utils.CodesPerDroplet; i++ {
-				code := availableCodes[cursor]
-				cursor++
-
-				droplet := DropletCode{
-					InviteCode:   code.InviteCode,
-					Round:        round,
-					DropletIndex: dropletIdx,
-				}
-				if err := tx.Create(&droplet).Error; err != nil {
-					return fmt.Errorf("failed to create droplet: %w", err)
-				}
+	// Assign codes and create droplets
+	cursor := 0
+	for dropletIdx := uint8(0); dropletIdx < shape.Droplets; dropletIdx++ {
+		for i := uint8(0); i < shape.CodesPerDroplet; i++ {
+			code := availableCodes[cursor]
+			cursor++
+
+			droplet := DropletCode{
+				InviteCode:   code.InviteCode,
+				CampaignId:   campaignId,
+				Round:        round,
+				DropletIndex: dropletIdx,
+			}
+			if err := tx.Create(&droplet).Error; err != nil {
+				return fmt.Errorf("failed to create droplet: %w", err)
+			}
+			if err := createInviteCodeEvent(tx, &code, EventDropletAssigned, SystemEventMeta); err != nil {
+				return fmt.Errorf("failed to create droplet event: %w", err)
 			}
 		}
+	}
 
-		return nil
-	})
+	return nil
 }
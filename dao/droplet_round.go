@@ -0,0 +1,150 @@
+package dao
+
+import (
+	"errors"
+	"invite-code-service/pkg/db"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrDropletRoundOrder  = errors.New("droplet round not after the last one")
+	ErrNoOpenDropletRound = errors.New("no open droplet round")
+)
+
+// DropletShape is how many droplets a round has and how many codes each holds
+type DropletShape struct {
+	Droplets        uint8
+	CodesPerDroplet uint8
+}
+
+// DefaultDropletShape is the shape of rounds opened without one
+var DefaultDropletShape = DropletShape{Droplets: 5, CodesPerDroplet: 5}
+
+// OrDefault fills the zero fields of s from DefaultDropletShape
+func (s DropletShape) OrDefault() DropletShape {
+	if s.Droplets == 0 {
+		s.Droplets = DefaultDropletShape.Droplets
+	}
+	if s.CodesPerDroplet == 0 {
+		s.CodesPerDroplet = DefaultDropletShape.CodesPerDroplet
+	}
+	return s
+}
+
+// Codes is how many water codes a round of the shape takes
+func (s DropletShape) Codes() int {
+	return int(s.Droplets) * int(s.CodesPerDroplet)
+}
+
+// DropletRound is a round of droplets of a campaign, the open one is served.
+// Rounds open in order and at most one per campaign is open.
+type DropletRound struct {
+	db.BaseModel
+
+	CampaignId int64  `gorm:"not null;default:0;column:campaign_id;uniqueIndex:droplet_round_index"`
+	Round      uint8  `gorm:"type:tinyint(1);unsigned;not null;default:0;column:round;uniqueIndex:droplet_round_index"`
+	OpenTime   uint64 `gorm:"type:int(11);unsigned;not null;default:0;column:open_time"`
+	// CloseTime is 0 while the round is open
+	CloseTime uint64 `gorm:"type:int(11);unsigned;not null;default:0;column:close_time"`
+
+	Droplets        uint8 `gorm:"type:tinyint(1);unsigned;not null;default:0;column:droplets"`
+	CodesPerDroplet uint8 `gorm:"type:tinyint(1);unsigned;not null;default:0;column:codes_per_droplet"`
+}
+
+func (f DropletRound) TableName() string {
+	return "droplet_rounds"
+}
+
+func (f DropletRound) IsOpen() bool {
+	return f.CloseTime == 0
+}
+
+func (f DropletRound) Shape() DropletShape {
+	return DropletShape{Droplets: f.Droplets, CodesPerDroplet: f.CodesPerDroplet}
+}
+
+func GetDropletRounds(db *db.WrapDb, campaignId int64) (list []*DropletRound, err error) {
+	err = db.Scopes(inCampaign(campaignId)).Order("round ASC").Find(&list).Error
+	return
+}
+
+// GetOpenDropletRound returns gorm.ErrRecordNotFound if no round of the campaign is open
+func GetOpenDropletRound(db *db.WrapDb, campaignId int64) (info *DropletRound, err error) {
+	info = &DropletRound{}
+	err = db.Scopes(inCampaign(campaignId)).Where("close_time = 0").Take(info).Error
+	return
+}
+
+// OpenDropletRound closes the open round of a campaign and opens round with
+// droplets of available water codes, zero fields of shape take the default.
+// round must come after the last round, rounds skipped never open.
+func OpenDropletRound(db *db.WrapDb, campaignId int64, round uint8, shape DropletShape) (*DropletRound, error) {
+	campaignId = campaignOrDefault(campaignId)
+	now := uint64(time.Now().Unix())
+	shape = shape.OrDefault()
+	opened := &DropletRound{CampaignId: campaignId, Round: round, OpenTime: now,
+		Droplets: shape.Droplets, CodesPerDroplet: shape.CodesPerDroplet}
+	err := db.Transaction(func(tx *gorm.DB) error {
+		last := &DropletRound{}
+		err := tx.Scopes(inCampaign(campaignId)).Order("round DESC").Take(last).Error
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+		if err == nil && round <= last.Round {
+			return ErrDropletRoundOrder
+		}
+
+		err = tx.Model(&DropletRound{}).Scopes(inCampaign(campaignId)).Where("close_time = 0").
+			Update("close_time", now).Error
+		if err != nil {
+			return err
+		}
+		if err := assignDropletCodes(tx, campaignId, round, shape, now); err != nil {
+			return err
+		}
+		return tx.Create(opened).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return opened, nil
+}
+
+// CloseDropletRound closes the open round of a campaign, its droplets are no
+// longer served until the next round opens
+func CloseDropletRound(db *db.WrapDb, campaignId int64) (*DropletRound, error) {
+	closed := &DropletRound{}
+	err := db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Scopes(inCampaign(campaignId)).Where("close_time = 0").Take(closed).Error
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrNoOpenDropletRound
+			}
+			return err
+		}
+		closed.CloseTime = uint64(time.Now().Unix())
+		result := tx.Model(closed).Where("close_time = 0").Update("close_time", closed.CloseTime)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrNoOpenDropletRound
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return closed, nil
+}
+
+// NextDropletRound is the round to open after rounds, sorted by round, 0 if
+// there are none
+func NextDropletRound(rounds []*DropletRound) uint8 {
+	if len(rounds) == 0 {
+		return 0
+	}
+	return rounds[len(rounds)-1].Round + 1
+}
@@ -0,0 +1,43 @@
+package dao
+
+import (
+	"errors"
+	"invite-code-service/pkg/db"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// EventCursor is the last invite code event a consumer, e.g. the discord role
+// sync, has handled, so it resumes after it on restart
+type EventCursor struct {
+	db.BaseModel
+
+	Name    string `gorm:"type:varchar(128);not null;default:'';column:name;uniqueIndex"`
+	EventId int64  `gorm:"not null;default:0;column:event_id"`
+}
+
+func (f EventCursor) TableName() string {
+	return "invite_code_event_cursors"
+}
+
+// GetEventCursor returns the event id saved for name, 0 if none was saved
+func GetEventCursor(db *db.WrapDb, name string) (int64, error) {
+	cursor := &EventCursor{}
+	err := db.Take(cursor, "name = ?", name).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return cursor.EventId, nil
+}
+
+// SaveEventCursor saves eventId as the last event handled by name
+func SaveEventCursor(db *db.WrapDb, name string, eventId int64) error {
+	return db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "name"}},
+		DoUpdates: clause.AssignmentColumns([]string{"event_id", "update_time"}),
+	}).Create(&EventCursor{Name: name, EventId: eventId}).Error
+}
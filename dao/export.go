@@ -0,0 +1,104 @@
+package dao
+
+import (
+	"invite-code-service/pkg/db"
+
+	"gorm.io/gorm"
+)
+
+const exportPageSize = 1000
+
+// ExportFilter selects the codes of an export, zero fields match everything
+type ExportFilter struct {
+	// CampaignId 0 exports every campaign
+	CampaignId int64
+	CodeTypes  []uint8
+	// Bound selects codes redeemed at least once, or never redeemed
+	Bound *bool
+	// bind_time in [BindFrom, BindTo), BindTo 0 is unbounded
+	BindFrom uint64
+	BindTo   uint64
+	// DropletRound selects the codes assigned to a droplet of the round
+	DropletRound *uint8
+	// BatchId selects the codes of a batch
+	BatchId int64
+}
+
+func (f *ExportFilter) scope(tx *gorm.DB) *gorm.DB {
+	if f.CampaignId != 0 {
+		tx = tx.Where("campaign_id = ?", f.CampaignId)
+	}
+	if len(f.CodeTypes) > 0 {
+		// a []uint8 would be bound as bytes
+		codeTypes := make([]int, 0, len(f.CodeTypes))
+		for _, t := range f.CodeTypes {
+			codeTypes = append(codeTypes, int(t))
+		}
+		tx = tx.Where("code_type IN ?", codeTypes)
+	}
+	if f.Bound != nil {
+		if *f.Bound {
+			tx = tx.Where("use_count > 0")
+		} else {
+			tx = tx.Where("use_count = 0")
+		}
+	}
+	if f.BindFrom > 0 {
+		tx = tx.Where("bind_time >= ?", f.BindFrom)
+	}
+	if f.BindTo > 0 {
+		tx = tx.Where("bind_time < ?", f.BindTo)
+	}
+	if f.BatchId != 0 {
+		tx = tx.Where("batch_id = ?", f.BatchId)
+	}
+	if f.DropletRound != nil {
+		tx = tx.Where("invite_code IN (SELECT invite_code FROM droplet_codes WHERE round = ?)", *f.DropletRound)
+	}
+	return tx
+}
+
+// ExportedCode is a code with the droplets it is assigned to
+type ExportedCode struct {
+	*InviteCode
+	Droplets []*DropletCode
+}
+
+// ExportInviteCodes calls fn with every code matching filter in id order. Codes
+// are read a page at a time by id, so exports of any size run in constant memory
+// and don't hold a transaction open.
+func ExportInviteCodes(db *db.WrapDb, filter ExportFilter, fn func(*ExportedCode) error) error {
+	lastId := int64(0)
+	for {
+		var page []*InviteCode
+		err := db.Scopes(filter.scope).Where("id > ?", lastId).
+			Order("id ASC").Limit(exportPageSize).Find(&page).Error
+		if err != nil {
+			return err
+		}
+		if len(page) == 0 {
+			return nil
+		}
+
+		codes := make([]string, 0, len(page))
+		for _, c := range page {
+			codes = append(codes, c.InviteCode)
+		}
+		var droplets []*DropletCode
+		err = db.Where("invite_code IN ?", codes).Order("round ASC, droplet_index ASC").Find(&droplets).Error
+		if err != nil {
+			return err
+		}
+		byCode := make(map[string][]*DropletCode)
+		for _, d := range droplets {
+			byCode[d.InviteCode] = append(byCode[d.InviteCode], d)
+		}
+
+		for _, c := range page {
+			if err := fn(&ExportedCode{InviteCode: c, Droplets: byCode[c.InviteCode]}); err != nil {
+				return err
+			}
+		}
+		lastId = page[len(page)-1].ID
+	}
+}
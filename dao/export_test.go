@@ -0,0 +1,82 @@
+package dao_test
+
+import (
+	"invite-code-service/dao"
+	"testing"
+)
+
+func TestExportInviteCodes(t *testing.T) {
+	store := dao.NewMemStore()
+	// more than one page of codes
+	water, err := store.GenerateInviteCodes(dao.GenerateSpec{CodeType: dao.WaterInviteCode, Count: 1100}, dao.SystemEventMeta)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.OpenDropletRound(dao.DefaultCampaignId, 0, dao.DropletShape{}); err != nil {
+		t.Fatal(err)
+	}
+	direct, err := store.GenerateInviteCodes(dao.GenerateSpec{CodeType: dao.DirectInviteCode, Count: 2}, dao.SystemEventMeta)
+	if err != nil {
+		t.Fatal(err)
+	}
+	address := "0xabc"
+	bound := direct[1]
+	bound.UserAddress = &address
+	bound.BindTime = 1000
+	if err := store.CheckBondAndUpdateInviteCode(bound, dao.EventBoundCli, dao.SystemEventMeta, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	export := func(filter dao.ExportFilter) []*dao.ExportedCode {
+		var list []*dao.ExportedCode
+		err := store.ExportInviteCodes(filter, func(c *dao.ExportedCode) error {
+			if len(list) > 0 && list[len(list)-1].ID >= c.ID {
+				t.Fatalf("codes out of id order: %d after %d", c.ID, list[len(list)-1].ID)
+			}
+			list = append(list, c)
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return list
+	}
+
+	if all := export(dao.ExportFilter{}); len(all) != 1102 {
+		t.Fatalf("expect 1102 codes, got %d", len(all))
+	}
+	if list := export(dao.ExportFilter{CodeTypes: []uint8{dao.DirectInviteCode}}); len(list) != 2 {
+		t.Fatalf("expect 2 direct codes, got %d", len(list))
+	}
+
+	yes, no := true, false
+	list := export(dao.ExportFilter{Bound: &yes})
+	if len(list) != 1 || list[0].InviteCode.InviteCode != bound.InviteCode || *list[0].UserAddress != address {
+		t.Fatalf("unexpected bound codes: %+v", list)
+	}
+	if list := export(dao.ExportFilter{Bound: &no, CodeTypes: []uint8{dao.DirectInviteCode}}); len(list) != 1 {
+		t.Fatalf("expect 1 unbound direct code, got %d", len(list))
+	}
+	if list := export(dao.ExportFilter{BindFrom: 1000, BindTo: 1001}); len(list) != 1 {
+		t.Fatalf("expect 1 code bound in range, got %d", len(list))
+	}
+	if list := export(dao.ExportFilter{BindFrom: 1001}); len(list) != 0 {
+		t.Fatalf("expect no code bound after range, got %d", len(list))
+	}
+
+	round := uint8(0)
+	list = export(dao.ExportFilter{DropletRound: &round})
+	if len(list) != 25 || list[0].InviteCode.InviteCode != water[0].InviteCode {
+		t.Fatalf("expect the 25 round 0 droplet codes, got %d", len(list))
+	}
+	if len(list[0].Droplets) != 1 || list[0].Droplets[0].Round != 0 {
+		t.Fatalf("unexpected droplets: %+v", list[0].Droplets)
+	}
+	round = 1
+	if list := export(dao.ExportFilter{DropletRound: &round}); len(list) != 0 {
+		t.Fatalf("expect no round 1 droplet codes, got %d", len(list))
+	}
+	if list := export(dao.ExportFilter{CampaignId: dao.DefaultCampaignId + 1}); len(list) != 0 {
+		t.Fatalf("expect no codes of another campaign, got %d", len(list))
+	}
+}
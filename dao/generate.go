@@ -0,0 +1,176 @@
+package dao
+
+import (
+	"errors"
+	"fmt"
+	"invite-code-service/pkg/db"
+	"invite-code-service/pkg/utils"
+
+	"gorm.io/gorm"
+)
+
+const DefaultGenBatchSize = 500
+
+// GenerateSpec describes a run of new invite codes
+type GenerateSpec struct {
+	CampaignId int64
+	CodeType   uint8
+	Count      int64
+	MaxUses    uint64
+	ValidFrom  uint64
+	ExpiresAt  uint64
+	BatchId    int64
+	// Batch labels the codes when BatchId is 0, the batch is created in the
+	// transaction of the first insert if it doesn't exist
+	Batch *Batch
+
+	// rows per multi-row insert, DefaultGenBatchSize if 0
+	BatchSize int
+	// NewCode makes a random code, utils.GenerateInviteCode if nil
+	NewCode func() (string, error)
+	// Progress is called after every committed batch
+	Progress func(done, total int64)
+}
+
+func (s *GenerateSpec) batchSize() int {
+	if s.BatchSize <= 0 {
+		return DefaultGenBatchSize
+	}
+	return s.BatchSize
+}
+
+func (s *GenerateSpec) newCode() (string, error) {
+	if s.NewCode == nil {
+		return utils.GenerateInviteCode()
+	}
+	return s.NewCode()
+}
+
+// newCodes returns n codes distinct from each other and from skip
+func (s *GenerateSpec) newCodes(n int, skip map[string]bool) ([]string, error) {
+	codes := make([]string, 0, n)
+	seen := make(map[string]bool, n)
+	for tries := 0; len(codes) < n; tries++ {
+		if tries >= n*maxGenRetry {
+			return nil, fmt.Errorf("no unused invite code after %d tries", tries)
+		}
+		code, err := s.newCode()
+		if err != nil {
+			return nil, err
+		}
+		if seen[code] || skip[code] {
+			continue
+		}
+		seen[code] = true
+		codes = append(codes, code)
+	}
+	return codes, nil
+}
+
+func (s *GenerateSpec) inviteCode(code string) *InviteCode {
+	return &InviteCode{
+		InviteCode: code,
+		CampaignId: campaignOrDefault(s.CampaignId),
+		CodeType:   s.CodeType,
+		MaxUses:    max(s.MaxUses, 1),
+		ValidFrom:  s.ValidFrom,
+		ExpiresAt:  s.ExpiresAt,
+		BatchId:    s.BatchId,
+	}
+}
+
+// GenerateInviteCodes inserts spec.Count new codes with their generated events.
+// Every batch is one multi-row insert committed in its own transaction, a batch
+// hitting the unique index is retried with the taken codes replaced, so
+// concurrent generators never fail on each other. The codes committed before an
+// error are returned with it.
+func GenerateInviteCodes(db *db.WrapDb, spec GenerateSpec, meta EventMeta) ([]*InviteCode, error) {
+	generated := make([]*InviteCode, 0, spec.Count)
+	for int64(len(generated)) < spec.Count {
+		n := int(min(int64(spec.batchSize()), spec.Count-int64(len(generated))))
+		batch, err := generateBatch(db, &spec, n, meta)
+		if err != nil {
+			return generated, err
+		}
+		generated = append(generated, batch...)
+		if spec.Progress != nil {
+			spec.Progress(int64(len(generated)), spec.Count)
+		}
+	}
+	return generated, nil
+}
+
+func generateBatch(db *db.WrapDb, spec *GenerateSpec, n int, meta EventMeta) ([]*InviteCode, error) {
+	codes, err := spec.newCodes(n, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	for retry := 0; ; retry++ {
+		var batchId int64
+		batch := make([]*InviteCode, 0, n)
+		events := make([]*InviteCodeEvent, 0, n)
+		err := db.Transaction(func(tx *gorm.DB) error {
+			batchId = spec.BatchId
+			if batchId == 0 && spec.Batch != nil {
+				info, err := getOrCreateBatch(tx, *spec.Batch)
+				if err != nil {
+					return err
+				}
+				batchId = info.ID
+			}
+			for _, code := range codes {
+				c := spec.inviteCode(code)
+				c.BatchId = batchId
+				batch = append(batch, c)
+				events = append(events, newInviteCodeEvent(c, EventGenerated, meta))
+			}
+
+			if err := tx.Create(&batch).Error; err != nil {
+				return err
+			}
+			return tx.Create(&events).Error
+		})
+		if err == nil {
+			spec.BatchId = batchId
+			return batch, nil
+		}
+		if !errors.Is(err, gorm.ErrDuplicatedKey) || retry >= maxGenRetry {
+			return nil, err
+		}
+
+		// replace the taken codes, or all of them when the conflicting row isn't visible yet
+		var taken []string
+		if err := db.Model(&InviteCode{}).Where("invite_code IN ?", codes).Pluck("invite_code", &taken).Error; err != nil {
+			return nil, err
+		}
+		if len(taken) == 0 {
+			taken = codes
+		}
+		codes, err = replaceCodes(spec, codes, taken)
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// replaceCodes swaps the taken codes for new ones
+func replaceCodes(spec *GenerateSpec, codes, taken []string) ([]string, error) {
+	takenSet := make(map[string]bool, len(taken))
+	for _, code := range taken {
+		takenSet[code] = true
+	}
+	skip := make(map[string]bool, len(codes))
+	kept := make([]string, 0, len(codes))
+	for _, code := range codes {
+		skip[code] = true
+		if !takenSet[code] {
+			kept = append(kept, code)
+		}
+	}
+	fresh, err := spec.newCodes(len(codes)-len(kept), skip)
+	if err != nil {
+		return nil, err
+	}
+	return append(kept, fresh...), nil
+}
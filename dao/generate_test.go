@@ -0,0 +1,186 @@
+package dao_test
+
+import (
+	"errors"
+	"fmt"
+	"invite-code-service/dao"
+	"testing"
+
+	"gorm.io/gorm"
+)
+
+func TestGenerateInviteCodes(t *testing.T) {
+	store := dao.NewMemStore()
+	var progress []int64
+	codes, err := store.GenerateInviteCodes(dao.GenerateSpec{
+		CodeType:  dao.WaterInviteCode,
+		Count:     1200,
+		BatchSize: 500,
+		ExpiresAt: 100,
+		Progress: func(done, total int64) {
+			if total != 1200 {
+				t.Fatalf("unexpected total %d", total)
+			}
+			progress = append(progress, done)
+		},
+	}, dao.SystemEventMeta)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(codes) != 1200 {
+		t.Fatalf("expect 1200 codes, got %d", len(codes))
+	}
+	if fmt.Sprint(progress) != "[500 1000 1200]" {
+		t.Fatalf("unexpected progress %v", progress)
+	}
+
+	count, err := store.GetInviteCodeCount(dao.DefaultCampaignId, dao.WaterInviteCode)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 1200 {
+		t.Fatalf("expect 1200 stored codes, got %d", count)
+	}
+	stored, err := store.GetInviteCode(codes[1100].InviteCode)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.MaxUses != 1 || stored.ExpiresAt != 100 || stored.CampaignId != dao.DefaultCampaignId {
+		t.Fatalf("unexpected code: %+v", stored)
+	}
+	events, err := store.GetInviteCodeEvents(stored.InviteCode)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 1 || events[0].EventType != dao.EventGenerated {
+		t.Fatalf("unexpected events: %+v", events)
+	}
+}
+
+func TestGenerateInviteCodesCollision(t *testing.T) {
+	store := dao.NewMemStore()
+	for _, code := range []string{"CODE0001", "CODE0003"} {
+		if err := store.CreateInviteCode(&dao.InviteCode{InviteCode: code}, dao.SystemEventMeta); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// repeats and codes already stored are replaced by the next ones
+	sequence := []string{"CODE0001", "CODE0002", "CODE0002", "CODE0003", "CODE0004", "CODE0005", "CODE0006"}
+	next := 0
+	codes, err := store.GenerateInviteCodes(dao.GenerateSpec{
+		CodeType:  dao.DirectInviteCode,
+		Count:     3,
+		BatchSize: 2,
+		NewCode: func() (string, error) {
+			if next >= len(sequence) {
+				return "", fmt.Errorf("sequence exhausted")
+			}
+			next++
+			return sequence[next-1], nil
+		},
+	}, dao.SystemEventMeta)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	seen := make(map[string]bool)
+	for _, c := range codes {
+		if c.InviteCode == "CODE0001" || c.InviteCode == "CODE0003" || seen[c.InviteCode] {
+			t.Fatalf("unexpected code %s in %+v", c.InviteCode, codes)
+		}
+		seen[c.InviteCode] = true
+	}
+	count, err := store.GetInviteCodeCount(dao.DefaultCampaignId, dao.DirectInviteCode)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 3 {
+		t.Fatalf("expect 3 direct codes, got %d", count)
+	}
+}
+
+func TestGenerateInviteCodesBatch(t *testing.T) {
+	store := dao.NewMemStore()
+	batch, err := store.GetOrCreateBatch(dao.Batch{Name: "partner-a"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, err := store.GetOrCreateBatch(dao.Batch{Name: "partner-a"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if batch.ID == 0 || again.ID != batch.ID {
+		t.Fatalf("expect the same batch, got %d and %d", batch.ID, again.ID)
+	}
+	other, err := store.GetOrCreateBatch(dao.Batch{Name: "partner-b"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if other.ID == batch.ID {
+		t.Fatalf("expect a new batch, got %d", other.ID)
+	}
+
+	codes, err := store.GenerateInviteCodes(dao.GenerateSpec{CodeType: dao.DirectInviteCode, Count: 3, BatchId: batch.ID}, dao.SystemEventMeta)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stored, err := store.GetInviteCode(codes[2].InviteCode)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.BatchId != batch.ID {
+		t.Fatalf("expect batch %d, got %d", batch.ID, stored.BatchId)
+	}
+}
+
+func TestGenerateInviteCodesNewBatch(t *testing.T) {
+	wrapDb := newTestDb(t)
+	// a failed insert leaves no batch behind
+	failInsert := true
+	err := wrapDb.Callback().Create().Before("gorm:create").Register("test:fail_insert", func(tx *gorm.DB) {
+		if _, ok := tx.Statement.Dest.(*[]*dao.InviteCode); ok && failInsert {
+			tx.AddError(errors.New("insert failed"))
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	spec := dao.GenerateSpec{CodeType: dao.DirectInviteCode, Count: 3, BatchSize: 2, Batch: &dao.Batch{Name: "partner-a", Owner: "alice"}}
+	if _, err := dao.GenerateInviteCodes(wrapDb, spec, dao.SystemEventMeta); err == nil {
+		t.Fatal("expect the generation to fail")
+	}
+	if _, err := dao.GetBatch(wrapDb, "partner-a"); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Fatalf("expect no batch, got %v", err)
+	}
+
+	failInsert = false
+	codes, err := dao.GenerateInviteCodes(wrapDb, spec, dao.SystemEventMeta)
+	if err != nil {
+		t.Fatal(err)
+	}
+	batch, err := dao.GetBatch(wrapDb, "partner-a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if batch.Owner != "alice" {
+		t.Fatalf("unexpected batch: %+v", batch)
+	}
+	for _, c := range codes {
+		if c.BatchId != batch.ID {
+			t.Fatalf("expect batch %d, got %d", batch.ID, c.BatchId)
+		}
+	}
+}
+
+func TestParseCodeType(t *testing.T) {
+	for _, codeType := range []uint8{dao.TaskInviteCode, dao.DirectInviteCode, dao.WaterInviteCode, dao.ReferralInviteCode} {
+		parsed, err := dao.ParseCodeType(dao.CodeTypeName(codeType))
+		if err != nil || parsed != codeType {
+			t.Fatalf("code type %d parsed as %d, %v", codeType, parsed, err)
+		}
+	}
+	if _, err := dao.ParseCodeType("gold"); err == nil {
+		t.Fatal("expect unknown code type error")
+	}
+}
@@ -0,0 +1,74 @@
+package dao
+
+import (
+	"invite-code-service/pkg/db"
+
+	"gorm.io/gorm"
+)
+
+const importChunkSize = 1000
+
+// GetExistingInviteCodes returns the codes already stored out of codes
+func GetExistingInviteCodes(db *db.WrapDb, codes []string) ([]string, error) {
+	var existing []string
+	for start := 0; start < len(codes); start += importChunkSize {
+		var chunk []string
+		err := db.Model(&InviteCode{}).Where("invite_code IN ?", codes[start:min(start+importChunkSize, len(codes))]).
+			Pluck("invite_code", &chunk).Error
+		if err != nil {
+			return nil, err
+		}
+		existing = append(existing, chunk...)
+	}
+	return existing, nil
+}
+
+// ImportedCode is an externally supplied code and the name of the batch it is
+// handed out in, none if empty
+type ImportedCode struct {
+	Code  *InviteCode
+	Batch string
+}
+
+// ImportInviteCodes inserts externally supplied codes with their imported
+// events in one transaction, nothing is inserted if any of them is taken. The
+// batches named by the codes are created from batchInfo in that transaction
+// too, the owner and notes of existing ones are kept.
+func ImportInviteCodes(db *db.WrapDb, codes []ImportedCode, batchInfo Batch, meta EventMeta) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		batchIds := make(map[string]int64)
+		for _, c := range codes {
+			if len(c.Batch) == 0 {
+				continue
+			}
+			if _, ok := batchIds[c.Batch]; !ok {
+				batchInfo.Name = c.Batch
+				batch, err := getOrCreateBatch(tx, batchInfo)
+				if err != nil {
+					return err
+				}
+				batchIds[c.Batch] = batch.ID
+			}
+			c.Code.BatchId = batchIds[c.Batch]
+		}
+
+		for start := 0; start < len(codes); start += importChunkSize {
+			chunk := make([]*InviteCode, 0, min(importChunkSize, len(codes)-start))
+			events := make([]*InviteCodeEvent, 0, cap(chunk))
+			for _, ic := range codes[start:min(start+importChunkSize, len(codes))] {
+				c := ic.Code
+				c.CampaignId = campaignOrDefault(c.CampaignId)
+				c.MaxUses = max(c.MaxUses, 1)
+				chunk = append(chunk, c)
+				events = append(events, newInviteCodeEvent(c, EventImported, meta))
+			}
+			if err := tx.Create(&chunk).Error; err != nil {
+				return err
+			}
+			if err := tx.Create(&events).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
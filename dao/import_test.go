@@ -0,0 +1,71 @@
+package dao_test
+
+import (
+	"errors"
+	"invite-code-service/dao"
+	"testing"
+
+	"gorm.io/gorm"
+)
+
+func TestImportInviteCodes(t *testing.T) {
+	store := dao.NewMemStore()
+	if err := store.CreateInviteCode(&dao.InviteCode{InviteCode: "TAKEN001", CodeType: dao.DirectInviteCode}, dao.SystemEventMeta); err != nil {
+		t.Fatal(err)
+	}
+	existing, err := store.GetExistingInviteCodes([]string{"PARTNER1", "TAKEN001"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(existing) != 1 || existing[0] != "TAKEN001" {
+		t.Fatalf("unexpected existing codes: %v", existing)
+	}
+
+	// one taken code fails the whole import, the batch included
+	batchInfo := dao.Batch{Owner: "partner"}
+	err = store.ImportInviteCodes([]dao.ImportedCode{
+		{Code: &dao.InviteCode{InviteCode: "PARTNER1", CodeType: dao.DirectInviteCode}, Batch: "partner-1"},
+		{Code: &dao.InviteCode{InviteCode: "TAKEN001", CodeType: dao.DirectInviteCode}},
+	}, batchInfo, dao.SystemEventMeta)
+	if !errors.Is(err, gorm.ErrDuplicatedKey) {
+		t.Fatalf("expect ErrDuplicatedKey, got %v", err)
+	}
+	if _, err := store.GetInviteCode("PARTNER1"); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Fatalf("expect PARTNER1 rolled back, got %v", err)
+	}
+	if _, err := store.GetBatch("partner-1"); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Fatalf("expect the batch rolled back, got %v", err)
+	}
+
+	err = store.ImportInviteCodes([]dao.ImportedCode{
+		{Code: &dao.InviteCode{InviteCode: "PARTNER1", CodeType: dao.DirectInviteCode, ExpiresAt: 100}, Batch: "partner-1"},
+		{Code: &dao.InviteCode{InviteCode: "PARTNER2", CodeType: dao.TaskInviteCode}},
+	}, batchInfo, dao.SystemEventMeta)
+	if err != nil {
+		t.Fatal(err)
+	}
+	batch, err := store.GetBatch("partner-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if batch.Owner != "partner" {
+		t.Fatalf("unexpected batch: %+v", batch)
+	}
+	stored, err := store.GetInviteCode("PARTNER1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.CampaignId != dao.DefaultCampaignId || stored.MaxUses != 1 || stored.ExpiresAt != 100 || stored.BatchId != batch.ID {
+		t.Fatalf("unexpected code: %+v", stored)
+	}
+	if other, err := store.GetInviteCode("PARTNER2"); err != nil || other.BatchId != 0 {
+		t.Fatalf("expect PARTNER2 in no batch, got %+v %v", other, err)
+	}
+	events, err := store.GetInviteCodeEvents("PARTNER2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 1 || events[0].EventType != dao.EventImported {
+		t.Fatalf("unexpected events: %+v", events)
+	}
+}
@@ -1,14 +1,24 @@
 package dao
 
 import (
+	"crypto/rand"
 	"errors"
+	"fmt"
 	"invite-code-service/pkg/db"
+	"math"
+	"math/big"
+	"time"
+
+	"gorm.io/gorm"
 )
 
 const (
 	TaskInviteCode   = uint8(0)
 	DirectInviteCode = uint8(1)
 	WaterInviteCode  = uint8(2)
+
+	// ReferralInviteCode is issued to users after they bind, to invite others
+	ReferralInviteCode = uint8(3)
 )
 
 type InviteCode struct {
@@ -16,34 +26,189 @@ type InviteCode struct {
 
 	InviteCode string `gorm:"type:varchar(10);not null;default:'';column:invite_code;uniqueIndex"`
 
-	UserAddress *string `gorm:"type:varchar(80);column:user_address;uniqueIndex"`
-	DiscordId   *string `gorm:"type:varchar(80);column:discord_id;uniqueIndex"`
+	// a user binds at most once per campaign
+	CampaignId int64 `gorm:"not null;default:0;column:campaign_id;uniqueIndex:code_user_address_index,priority:1;uniqueIndex:code_discord_id_index,priority:1;uniqueIndex:code_user_id_index,priority:1;uniqueIndex:inviter_seq_index,priority:1;index:alloc_key_index,priority:1"`
+
+	UserAddress *string `gorm:"type:varchar(80);column:user_address;uniqueIndex:code_user_address_index"`
+	DiscordId   *string `gorm:"type:varchar(80);column:discord_id;uniqueIndex:code_discord_id_index"`
 	DiscordName *string `gorm:"type:varchar(80);column:discord_name;"`
-	UserId      *string `gorm:"type:varchar(80);column:user_id;uniqueIndex"`
+	UserId      *string `gorm:"type:varchar(80);column:user_id;uniqueIndex:code_user_id_index"`
+
+	CodeType uint8  `gorm:"type:tinyint(1);unsigned;not null;default:0;column:code_type;index:alloc_key_index,priority:2"`
+	BindTime uint64 `gorm:"type:int(11);unsigned;not null;default:0;column:bind_time;index:alloc_key_index,priority:3"`
+
+	// validity window in unix seconds, 0 means unbounded
+	ValidFrom uint64 `gorm:"type:int(11);unsigned;not null;default:0;column:valid_from"`
+	ExpiresAt uint64 `gorm:"type:int(11);unsigned;not null;default:0;column:expires_at"`
+
+	// RevokeTime is set when a code is permanently revoked, it can never be bound again
+	RevokeTime uint64 `gorm:"type:int(11);unsigned;not null;default:0;column:revoke_time;index:alloc_key_index,priority:4"`
 
-	CodeType uint8  `gorm:"type:tinyint(1);unsigned;not null;default:0;column:code_type"`
-	BindTime uint64 `gorm:"type:int(11);unsigned;not null;default:0;column:bind_time"`
+	// MaxUses is how many users can redeem the code. Multi-use codes keep the
+	// binding columns empty, their users are in invite_code_redemptions and
+	// BindTime is set once UseCount reaches MaxUses.
+	MaxUses  uint64 `gorm:"type:int(11);unsigned;not null;default:1;column:max_uses"`
+	UseCount uint64 `gorm:"type:int(11);unsigned;not null;default:0;column:use_count"`
+
+	// InviterAddress owns a referral code, ReferralSeq numbers the codes of an owner
+	InviterAddress *string `gorm:"type:varchar(80);column:inviter_address;uniqueIndex:inviter_seq_index"`
+	ReferralSeq    uint8   `gorm:"type:tinyint(1);unsigned;not null;default:0;column:referral_seq;uniqueIndex:inviter_seq_index"`
+
+	// BatchId is the batch the code was handed out in, 0 if none
+	BatchId int64 `gorm:"not null;default:0;column:batch_id;index"`
+
+	// AllocKey is a secret random key that orders the codes drawn at random, set
+	// on create. A draw seeks the index from a random pivot instead of sorting
+	// the pool, users never see the keys so they can't predict their code.
+	AllocKey int64 `gorm:"not null;default:0;column:alloc_key;index:alloc_key_index,priority:5" json:"-"`
 }
 
 func (f InviteCode) TableName() string {
 	return "invite_codes"
 }
 
-func CreateInviteCode(db *db.WrapDb, c *InviteCode) error {
-	return db.Save(c).Error
+// BeforeCreate gives new codes their AllocKey
+func (f *InviteCode) BeforeCreate(tx *gorm.DB) (err error) {
+	if f.AllocKey == 0 {
+		f.AllocKey, err = newAllocKey()
+	}
+	return
+}
+
+// newAllocKey returns a random key in [1, MaxInt64], 0 is left for unset
+func newAllocKey() (int64, error) {
+	n, err := rand.Int(rand.Reader, big.NewInt(math.MaxInt64))
+	if err != nil {
+		return 0, err
+	}
+	return n.Int64() + 1, nil
+}
+
+func (f InviteCode) IsExpired(now uint64) bool {
+	return f.ExpiresAt != 0 && f.ExpiresAt <= now
+}
+
+func (f InviteCode) IsNotYetValid(now uint64) bool {
+	return f.ValidFrom > now
+}
+
+func (f InviteCode) IsRevoked() bool {
+	return f.RevokeTime != 0
+}
+
+func (f InviteCode) IsMultiUse() bool {
+	return f.MaxUses > 1
+}
+
+var codeTypeNames = map[uint8]string{
+	TaskInviteCode:     "task",
+	DirectInviteCode:   "direct",
+	WaterInviteCode:    "water",
+	ReferralInviteCode: "referral",
+}
+
+// CodeTypeName is the name of a code type used by the commands and exports
+func CodeTypeName(codeType uint8) string {
+	if name, ok := codeTypeNames[codeType]; ok {
+		return name
+	}
+	return fmt.Sprintf("%d", codeType)
+}
+
+// ParseCodeType is the inverse of CodeTypeName
+func ParseCodeType(name string) (uint8, error) {
+	for codeType, n := range codeTypeNames {
+		if n == name {
+			return codeType, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown code type %q", name)
+}
+
+// validAt scopes a query to codes inside their validity window at now
+func validAt(now uint64) func(tx *gorm.DB) *gorm.DB {
+	return func(tx *gorm.DB) *gorm.DB {
+		return tx.Where("valid_from <= ? AND (expires_at = 0 OR expires_at > ?)", now, now)
+	}
+}
+
+// expiredAt scopes a query to unbound, unrevoked codes expired at now
+func expiredAt(now uint64) func(tx *gorm.DB) *gorm.DB {
+	return func(tx *gorm.DB) *gorm.DB {
+		return tx.Where("bind_time = 0 AND revoke_time = 0 AND expires_at > 0 AND expires_at <= ?", now)
+	}
+}
+
+// inCampaign scopes a query to the codes of a campaign
+func inCampaign(id int64) func(tx *gorm.DB) *gorm.DB {
+	return func(tx *gorm.DB) *gorm.DB {
+		return tx.Where("campaign_id = ?", campaignOrDefault(id))
+	}
+}
+
+func CreateInviteCode(db *db.WrapDb, c *InviteCode, meta EventMeta) error {
+	if c.MaxUses == 0 {
+		c.MaxUses = 1
+	}
+	c.CampaignId = campaignOrDefault(c.CampaignId)
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(c).Error; err != nil {
+			return err
+		}
+		return createInviteCodeEvent(tx, c, EventGenerated, meta)
+	})
 }
 
 var ErrAlreadyBond = errors.New("already bond")
 
-func CheckBondAndUpdateInviteCode(db *db.WrapDb, c *InviteCode) error {
-	result := db.Model(c).Where("bind_time = 0").Select("*").Omit("CreatedAt", "InviteCode", "CodeType").Updates(c)
+// CheckBondAndUpdateInviteCode redeems c for the user set on its binding fields,
+// only if it is not fully used or revoked yet, and records the redemption and
+// eventType in the same transaction. The user is issued the referral codes of
+// referral in that transaction too, none if nil.
+func CheckBondAndUpdateInviteCode(db *db.WrapDb, c *InviteCode, eventType string, meta EventMeta, referral *GenerateSpec) error {
+	now := uint64(time.Now().Unix())
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := bindInviteCode(tx, c, eventType, meta, now); err != nil {
+			return err
+		}
+		return issueBoundReferralCodes(tx, c, referral, meta)
+	})
+	if err != nil {
+		if !c.IsMultiUse() {
+			c.UseCount = 0
+		}
+		return err
+	}
+	if c.IsMultiUse() {
+		c.UseCount++
+		c.BindTime = 0
+		if c.UseCount >= c.MaxUses {
+			c.BindTime = now
+		}
+	}
+	return nil
+}
+
+func bindInviteCode(tx *gorm.DB, c *InviteCode, eventType string, meta EventMeta, now uint64) error {
+	if c.IsMultiUse() {
+		if err := redeemMultiUse(tx, c, now); err != nil {
+			return err
+		}
+		return createInviteCodeEvent(tx, c, eventType, meta)
+	}
+
+	c.UseCount = 1
+	result := tx.Model(c).Where("bind_time = 0 AND revoke_time = 0").Select("*").Omit("CreatedAt", "InviteCode", "CampaignId", "CodeType", "MaxUses").Updates(c)
 	if result.Error != nil {
 		return result.Error
 	}
 	if result.RowsAffected == 0 {
 		return ErrAlreadyBond
 	}
-	return nil
+	if err := tx.Create(newInviteCodeRedemption(c, now)).Error; err != nil {
+		return err
+	}
+	return createInviteCodeEvent(tx, c, eventType, meta)
 }
 
 func GetInviteCode(db *db.WrapDb, code string) (info *InviteCode, err error) {
@@ -52,66 +217,157 @@ func GetInviteCode(db *db.WrapDb, code string) (info *InviteCode, err error) {
 	return
 }
 
-func GetInviteCodeCount(db *db.WrapDb, codeType uint8) (count int64, err error) {
-	err = db.Model(&InviteCode{}).Where("code_type = ?", codeType).Count(&count).Error
+func GetInviteCodeCount(db *db.WrapDb, campaignId int64, codeType uint8) (count int64, err error) {
+	err = db.Model(&InviteCode{}).Scopes(inCampaign(campaignId)).Where("code_type = ?", codeType).Count(&count).Error
 	return
 }
 
-func GetInviteCodeByUserAddress(db *db.WrapDb, user string) (info *InviteCode, err error) {
-	info = &InviteCode{}
-	err = db.Take(info, "user_address = ?", user).Error
-	return
+// GetInviteCodeByUserAddress returns the code redeemed by user in a campaign
+func GetInviteCodeByUserAddress(db *db.WrapDb, campaignId int64, user string) (info *InviteCode, err error) {
+	return getRedeemedInviteCode(db, campaignId, "user_address", user)
 }
 
-func GetInviteCodeByDiscordId(db *db.WrapDb, discordId string) (info *InviteCode, err error) {
-	info = &InviteCode{}
-	err = db.Take(info, "discord_id = ?", discordId).Error
-	return
+// GetInviteCodeByDiscordId returns the code redeemed by discordId in a campaign
+func GetInviteCodeByDiscordId(db *db.WrapDb, campaignId int64, discordId string) (info *InviteCode, err error) {
+	return getRedeemedInviteCode(db, campaignId, "discord_id", discordId)
 }
 
-func GetAvailableTaskInviteCode(db *db.WrapDb) (info *InviteCode, err error) {
+// GetAvailableTaskInviteCode picks a random unbound task code of a campaign that
+// has not expired. It takes the first code at or after a random AllocKey,
+// wrapping around to the lowest key, which is an index seek on alloc_key_index
+// whatever the size of the pool.
+func GetAvailableTaskInviteCode(db *db.WrapDb, campaignId int64) (info *InviteCode, err error) {
+	pivot, err := newAllocKey()
+	if err != nil {
+		return nil, err
+	}
+	now := uint64(time.Now().Unix())
+	available := func() *gorm.DB {
+		return db.Scopes(inCampaign(campaignId), validAt(now)).
+			Where("code_type = 0 AND bind_time = 0 AND revoke_time = 0")
+	}
+
 	info = &InviteCode{}
-	err = db.Where("code_type = 0 AND bind_time = 0").Order("RAND()").First(info).Error
+	err = available().Where("alloc_key >= ?", pivot).Order("alloc_key ASC").Take(info).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		err = available().Where("alloc_key < ?", pivot).Order("alloc_key ASC").Take(info).Error
+	}
 	return
 }
 
+// TaskCodeClaim is the user a task code is claimed for
+type TaskCodeClaim struct {
+	UserAddress string
+	DiscordId   string
+	DiscordName string
+	UserId      string
+}
+
+// ClaimTaskInviteCode binds a random available task code of a campaign to a
+// user. Concurrent claims can draw the same code, the one losing the conditional
+// update draws again. A lost draw means the code was taken, so the retries are
+// bounded by the pool and every claim gets a distinct code until the pool runs
+// out with gorm.ErrRecordNotFound. A user already bound in the campaign fails
+// with gorm.ErrDuplicatedKey. The user is issued the referral codes of referral
+// with the bind, none if nil.
+func ClaimTaskInviteCode(db *db.WrapDb, campaignId int64, claim TaskCodeClaim, meta EventMeta, referral *GenerateSpec) (*InviteCode, error) {
+	return claimTaskInviteCode(claim,
+		func() (*InviteCode, error) { return GetAvailableTaskInviteCode(db, campaignId) },
+		func(c *InviteCode) error { return CheckBondAndUpdateInviteCode(db, c, EventBoundGen, meta, referral) })
+}
+
+func claimTaskInviteCode(claim TaskCodeClaim, pick func() (*InviteCode, error), bind func(c *InviteCode) error) (*InviteCode, error) {
+	for {
+		c, err := pick()
+		if err != nil {
+			return nil, err
+		}
+		c.UserAddress = &claim.UserAddress
+		c.DiscordId = &claim.DiscordId
+		c.DiscordName = &claim.DiscordName
+		c.UserId = &claim.UserId
+		c.BindTime = uint64(time.Now().Unix())
+		err = bind(c)
+		if errors.Is(err, ErrAlreadyBond) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		return c, nil
+	}
+}
+
+// InviteCodeStats counts expired and revoked codes apart from the remaining ones.
+// A multi-use code remains until all its uses are redeemed, RemainUses counts
+// the uses left on remaining codes.
 type InviteCodeStats struct {
-	TotalCodes  int64 `json:"totalCodes"`
-	RemainCodes int64 `json:"remainCodes"`
+	TotalCodes   int64 `json:"totalCodes"`
+	RemainCodes  int64 `json:"remainCodes"`
+	ExpiredCodes int64 `json:"expiredCodes"`
+	RevokedCodes int64 `json:"revokedCodes"`
+
+	Redemptions int64 `json:"redemptions"`
+	RemainUses  int64 `json:"remainUses"`
 }
 
-func GetTaskInviteCodeStats(db *db.WrapDb) (*InviteCodeStats, error) {
+func getInviteCodeStats(db *db.WrapDb, scope func(tx *gorm.DB) *gorm.DB) (*InviteCodeStats, error) {
 	var total int64
 	var unused int64
+	var expired int64
+	var revoked int64
+	var redemptions int64
+	var remainUses int64
+	now := uint64(time.Now().Unix())
 
-	if err := db.Model(&InviteCode{}).Where("code_type = 0").Count(&total).Error; err != nil {
+	if err := db.Model(&InviteCode{}).Scopes(scope).Count(&total).Error; err != nil {
 		return nil, err
 	}
 
-	if err := db.Model(&InviteCode{}).Where("code_type = 0 AND bind_time = 0").Count(&unused).Error; err != nil {
+	if err := db.Model(&InviteCode{}).Scopes(scope).Where("bind_time = 0 AND revoke_time = 0").Count(&unused).Error; err != nil {
 		return nil, err
 	}
 
-	return &InviteCodeStats{
-		TotalCodes:  total,
-		RemainCodes: unused,
-	}, nil
-}
+	if err := db.Model(&InviteCode{}).Scopes(scope, expiredAt(now)).Count(&expired).Error; err != nil {
+		return nil, err
+	}
 
-func GetAllInviteCodeStats(db *db.WrapDb) (*InviteCodeStats, error) {
-	var total int64
-	var unused int64
+	if err := db.Model(&InviteCode{}).Scopes(scope).Where("revoke_time > 0").Count(&revoked).Error; err != nil {
+		return nil, err
+	}
 
-	if err := db.Model(&InviteCode{}).Count(&total).Error; err != nil {
+	if err := db.Model(&InviteCode{}).Scopes(scope).Select("COALESCE(SUM(use_count), 0)").Scan(&redemptions).Error; err != nil {
 		return nil, err
 	}
 
-	if err := db.Model(&InviteCode{}).Where("bind_time = 0").Count(&unused).Error; err != nil {
+	err := db.Model(&InviteCode{}).Scopes(scope).
+		Where("bind_time = 0 AND revoke_time = 0 AND (expires_at = 0 OR expires_at > ?)", now).
+		Select("COALESCE(SUM(max_uses - use_count), 0)").Scan(&remainUses).Error
+	if err != nil {
 		return nil, err
 	}
 
 	return &InviteCodeStats{
-		TotalCodes:  total,
-		RemainCodes: unused,
+		TotalCodes:   total,
+		RemainCodes:  unused - expired,
+		ExpiredCodes: expired,
+		RevokedCodes: revoked,
+		Redemptions:  redemptions,
+		RemainUses:   remainUses,
 	}, nil
 }
+
+func GetTaskInviteCodeStats(db *db.WrapDb, campaignId int64) (*InviteCodeStats, error) {
+	return GetInviteCodeTypeStats(db, campaignId, TaskInviteCode)
+}
+
+// GetInviteCodeTypeStats counts the codes of a type in a campaign
+func GetInviteCodeTypeStats(db *db.WrapDb, campaignId int64, codeType uint8) (*InviteCodeStats, error) {
+	return getInviteCodeStats(db, func(tx *gorm.DB) *gorm.DB {
+		return tx.Scopes(inCampaign(campaignId)).Where("code_type = ?", codeType)
+	})
+}
+
+func GetAllInviteCodeStats(db *db.WrapDb, campaignId int64) (*InviteCodeStats, error) {
+	return getInviteCodeStats(db, inCampaign(campaignId))
+}
@@ -0,0 +1,197 @@
+package dao
+
+import (
+	"errors"
+	"invite-code-service/pkg/db"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// InviteCodeBinding keeps a binding removed by an unbind or revoke
+type InviteCodeBinding struct {
+	db.BaseModel
+
+	InviteCode  string `gorm:"type:varchar(10);not null;default:'';column:invite_code;index"`
+	UserAddress string `gorm:"type:varchar(80);not null;default:'';column:user_address;index"`
+	DiscordId   string `gorm:"type:varchar(80);not null;default:'';column:discord_id"`
+	DiscordName string `gorm:"type:varchar(80);not null;default:'';column:discord_name"`
+	UserId      string `gorm:"type:varchar(80);not null;default:'';column:user_id"`
+	BindTime    uint64 `gorm:"type:int(11);unsigned;not null;default:0;column:bind_time"`
+
+	Action     string `gorm:"type:varchar(32);not null;default:'';column:action"` // EventUnbound or EventRevoked
+	Actor      string `gorm:"type:varchar(80);not null;default:'';column:actor"`
+	Reason     string `gorm:"type:varchar(255);not null;default:'';column:reason"`
+	UnbindTime uint64 `gorm:"type:int(11);unsigned;not null;default:0;column:unbind_time"`
+}
+
+func (f InviteCodeBinding) TableName() string {
+	return "invite_code_bindings"
+}
+
+func deref(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
+
+func newInviteCodeBinding(r *InviteCodeRedemption, action, reason string, meta EventMeta, now uint64) *InviteCodeBinding {
+	return &InviteCodeBinding{
+		InviteCode:  r.InviteCode,
+		UserAddress: deref(r.UserAddress),
+		DiscordId:   deref(r.DiscordId),
+		DiscordName: deref(r.DiscordName),
+		UserId:      deref(r.UserId),
+		BindTime:    r.RedeemTime,
+		Action:      action,
+		Actor:       meta.Actor,
+		Reason:      reason,
+		UnbindTime:  now,
+	}
+}
+
+// redeemedBy returns a copy of c carrying the user of r, to record events of that user
+func redeemedBy(c *InviteCode, r *InviteCodeRedemption) *InviteCode {
+	cp := *c
+	cp.UserAddress = r.UserAddress
+	cp.DiscordId = r.DiscordId
+	cp.DiscordName = r.DiscordName
+	cp.UserId = r.UserId
+	return &cp
+}
+
+var (
+	ErrNotBound        = errors.New("not bound")
+	ErrAlreadyRevoked  = errors.New("already revoked")
+	ErrConcurrentWrite = errors.New("invite code changed concurrently")
+	ErrMultiUse        = errors.New("multi-use code")
+)
+
+// UnbindInviteCode clears every redemption of code so it can be redeemed again,
+// or with revoke marks it permanently unusable. The removed redemptions are kept
+// in invite_code_bindings, one event is recorded per removed user, and the code
+// state before the change is returned with them.
+func UnbindInviteCode(db *db.WrapDb, code string, revoke bool, reason string, meta EventMeta) (*InviteCode, []*InviteCodeRedemption, error) {
+	var prev *InviteCode
+	var redemptions []*InviteCodeRedemption
+	err := db.Transaction(func(tx *gorm.DB) (err error) {
+		prev, redemptions, err = unbindInviteCode(tx, code, revoke, reason, meta, uint64(time.Now().Unix()))
+		return
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return prev, redemptions, nil
+}
+
+func unbindInviteCode(tx *gorm.DB, code string, revoke bool, reason string, meta EventMeta, now uint64) (*InviteCode, []*InviteCodeRedemption, error) {
+	prev := &InviteCode{}
+	if err := tx.Take(prev, "invite_code = ?", code).Error; err != nil {
+		return nil, nil, err
+	}
+	if prev.IsRevoked() {
+		return nil, nil, ErrAlreadyRevoked
+	}
+	if prev.UseCount == 0 && !revoke {
+		return nil, nil, ErrNotBound
+	}
+	var redemptions []*InviteCodeRedemption
+	if err := tx.Where("invite_code = ?", code).Order("id ASC").Find(&redemptions).Error; err != nil {
+		return nil, nil, err
+	}
+
+	action := EventUnbound
+	updates := map[string]any{
+		"user_address": nil,
+		"discord_id":   nil,
+		"discord_name": nil,
+		"user_id":      nil,
+		"bind_time":    0,
+		"use_count":    0,
+	}
+	if revoke {
+		action = EventRevoked
+		updates["revoke_time"] = now
+	}
+
+	result := tx.Model(&InviteCode{}).
+		Where("id = ? AND bind_time = ? AND use_count = ? AND revoke_time = 0", prev.ID, prev.BindTime, prev.UseCount).
+		Updates(updates)
+	if result.Error != nil {
+		return nil, nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, nil, ErrConcurrentWrite
+	}
+	if err := tx.Where("invite_code = ?", code).Delete(&InviteCodeRedemption{}).Error; err != nil {
+		return nil, nil, err
+	}
+
+	for _, r := range redemptions {
+		if err := tx.Create(newInviteCodeBinding(r, action, reason, meta, now)).Error; err != nil {
+			return nil, nil, err
+		}
+		if err := createInviteCodeEvent(tx, redeemedBy(prev, r), action, meta); err != nil {
+			return nil, nil, err
+		}
+	}
+	if len(redemptions) == 0 {
+		if err := createInviteCodeEvent(tx, prev, action, meta); err != nil {
+			return nil, nil, err
+		}
+	}
+	return prev, redemptions, nil
+}
+
+// CodeUser is the user an admin binds a code to, nil discord fields stay unset
+type CodeUser struct {
+	UserAddress string
+	DiscordId   *string
+	DiscordName *string
+}
+
+// Set binds c to the user
+func (u CodeUser) Set(c *InviteCode) {
+	c.UserAddress = &u.UserAddress
+	c.DiscordId = u.DiscordId
+	c.DiscordName = u.DiscordName
+	c.UserId = nil
+}
+
+// RebindInviteCode moves a bound single-use code to another user in one
+// transaction. The previous binding is kept and recorded like an unbind, the
+// new one is recorded as EventBoundAdmin. A new user already bound in the
+// campaign fails with gorm.ErrDuplicatedKey and nothing changes. The new user is
+// issued the referral codes of referral in the transaction, none if nil.
+func RebindInviteCode(db *db.WrapDb, code string, user CodeUser, reason string, meta EventMeta, referral *GenerateSpec) (*InviteCode, []*InviteCodeRedemption, error) {
+	var prev *InviteCode
+	var redemptions []*InviteCodeRedemption
+	err := db.Transaction(func(tx *gorm.DB) (err error) {
+		now := uint64(time.Now().Unix())
+		prev, redemptions, err = unbindInviteCode(tx, code, false, reason, meta, now)
+		if err != nil {
+			return err
+		}
+		if prev.IsMultiUse() {
+			return ErrMultiUse
+		}
+		c := *prev
+		user.Set(&c)
+		c.BindTime = now
+		if err := bindInviteCode(tx, &c, EventBoundAdmin, meta, now); err != nil {
+			return err
+		}
+		return issueBoundReferralCodes(tx, &c, referral, meta)
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return prev, redemptions, nil
+}
+
+// GetInviteCodeBindings returns the removed bindings of a code, oldest first
+func GetInviteCodeBindings(db *db.WrapDb, code string) (list []*InviteCodeBinding, err error) {
+	err = db.Where("invite_code = ?", code).Order("id ASC").Find(&list).Error
+	return
+}
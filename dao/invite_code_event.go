@@ -0,0 +1,91 @@
+package dao
+
+import (
+	"invite-code-service/pkg/db"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	EventGenerated       = "generated"
+	EventImported        = "imported"
+	EventDropletAssigned = "droplet_assigned"
+	EventBoundGen        = "bound_gen"
+	EventBoundBind       = "bound_bind"
+	EventBoundCli        = "bound_cli"
+	EventBoundAdmin      = "bound_admin"
+	EventUnbound         = "unbound"
+	EventRevoked         = "revoked"
+)
+
+const ActorSystem = "system"
+
+// EventMeta describes who caused a state change of an invite code
+type EventMeta struct {
+	Actor       string
+	SourceIp    string
+	PayloadHash string
+}
+
+var SystemEventMeta = EventMeta{Actor: ActorSystem}
+
+// InviteCodeEvent is append only, rows are never updated or deleted
+type InviteCodeEvent struct {
+	db.BaseModel
+
+	InviteCode  string `gorm:"type:varchar(10);not null;default:'';column:invite_code;index"`
+	CampaignId  int64  `gorm:"not null;default:0;column:campaign_id"`
+	EventType   string `gorm:"type:varchar(32);not null;default:'';column:event_type"`
+	UserAddress string `gorm:"type:varchar(80);not null;default:'';column:user_address;index"`
+	DiscordId   string `gorm:"type:varchar(80);not null;default:'';column:discord_id"`
+	Actor       string `gorm:"type:varchar(80);not null;default:'';column:actor"`
+	SourceIp    string `gorm:"type:varchar(64);not null;default:'';column:source_ip"`
+	PayloadHash string `gorm:"type:varchar(66);not null;default:'';column:payload_hash"`
+	EventTime   uint64 `gorm:"type:int(11);unsigned;not null;default:0;column:event_time"`
+}
+
+func (f InviteCodeEvent) TableName() string {
+	return "invite_code_events"
+}
+
+func newInviteCodeEvent(c *InviteCode, eventType string, meta EventMeta) *InviteCodeEvent {
+	event := &InviteCodeEvent{
+		InviteCode:  c.InviteCode,
+		CampaignId:  c.CampaignId,
+		EventType:   eventType,
+		Actor:       meta.Actor,
+		SourceIp:    meta.SourceIp,
+		PayloadHash: meta.PayloadHash,
+		EventTime:   uint64(time.Now().Unix()),
+	}
+	if c.UserAddress != nil {
+		event.UserAddress = *c.UserAddress
+	}
+	if c.DiscordId != nil {
+		event.DiscordId = *c.DiscordId
+	}
+	return event
+}
+
+func createInviteCodeEvent(tx *gorm.DB, c *InviteCode, eventType string, meta EventMeta) error {
+	return tx.Create(newInviteCodeEvent(c, eventType, meta)).Error
+}
+
+// GetInviteCodeEvents returns the history of a code, or of every code an address
+// or discord id was ever involved with, oldest first
+func GetInviteCodeEvents(db *db.WrapDb, key string) (list []*InviteCodeEvent, err error) {
+	err = db.Where("invite_code = ? OR user_address = ? OR discord_id = ?", key, key, key).
+		Order("id ASC").
+		Find(&list).Error
+	return
+}
+
+// GetInviteCodeEventsAfter returns up to limit events of eventTypes with id > afterId, oldest first
+func GetInviteCodeEventsAfter(db *db.WrapDb, afterId int64, eventTypes []string, limit int) (list []*InviteCodeEvent, err error) {
+	err = db.Where("id > ? AND event_type IN ?", afterId, eventTypes).
+		Order("id ASC").
+		Limit(limit).
+		Find(&list).Error
+	return
+}
@@ -0,0 +1,74 @@
+package dao
+
+import (
+	"invite-code-service/pkg/db"
+
+	"gorm.io/gorm"
+)
+
+// InviteCodeRedemption is one user redeeming a code. Every bind writes one, the
+// unique indexes keep an address or discord id to a single redemption per campaign.
+type InviteCodeRedemption struct {
+	db.BaseModel
+
+	InviteCode  string  `gorm:"type:varchar(10);not null;default:'';column:invite_code;index"`
+	CampaignId  int64   `gorm:"not null;default:0;column:campaign_id;uniqueIndex:redemption_user_address_index,priority:1;uniqueIndex:redemption_discord_id_index,priority:1;uniqueIndex:redemption_user_id_index,priority:1"`
+	UserAddress *string `gorm:"type:varchar(80);column:user_address;uniqueIndex:redemption_user_address_index"`
+	DiscordId   *string `gorm:"type:varchar(80);column:discord_id;uniqueIndex:redemption_discord_id_index"`
+	DiscordName *string `gorm:"type:varchar(80);column:discord_name;"`
+	UserId      *string `gorm:"type:varchar(80);column:user_id;uniqueIndex:redemption_user_id_index"`
+	RedeemTime  uint64  `gorm:"type:int(11);unsigned;not null;default:0;column:redeem_time"`
+}
+
+func (f InviteCodeRedemption) TableName() string {
+	return "invite_code_redemptions"
+}
+
+func newInviteCodeRedemption(c *InviteCode, now uint64) *InviteCodeRedemption {
+	return &InviteCodeRedemption{
+		InviteCode:  c.InviteCode,
+		CampaignId:  c.CampaignId,
+		UserAddress: c.UserAddress,
+		DiscordId:   c.DiscordId,
+		DiscordName: c.DiscordName,
+		UserId:      c.UserId,
+		RedeemTime:  now,
+	}
+}
+
+// getRedeemedInviteCode returns the code redeemed in a campaign by the user
+// matching column, bound single-use codes hold the binding in their own columns too
+func getRedeemedInviteCode(db *db.WrapDb, campaignId int64, column, value string) (info *InviteCode, err error) {
+	redemption := &InviteCodeRedemption{}
+	if err = db.Scopes(inCampaign(campaignId)).Take(redemption, column+" = ?", value).Error; err != nil {
+		return nil, err
+	}
+	return GetInviteCode(db, redemption.InviteCode)
+}
+
+func GetInviteCodeRedemptions(db *db.WrapDb, code string) (list []*InviteCodeRedemption, err error) {
+	err = db.Where("invite_code = ?", code).Order("id ASC").Find(&list).Error
+	return
+}
+
+// redeemMultiUse records c's user as one more redemption of a multi-use code,
+// binding the code once its uses are exhausted
+func redeemMultiUse(tx *gorm.DB, c *InviteCode, now uint64) error {
+	result := tx.Model(&InviteCode{}).
+		Where("id = ? AND bind_time = 0 AND revoke_time = 0 AND use_count < max_uses", c.ID).
+		Updates(map[string]any{"use_count": gorm.Expr("use_count + 1"), "update_time": now})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrAlreadyBond
+	}
+	// two statements, mysql and sqlite disagree on whether SET sees updated values
+	err := tx.Model(&InviteCode{}).
+		Where("id = ? AND bind_time = 0 AND use_count >= max_uses", c.ID).
+		Update("bind_time", now).Error
+	if err != nil {
+		return err
+	}
+	return tx.Create(newInviteCodeRedemption(c, now)).Error
+}
@@ -0,0 +1,145 @@
+package dao_test
+
+import (
+	"fmt"
+	"invite-code-service/dao"
+	"testing"
+
+	"gorm.io/gorm"
+)
+
+func TestGetAvailableTaskInviteCodeRandom(t *testing.T) {
+	store := dao.NewMemStore()
+	codes, err := store.GenerateInviteCodes(dao.GenerateSpec{CodeType: dao.TaskInviteCode, Count: 20}, dao.SystemEventMeta)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, c := range codes[:10] {
+		address := fmt.Sprintf("0x%d", i)
+		c.UserAddress = &address
+		c.BindTime = 1
+		if err := store.CheckBondAndUpdateInviteCode(c, dao.EventBoundGen, dao.SystemEventMeta, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	bound := make(map[string]bool)
+	for _, c := range codes[:10] {
+		bound[c.InviteCode] = true
+	}
+
+	drawn := make(map[string]int)
+	for i := 0; i < 200; i++ {
+		c, err := store.GetAvailableTaskInviteCode(dao.DefaultCampaignId)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if bound[c.InviteCode] {
+			t.Fatalf("bound code %s drawn", c.InviteCode)
+		}
+		drawn[c.InviteCode]++
+	}
+	// 200 draws over 10 codes leave a code out only when its key gap is tiny
+	if len(drawn) < 5 {
+		t.Fatalf("draws not spread over the pool: %v", drawn)
+	}
+}
+
+const benchPoolSize = 100000
+
+// BenchmarkGetAvailableTaskInviteCode draws from a pool of 100k task codes, half
+// of them bound, against the former ORDER BY RANDOM() query
+func BenchmarkGetAvailableTaskInviteCode(b *testing.B) {
+	wrapDb := newTestDb(b)
+	store := dao.NewDbStore(wrapDb)
+	if _, err := store.GenerateInviteCodes(dao.GenerateSpec{CodeType: dao.TaskInviteCode, Count: benchPoolSize}, dao.SystemEventMeta); err != nil {
+		b.Fatal(err)
+	}
+	if err := wrapDb.Exec("UPDATE invite_codes SET bind_time = 1, use_count = 1 WHERE id % 2 = 0").Error; err != nil {
+		b.Fatal(err)
+	}
+	b.ResetTimer()
+
+	b.Run("seek", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if _, err := store.GetAvailableTaskInviteCode(dao.DefaultCampaignId); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("order_by_random", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			c := &dao.InviteCode{}
+			err := wrapDb.Where("campaign_id = ? AND code_type = 0 AND bind_time = 0 AND revoke_time = 0", dao.DefaultCampaignId).
+				Order("RANDOM()").Take(c).Error
+			if err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func TestGetInviteCodeTypeStats(t *testing.T) {
+	store := dao.NewMemStore()
+	for codeType, count := range map[uint8]int64{dao.TaskInviteCode: 3, dao.DirectInviteCode: 2, dao.WaterInviteCode: 1} {
+		if _, err := store.GenerateInviteCodes(dao.GenerateSpec{CodeType: codeType, Count: count}, dao.SystemEventMeta); err != nil {
+			t.Fatal(err)
+		}
+	}
+	stats, err := store.GetInviteCodeTypeStats(dao.DefaultCampaignId, dao.DirectInviteCode)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.TotalCodes != 2 || stats.RemainCodes != 2 {
+		t.Fatalf("unexpected stats: %+v", stats)
+	}
+}
+
+func TestBindReferralCodeCollision(t *testing.T) {
+	wrapDb := newTestDb(t)
+	if err := dao.CreateInviteCode(wrapDb, &dao.InviteCode{InviteCode: "TASK0001", CodeType: dao.TaskInviteCode}, dao.SystemEventMeta); err != nil {
+		t.Fatal(err)
+	}
+
+	// a concurrent insert takes the first referral code right before it is created
+	stolen := false
+	err := wrapDb.Callback().Create().Before("gorm:create").Register("test:steal_code", func(tx *gorm.DB) {
+		c, ok := tx.Statement.Dest.(*dao.InviteCode)
+		if !ok || c.CodeType != dao.ReferralInviteCode || stolen {
+			return
+		}
+		stolen = true
+		taken := &dao.InviteCode{InviteCode: c.InviteCode, CodeType: dao.DirectInviteCode}
+		if err := tx.Session(&gorm.Session{NewDB: true}).Create(taken).Error; err != nil {
+			t.Error(err)
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	n := 0
+	referral := &dao.GenerateSpec{Count: 2, MaxUses: 1, NewCode: func() (string, error) {
+		n++
+		return fmt.Sprintf("REF%05d", n), nil
+	}}
+	c, err := dao.GetInviteCode(wrapDb, "TASK0001")
+	if err != nil {
+		t.Fatal(err)
+	}
+	address := "0xbound"
+	c.UserAddress = &address
+	c.BindTime = 1
+	if err := dao.CheckBondAndUpdateInviteCode(wrapDb, c, dao.EventBoundBind, dao.SystemEventMeta, referral); err != nil {
+		t.Fatalf("expect the taken referral code redrawn, got %v", err)
+	}
+	if !stolen {
+		t.Fatal("referral code not stolen")
+	}
+	codes, err := dao.GetReferralCodes(wrapDb, dao.DefaultCampaignId, address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(codes) != 2 || codes[0].InviteCode != "REF00002" || codes[1].InviteCode != "REF00003" {
+		t.Fatalf("unexpected referral codes: %+v", codes)
+	}
+}
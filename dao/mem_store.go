@@ -0,0 +1,19 @@
+package dao
+
+import (
+	"invite-code-service/pkg/db"
+)
+
+// NewMemStore returns a Store over a private in-memory sqlite database at the
+// latest schema, for tests and local development. It runs the queries of the
+// mysql backed store, so the rules of the two can't drift apart.
+func NewMemStore() *DbStore {
+	wrapDb, err := db.NewDB(&db.Config{Dialect: db.DialectSqlite, Path: db.SqliteMemory, Mode: "silent"})
+	if err != nil {
+		panic(err)
+	}
+	if err := MigrateLatest(wrapDb); err != nil {
+		panic(err)
+	}
+	return NewDbStore(wrapDb)
+}
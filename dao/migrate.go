@@ -1,10 +1,232 @@
 package dao
 
 import (
+	"errors"
+	"fmt"
 	"invite-code-service/pkg/db"
+	"time"
+
+	"gorm.io/gorm"
 )
 
-func AutoMigrate(db *db.WrapDb) error {
-	return db.Set("gorm:table_options", "ENGINE=InnoDB AUTO_INCREMENT=1 DEFAULT CHARSET=utf8").
-		AutoMigrate(InviteCode{}, DropletCode{})
+// Migration is one numbered schema change. Up and Down run inside a transaction
+// together with the schema_migrations bookkeeping, which makes them atomic on
+// sqlite only: mysql commits every DDL statement implicitly, so a step failing
+// there can be left half applied. Its schema_migrations row then stays dirty,
+// see ErrSchemaDirty.
+type Migration struct {
+	Version uint
+	Name    string
+	Up      func(tx *gorm.DB) error
+	Down    func(tx *gorm.DB) error
+}
+
+type SchemaMigration struct {
+	Version   uint   `gorm:"not null;primaryKey;autoIncrement:false;column:version"`
+	Name      string `gorm:"type:varchar(100);not null;default:'';column:name"`
+	AppliedAt uint64 `gorm:"type:int(11);unsigned;not null;default:0;column:applied_at"`
+	// Dirty is set while the step runs, a dirty row left behind is a step
+	// that failed after mysql had committed part of it
+	Dirty bool `gorm:"not null;default:false;column:dirty"`
+}
+
+func (f SchemaMigration) TableName() string {
+	return "schema_migrations"
+}
+
+type MigrationStatus struct {
+	Version   uint
+	Name      string
+	Applied   bool
+	AppliedAt uint64
+	Dirty     bool
+}
+
+var (
+	ErrSchemaTooNew = errors.New("database schema is newer than this binary")
+	ErrSchemaTooOld = errors.New("database schema is older than this binary, run migrate up")
+	ErrSchemaDirty  = errors.New("database schema is dirty, a migration failed half applied")
+)
+
+// LatestSchemaVersion is the schema version this binary is built for
+func LatestSchemaVersion() uint {
+	if len(migrations) == 0 {
+		return 0
+	}
+	return migrations[len(migrations)-1].Version
+}
+
+// migrator returns tx with the mysql table options applied to created tables
+func migrator(tx *gorm.DB) *gorm.DB {
+	if tx.Dialector.Name() == db.DialectMysql {
+		return tx.Set("gorm:table_options", "ENGINE=InnoDB AUTO_INCREMENT=1 DEFAULT CHARSET=utf8")
+	}
+	return tx
+}
+
+func ensureSchemaMigrations(db *db.WrapDb) error {
+	return migrator(db.DB).AutoMigrate(SchemaMigration{})
+}
+
+// GetSchemaVersion returns the highest applied migration version, 0 for an empty database
+func GetSchemaVersion(db *db.WrapDb) (uint, error) {
+	if err := ensureSchemaMigrations(db); err != nil {
+		return 0, err
+	}
+	var version uint
+	err := db.Model(&SchemaMigration{}).Select("COALESCE(MAX(version), 0)").Scan(&version).Error
+	return version, err
+}
+
+func getAppliedMigrations(db *db.WrapDb) (map[uint]*SchemaMigration, error) {
+	if err := ensureSchemaMigrations(db); err != nil {
+		return nil, err
+	}
+	var list []*SchemaMigration
+	if err := db.Order("version ASC").Find(&list).Error; err != nil {
+		return nil, err
+	}
+	applied := make(map[uint]*SchemaMigration, len(list))
+	for _, m := range list {
+		applied[m.Version] = m
+	}
+	return applied, nil
+}
+
+// checkMigratable refuses databases migrated by a newer binary or left dirty
+func checkMigratable(db *db.WrapDb) error {
+	version, err := GetSchemaVersion(db)
+	if err != nil {
+		return err
+	}
+	if version > LatestSchemaVersion() {
+		return fmt.Errorf("%w: database version %d, binary version %d", ErrSchemaTooNew, version, LatestSchemaVersion())
+	}
+	var dirty SchemaMigration
+	err = db.Where("dirty = ?", true).Order("version ASC").Limit(1).Find(&dirty).Error
+	if err != nil {
+		return err
+	}
+	if dirty.Version != 0 {
+		return fmt.Errorf("%w: migration %d %s, repair the schema by hand then clear its dirty flag "+
+			"in schema_migrations, or delete the row if the step is undone", ErrSchemaDirty, dirty.Version, dirty.Name)
+	}
+	return nil
+}
+
+// CheckSchemaVersion refuses databases that aren't exactly at the version of
+// this binary, for the commands that use the schema without migrating it
+func CheckSchemaVersion(db *db.WrapDb) error {
+	if err := checkMigratable(db); err != nil {
+		return err
+	}
+	version, err := GetSchemaVersion(db)
+	if err != nil {
+		return err
+	}
+	if version < LatestSchemaVersion() {
+		return fmt.Errorf("%w: database version %d, binary version %d", ErrSchemaTooOld, version, LatestSchemaVersion())
+	}
+	return nil
+}
+
+// MigrateUp applies pending migrations up to and including target, 0 means latest.
+// Each step records its version as dirty before running, a failure rolls the
+// step back on sqlite and leaves the dirty row on mysql.
+func MigrateUp(db *db.WrapDb, target uint) ([]Migration, error) {
+	if err := checkMigratable(db); err != nil {
+		return nil, err
+	}
+	applied, err := getAppliedMigrations(db)
+	if err != nil {
+		return nil, err
+	}
+
+	var done []Migration
+	for _, m := range migrations {
+		if target > 0 && m.Version > target {
+			break
+		}
+		if _, ok := applied[m.Version]; ok {
+			continue
+		}
+		err := db.Transaction(func(tx *gorm.DB) error {
+			err := tx.Create(&SchemaMigration{Version: m.Version, Name: m.Name, Dirty: true}).Error
+			if err != nil {
+				return err
+			}
+			if err := m.Up(migrator(tx)); err != nil {
+				return err
+			}
+			return tx.Model(&SchemaMigration{}).Where("version = ?", m.Version).
+				Updates(map[string]any{"applied_at": uint64(time.Now().Unix()), "dirty": false}).Error
+		})
+		if err != nil {
+			return done, fmt.Errorf("migration %d %s up failed: %w", m.Version, m.Name, err)
+		}
+		done = append(done, m)
+	}
+	return done, nil
+}
+
+// MigrateDown reverts the latest steps applied migrations, marking each dirty
+// until its row is deleted like MigrateUp
+func MigrateDown(db *db.WrapDb, steps int) ([]Migration, error) {
+	if err := checkMigratable(db); err != nil {
+		return nil, err
+	}
+	applied, err := getAppliedMigrations(db)
+	if err != nil {
+		return nil, err
+	}
+
+	var done []Migration
+	for i := len(migrations) - 1; i >= 0 && len(done) < steps; i-- {
+		m := migrations[i]
+		if _, ok := applied[m.Version]; !ok {
+			continue
+		}
+		err := db.Transaction(func(tx *gorm.DB) error {
+			err := tx.Model(&SchemaMigration{}).Where("version = ?", m.Version).Update("dirty", true).Error
+			if err != nil {
+				return err
+			}
+			if err := m.Down(migrator(tx)); err != nil {
+				return err
+			}
+			return tx.Delete(&SchemaMigration{}, "version = ?", m.Version).Error
+		})
+		if err != nil {
+			return done, fmt.Errorf("migration %d %s down failed: %w", m.Version, m.Name, err)
+		}
+		done = append(done, m)
+	}
+	return done, nil
+}
+
+// GetMigrationStatus lists every known migration and whether it is applied
+func GetMigrationStatus(db *db.WrapDb) ([]*MigrationStatus, error) {
+	applied, err := getAppliedMigrations(db)
+	if err != nil {
+		return nil, err
+	}
+
+	list := make([]*MigrationStatus, 0, len(migrations))
+	for _, m := range migrations {
+		status := &MigrationStatus{Version: m.Version, Name: m.Name}
+		if a, ok := applied[m.Version]; ok {
+			status.Applied = true
+			status.AppliedAt = a.AppliedAt
+			status.Dirty = a.Dirty
+		}
+		list = append(list, status)
+	}
+	return list, nil
+}
+
+// MigrateLatest is run by the long running commands on startup, it refuses newer
+// or dirty schemas and applies pending migrations
+func MigrateLatest(db *db.WrapDb) error {
+	_, err := MigrateUp(db, 0)
+	return err
 }
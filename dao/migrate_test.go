@@ -0,0 +1,263 @@
+package dao_test
+
+import (
+	"errors"
+	"fmt"
+	"invite-code-service/dao"
+	"invite-code-service/pkg/db"
+	"testing"
+)
+
+func TestMigrateUpDown(t *testing.T) {
+	wrapDb, err := db.NewDB(&db.Config{Dialect: db.DialectSqlite, Path: db.SqliteMemory, Mode: "silent"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	done, err := dao.MigrateUp(wrapDb, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if uint(len(done)) != dao.LatestSchemaVersion() {
+		t.Fatalf("expect %d applied, got %d", dao.LatestSchemaVersion(), len(done))
+	}
+	version, err := dao.GetSchemaVersion(wrapDb)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if version != dao.LatestSchemaVersion() {
+		t.Fatalf("expect version %d, got %d", dao.LatestSchemaVersion(), version)
+	}
+
+	// idempotent
+	done, err = dao.MigrateUp(wrapDb, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(done) != 0 {
+		t.Fatalf("expect nothing applied, got %d", len(done))
+	}
+
+	done, err = dao.MigrateDown(wrapDb, int(dao.LatestSchemaVersion()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if uint(len(done)) != dao.LatestSchemaVersion() {
+		t.Fatalf("expect %d reverted, got %d", dao.LatestSchemaVersion(), len(done))
+	}
+	if wrapDb.Migrator().HasTable(&dao.InviteCode{}) {
+		t.Fatal("invite_codes should be dropped")
+	}
+
+	status, err := dao.GetMigrationStatus(wrapDb)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range status {
+		if s.Applied {
+			t.Fatalf("migration %d should be pending", s.Version)
+		}
+	}
+
+	if err := dao.MigrateLatest(wrapDb); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMigrateRefuseNewerSchema(t *testing.T) {
+	wrapDb := newTestDb(t)
+
+	err := wrapDb.Create(&dao.SchemaMigration{Version: dao.LatestSchemaVersion() + 1, Name: "future"}).Error
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := dao.MigrateLatest(wrapDb); !errors.Is(err, dao.ErrSchemaTooNew) {
+		t.Fatalf("expect ErrSchemaTooNew, got %v", err)
+	}
+}
+
+func TestCheckSchemaVersion(t *testing.T) {
+	wrapDb, err := db.NewDB(&db.Config{Dialect: db.DialectSqlite, Path: db.SqliteMemory, Mode: "silent"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := dao.MigrateUp(wrapDb, 5); err != nil {
+		t.Fatal(err)
+	}
+	if err := dao.CheckSchemaVersion(wrapDb); !errors.Is(err, dao.ErrSchemaTooOld) {
+		t.Fatalf("expect ErrSchemaTooOld, got %v", err)
+	}
+	if err := dao.MigrateLatest(wrapDb); err != nil {
+		t.Fatal(err)
+	}
+	if err := dao.CheckSchemaVersion(wrapDb); err != nil {
+		t.Fatal(err)
+	}
+
+	// a step mysql committed half of before failing
+	err = wrapDb.Model(&dao.SchemaMigration{}).Where("version = ?", 5).Update("dirty", true).Error
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := dao.CheckSchemaVersion(wrapDb); !errors.Is(err, dao.ErrSchemaDirty) {
+		t.Fatalf("expect ErrSchemaDirty, got %v", err)
+	}
+	if _, err := dao.MigrateDown(wrapDb, 1); !errors.Is(err, dao.ErrSchemaDirty) {
+		t.Fatalf("expect ErrSchemaDirty, got %v", err)
+	}
+	list, err := dao.GetMigrationStatus(wrapDb)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !list[4].Dirty || list[3].Dirty {
+		t.Fatalf("expect only migration 5 dirty: %+v %+v", list[3], list[4])
+	}
+}
+
+func TestMigrateBackfillRedemptions(t *testing.T) {
+	wrapDb, err := db.NewDB(&db.Config{Dialect: db.DialectSqlite, Path: db.SqliteMemory, Mode: "silent"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := dao.MigrateUp(wrapDb, 4); err != nil {
+		t.Fatal(err)
+	}
+	err = wrapDb.Exec("INSERT INTO invite_codes (create_time, update_time, invite_code, user_address, code_type, bind_time) VALUES (1, 1, 'OLDCODE1', '0xold', 1, 100)").Error
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := dao.MigrateLatest(wrapDb); err != nil {
+		t.Fatal(err)
+	}
+
+	inviteCode, err := dao.GetInviteCodeByUserAddress(wrapDb, dao.DefaultCampaignId, "0xold")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if inviteCode.InviteCode != "OLDCODE1" || inviteCode.MaxUses != 1 || inviteCode.UseCount != 1 {
+		t.Fatalf("unexpected code: %+v", inviteCode)
+	}
+}
+
+func TestMigrateDefaultCampaign(t *testing.T) {
+	wrapDb, err := db.NewDB(&db.Config{Dialect: db.DialectSqlite, Path: db.SqliteMemory, Mode: "silent"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := dao.MigrateUp(wrapDb, 6); err != nil {
+		t.Fatal(err)
+	}
+	err = wrapDb.Exec("INSERT INTO invite_codes (create_time, update_time, invite_code, user_address, code_type, bind_time, use_count) VALUES (1, 1, 'OLDCODE1', '0xold', 1, 100, 1)").Error
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = wrapDb.Exec("INSERT INTO invite_code_redemptions (create_time, update_time, invite_code, user_address, redeem_time) VALUES (1, 1, 'OLDCODE1', '0xold', 100)").Error
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := dao.MigrateLatest(wrapDb); err != nil {
+		t.Fatal(err)
+	}
+
+	campaign, err := dao.GetCampaign(wrapDb, dao.DefaultCampaignName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if campaign.ID != dao.DefaultCampaignId {
+		t.Fatalf("unexpected default campaign: %+v", campaign)
+	}
+	inviteCode, err := dao.GetInviteCodeByUserAddress(wrapDb, dao.DefaultCampaignId, "0xold")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if inviteCode.CampaignId != dao.DefaultCampaignId {
+		t.Fatalf("unexpected code: %+v", inviteCode)
+	}
+}
+
+func TestMigrateBackfillAllocKeys(t *testing.T) {
+	wrapDb, err := db.NewDB(&db.Config{Dialect: db.DialectSqlite, Path: db.SqliteMemory, Mode: "silent"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := dao.MigrateUp(wrapDb, 8); err != nil {
+		t.Fatal(err)
+	}
+	for _, code := range []string{"OLDCODE1", "OLDCODE2", "OLDCODE3"} {
+		err = wrapDb.Exec("INSERT INTO invite_codes (create_time, update_time, invite_code, campaign_id) VALUES (1, 1, ?, 1)", code).Error
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := dao.MigrateLatest(wrapDb); err != nil {
+		t.Fatal(err)
+	}
+
+	var keys []int64
+	if err := wrapDb.Model(&dao.InviteCode{}).Pluck("alloc_key", &keys).Error; err != nil {
+		t.Fatal(err)
+	}
+	seen := make(map[int64]bool)
+	for _, key := range keys {
+		if key <= 0 || seen[key] {
+			t.Fatalf("unexpected keys: %v", keys)
+		}
+		seen[key] = true
+	}
+	if len(keys) != 3 {
+		t.Fatalf("expect 3 keys, got %v", keys)
+	}
+
+	if _, err := dao.GetAvailableTaskInviteCode(wrapDb, dao.DefaultCampaignId); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMigrateBackfillDropletRounds(t *testing.T) {
+	wrapDb, err := db.NewDB(&db.Config{Dialect: db.DialectSqlite, Path: db.SqliteMemory, Mode: "silent"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := dao.MigrateUp(wrapDb, 10); err != nil {
+		t.Fatal(err)
+	}
+	for i, d := range []struct {
+		round      uint8
+		index      uint8
+		createTime int
+	}{{0, 0, 100}, {0, 0, 101}, {1, 0, 200}, {1, 1, 200}, {1, 2, 200}, {2, 0, 300}} {
+		err = wrapDb.Exec("INSERT INTO droplet_codes (create_time, update_time, invite_code, campaign_id, round, droplet_index) VALUES (?, ?, ?, 1, ?, ?)",
+			d.createTime, d.createTime, fmt.Sprintf("CODE%04d", i), d.round, d.index).Error
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := dao.MigrateLatest(wrapDb); err != nil {
+		t.Fatal(err)
+	}
+
+	rounds, err := dao.GetDropletRounds(wrapDb, dao.DefaultCampaignId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rounds) != 3 || rounds[0].OpenTime != 100 || rounds[0].CloseTime != 200 || rounds[1].CloseTime != 300 || !rounds[2].IsOpen() {
+		t.Fatalf("unexpected rounds: %+v", rounds)
+	}
+	for i, shape := range []dao.DropletShape{{Droplets: 1, CodesPerDroplet: 2}, {Droplets: 3, CodesPerDroplet: 1}, {Droplets: 1, CodesPerDroplet: 1}} {
+		if rounds[i].Shape() != shape {
+			t.Fatalf("round %d: expect shape %+v, got %+v", i, shape, rounds[i].Shape())
+		}
+	}
+	open, err := dao.GetOpenDropletRound(wrapDb, dao.DefaultCampaignId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if open.Round != 2 {
+		t.Fatalf("expect round 2 open, got %d", open.Round)
+	}
+}
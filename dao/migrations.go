@@ -0,0 +1,652 @@
+package dao
+
+import (
+	"invite-code-service/pkg/db"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// Migrations must only ever be appended. Each one snapshots the models it
+// touches so later changes to InviteCode/DropletCode don't rewrite history.
+var migrations = []Migration{
+	{
+		Version: 1,
+		Name:    "init",
+		Up: func(tx *gorm.DB) error {
+			// also adopts databases created by the former gorm AutoMigrate
+			return tx.AutoMigrate(inviteCodeV1{}, dropletCodeV1{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable(dropletCodeV1{}, inviteCodeV1{})
+		},
+	},
+	{
+		Version: 2,
+		Name:    "invite_code_events",
+		Up: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(inviteCodeEventV2{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable(inviteCodeEventV2{})
+		},
+	},
+	{
+		Version: 3,
+		Name:    "invite_code_validity",
+		Up: func(tx *gorm.DB) error {
+			return addColumns(tx, &inviteCodeV3{}, "ValidFrom", "ExpiresAt")
+		},
+		Down: func(tx *gorm.DB) error {
+			return dropColumns(tx, &inviteCodeV3{}, "ValidFrom", "ExpiresAt")
+		},
+	},
+	{
+		Version: 4,
+		Name:    "invite_code_revoke",
+		Up: func(tx *gorm.DB) error {
+			if err := addColumns(tx, &inviteCodeV4{}, "RevokeTime"); err != nil {
+				return err
+			}
+			return tx.AutoMigrate(inviteCodeBindingV4{})
+		},
+		Down: func(tx *gorm.DB) error {
+			if err := tx.Migrator().DropTable(inviteCodeBindingV4{}); err != nil {
+				return err
+			}
+			return dropColumns(tx, &inviteCodeV4{}, "RevokeTime")
+		},
+	},
+	{
+		Version: 5,
+		Name:    "invite_code_redemptions",
+		Up: func(tx *gorm.DB) error {
+			if err := addColumns(tx, &inviteCodeV5{}, "MaxUses", "UseCount"); err != nil {
+				return err
+			}
+			if err := tx.AutoMigrate(inviteCodeRedemptionV5{}); err != nil {
+				return err
+			}
+			// every bound code so far is a single-use code redeemed once
+			if err := tx.Exec("UPDATE invite_codes SET use_count = 1 WHERE bind_time > 0").Error; err != nil {
+				return err
+			}
+			return tx.Exec(`INSERT INTO invite_code_redemptions
+				(create_time, update_time, invite_code, user_address, discord_id, discord_name, user_id, redeem_time)
+				SELECT create_time, update_time, invite_code, user_address, discord_id, discord_name, user_id, bind_time
+				FROM invite_codes WHERE bind_time > 0`).Error
+		},
+		Down: func(tx *gorm.DB) error {
+			if err := tx.Migrator().DropTable(inviteCodeRedemptionV5{}); err != nil {
+				return err
+			}
+			return dropColumns(tx, &inviteCodeV5{}, "MaxUses", "UseCount")
+		},
+	},
+	{
+		Version: 6,
+		Name:    "invite_code_referral",
+		Up: func(tx *gorm.DB) error {
+			if err := addColumns(tx, &inviteCodeV6{}, "InviterAddress", "ReferralSeq"); err != nil {
+				return err
+			}
+			if tx.Migrator().HasIndex(&inviteCodeV6{}, "inviter_seq_index") {
+				return nil
+			}
+			return tx.Migrator().CreateIndex(&inviteCodeV6{}, "inviter_seq_index")
+		},
+		Down: func(tx *gorm.DB) error {
+			if tx.Migrator().HasIndex(&inviteCodeV6{}, "inviter_seq_index") {
+				if err := tx.Migrator().DropIndex(&inviteCodeV6{}, "inviter_seq_index"); err != nil {
+					return err
+				}
+			}
+			return dropColumns(tx, &inviteCodeV6{}, "InviterAddress", "ReferralSeq")
+		},
+	},
+	{
+		Version: 7,
+		Name:    "campaigns",
+		Up: func(tx *gorm.DB) error {
+			if err := tx.AutoMigrate(campaignV7{}); err != nil {
+				return err
+			}
+			err := tx.Where(campaignV7{BaseModel: db.BaseModel{ID: 1}}).
+				FirstOrCreate(&campaignV7{BaseModel: db.BaseModel{ID: 1}, Name: "default"}).Error
+			if err != nil {
+				return err
+			}
+
+			for _, model := range []any{&inviteCodeV7{}, &inviteCodeRedemptionV7{}, &dropletCodeV7{}, &inviteCodeEventV7{}} {
+				if err := addColumns(tx, model, "CampaignId"); err != nil {
+					return err
+				}
+				// everything so far belongs to the default campaign
+				if err := tx.Model(model).Where("campaign_id = 0").Update("campaign_id", 1).Error; err != nil {
+					return err
+				}
+			}
+
+			// users are unique per campaign instead of overall
+			if err := dropIndexes(tx, &inviteCodeV7{}, "idx_invite_codes_user_address", "idx_invite_codes_discord_id",
+				"idx_invite_codes_user_id", "inviter_seq_index"); err != nil {
+				return err
+			}
+			if err := createIndexes(tx, &inviteCodeV7{}, "code_user_address_index", "code_discord_id_index",
+				"code_user_id_index", "inviter_seq_index"); err != nil {
+				return err
+			}
+			if err := dropIndexes(tx, &inviteCodeRedemptionV7{}, "idx_invite_code_redemptions_user_address",
+				"idx_invite_code_redemptions_discord_id", "idx_invite_code_redemptions_user_id"); err != nil {
+				return err
+			}
+			if err := createIndexes(tx, &inviteCodeRedemptionV7{}, "redemption_user_address_index",
+				"redemption_discord_id_index", "redemption_user_id_index"); err != nil {
+				return err
+			}
+			return createIndexes(tx, &dropletCodeV7{}, "campaign_round_index")
+		},
+		Down: func(tx *gorm.DB) error {
+			if err := dropIndexes(tx, &dropletCodeV7{}, "campaign_round_index"); err != nil {
+				return err
+			}
+			if err := dropIndexes(tx, &inviteCodeRedemptionV7{}, "redemption_user_address_index",
+				"redemption_discord_id_index", "redemption_user_id_index"); err != nil {
+				return err
+			}
+			if err := createIndexes(tx, &inviteCodeRedemptionV5{}, "UserAddress", "DiscordId", "UserId"); err != nil {
+				return err
+			}
+			if err := dropIndexes(tx, &inviteCodeV7{}, "code_user_address_index", "code_discord_id_index",
+				"code_user_id_index", "inviter_seq_index"); err != nil {
+				return err
+			}
+			if err := createIndexes(tx, &inviteCodeV1{}, "UserAddress", "DiscordId", "UserId"); err != nil {
+				return err
+			}
+			if err := createIndexes(tx, &inviteCodeV6{}, "inviter_seq_index"); err != nil {
+				return err
+			}
+			for _, model := range []any{&inviteCodeV7{}, &inviteCodeRedemptionV7{}, &dropletCodeV7{}, &inviteCodeEventV7{}} {
+				if err := dropColumns(tx, model, "CampaignId"); err != nil {
+					return err
+				}
+			}
+			return tx.Migrator().DropTable(campaignV7{})
+		},
+	},
+	{
+		Version: 8,
+		Name:    "invite_code_batches",
+		Up: func(tx *gorm.DB) error {
+			if err := tx.AutoMigrate(batchV8{}); err != nil {
+				return err
+			}
+			if err := addColumns(tx, &inviteCodeV8{}, "BatchId"); err != nil {
+				return err
+			}
+			return createIndexes(tx, &inviteCodeV8{}, "BatchId")
+		},
+		Down: func(tx *gorm.DB) error {
+			if err := dropIndexes(tx, &inviteCodeV8{}, "BatchId"); err != nil {
+				return err
+			}
+			if err := dropColumns(tx, &inviteCodeV8{}, "BatchId"); err != nil {
+				return err
+			}
+			return tx.Migrator().DropTable(batchV8{})
+		},
+	},
+	{
+		Version: 9,
+		Name:    "invite_code_alloc_keys",
+		Up: func(tx *gorm.DB) error {
+			if err := addColumns(tx, &inviteCodeV9{}, "AllocKey"); err != nil {
+				return err
+			}
+			if err := backfillAllocKeys(tx); err != nil {
+				return err
+			}
+			return createIndexes(tx, &inviteCodeV9{}, "alloc_key_index")
+		},
+		Down: func(tx *gorm.DB) error {
+			if err := dropIndexes(tx, &inviteCodeV9{}, "alloc_key_index"); err != nil {
+				return err
+			}
+			return dropColumns(tx, &inviteCodeV9{}, "AllocKey")
+		},
+	},
+	{
+		Version: 10,
+		Name:    "invite_code_batch_owner",
+		Up: func(tx *gorm.DB) error {
+			return addColumns(tx, &batchV10{}, "Owner", "Notes")
+		},
+		Down: func(tx *gorm.DB) error {
+			return dropColumns(tx, &batchV10{}, "Owner", "Notes")
+		},
+	},
+	{
+		Version: 11,
+		Name:    "droplet_rounds",
+		Up: func(tx *gorm.DB) error {
+			if err := tx.AutoMigrate(dropletRoundV11{}); err != nil {
+				return err
+			}
+			return backfillDropletRounds(tx)
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable(dropletRoundV11{})
+		},
+	},
+	{
+		Version: 12,
+		Name:    "droplet_round_shape",
+		Up: func(tx *gorm.DB) error {
+			if err := addColumns(tx, &dropletRoundV12{}, "Droplets", "CodesPerDroplet"); err != nil {
+				return err
+			}
+			return backfillDropletShapes(tx)
+		},
+		Down: func(tx *gorm.DB) error {
+			return dropColumns(tx, &dropletRoundV12{}, "Droplets", "CodesPerDroplet")
+		},
+	},
+	{
+		Version: 13,
+		Name:    "invite_code_event_cursors",
+		Up: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(eventCursorV13{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable(eventCursorV13{})
+		},
+	},
+}
+
+// backfillDropletShapes records the shape of the rounds from their droplet
+// codes, rounds without codes get the 5 droplets of 5 codes every round had
+func backfillDropletShapes(tx *gorm.DB) error {
+	err := tx.Model(&dropletRoundV12{}).Where("droplets = 0").
+		Updates(map[string]any{"droplets": 5, "codes_per_droplet": 5}).Error
+	if err != nil {
+		return err
+	}
+	var shapes []struct {
+		CampaignId int64
+		Round      uint8
+		Droplets   int
+		Codes      int
+	}
+	err = tx.Table("droplet_codes").Select("campaign_id, round, COUNT(DISTINCT droplet_index) AS droplets, COUNT(*) AS codes").
+		Group("campaign_id, round").Scan(&shapes).Error
+	if err != nil {
+		return err
+	}
+	for _, s := range shapes {
+		err := tx.Model(&dropletRoundV12{}).Where("campaign_id = ? AND round = ?", s.CampaignId, s.Round).
+			Updates(map[string]any{"droplets": s.Droplets, "codes_per_droplet": s.Codes / s.Droplets}).Error
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// backfillDropletRounds records the rounds droplet codes were assigned to. The
+// last round of each campaign stays open as it was the one served, the others
+// closed when the next one opened.
+func backfillDropletRounds(tx *gorm.DB) error {
+	var assigned []struct {
+		CampaignId int64
+		Round      uint8
+		OpenTime   uint64
+	}
+	err := tx.Table("droplet_codes").Select("campaign_id, round, MIN(create_time) AS open_time").
+		Group("campaign_id, round").Order("campaign_id, round").Scan(&assigned).Error
+	if err != nil {
+		return err
+	}
+	for i, a := range assigned {
+		round := dropletRoundV11{CampaignId: a.CampaignId, Round: a.Round, OpenTime: a.OpenTime}
+		if i+1 < len(assigned) && assigned[i+1].CampaignId == a.CampaignId {
+			round.CloseTime = assigned[i+1].OpenTime
+		}
+		if err := tx.Create(&round).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+const allocKeyBackfillSize = 500
+
+// backfillAllocKeys gives every code without an alloc key a random one, keys
+// come from crypto/rand rather than the database's RAND() so they stay unpredictable
+func backfillAllocKeys(tx *gorm.DB) error {
+	for {
+		var ids []int64
+		err := tx.Table("invite_codes").Where("alloc_key = 0").Order("id ASC").Limit(allocKeyBackfillSize).Pluck("id", &ids).Error
+		if err != nil {
+			return err
+		}
+		if len(ids) == 0 {
+			return nil
+		}
+
+		var sql strings.Builder
+		args := make([]any, 0, 2*len(ids)+1)
+		sql.WriteString("UPDATE invite_codes SET alloc_key = CASE id")
+		for _, id := range ids {
+			key, err := newAllocKey()
+			if err != nil {
+				return err
+			}
+			sql.WriteString(" WHEN ? THEN ?")
+			args = append(args, id, key)
+		}
+		sql.WriteString(" END WHERE id IN ?")
+		args = append(args, ids)
+		if err := tx.Exec(sql.String(), args...).Error; err != nil {
+			return err
+		}
+	}
+}
+
+func addColumns(tx *gorm.DB, model any, fields ...string) error {
+	for _, field := range fields {
+		if tx.Migrator().HasColumn(model, field) {
+			continue
+		}
+		if err := tx.Migrator().AddColumn(model, field); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func dropIndexes(tx *gorm.DB, model any, names ...string) error {
+	for _, name := range names {
+		if !tx.Migrator().HasIndex(model, name) {
+			continue
+		}
+		if err := tx.Migrator().DropIndex(model, name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// createIndexes creates indexes by name, or by field for the unnamed ones
+func createIndexes(tx *gorm.DB, model any, names ...string) error {
+	for _, name := range names {
+		if tx.Migrator().HasIndex(model, name) {
+			continue
+		}
+		if err := tx.Migrator().CreateIndex(model, name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func dropColumns(tx *gorm.DB, model any, fields ...string) error {
+	for _, field := range fields {
+		if !tx.Migrator().HasColumn(model, field) {
+			continue
+		}
+		if err := tx.Migrator().DropColumn(model, field); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type inviteCodeV1 struct {
+	db.BaseModel
+
+	InviteCode string `gorm:"type:varchar(10);not null;default:'';column:invite_code;uniqueIndex"`
+
+	UserAddress *string `gorm:"type:varchar(80);column:user_address;uniqueIndex"`
+	DiscordId   *string `gorm:"type:varchar(80);column:discord_id;uniqueIndex"`
+	DiscordName *string `gorm:"type:varchar(80);column:discord_name;"`
+	UserId      *string `gorm:"type:varchar(80);column:user_id;uniqueIndex"`
+
+	CodeType uint8  `gorm:"type:tinyint(1);unsigned;not null;default:0;column:code_type"`
+	BindTime uint64 `gorm:"type:int(11);unsigned;not null;default:0;column:bind_time"`
+}
+
+func (f inviteCodeV1) TableName() string {
+	return "invite_codes"
+}
+
+type dropletCodeV1 struct {
+	db.BaseModel
+
+	InviteCode   string `gorm:"type:varchar(10);not null;default:'';column:invite_code;uniqueIndex:code_round_index"`
+	Round        uint8  `gorm:"type:tinyint(1);unsigned;not null;default:0;column:round;uniqueIndex:code_round_index"`
+	DropletIndex uint8  `gorm:"type:tinyint(1);unsigned;not null;default:0;column:droplet_index;uniqueIndex:code_round_index"`
+}
+
+func (f dropletCodeV1) TableName() string {
+	return "droplet_codes"
+}
+
+type inviteCodeEventV2 struct {
+	db.BaseModel
+
+	InviteCode  string `gorm:"type:varchar(10);not null;default:'';column:invite_code;index"`
+	EventType   string `gorm:"type:varchar(32);not null;default:'';column:event_type"`
+	UserAddress string `gorm:"type:varchar(80);not null;default:'';column:user_address;index"`
+	DiscordId   string `gorm:"type:varchar(80);not null;default:'';column:discord_id"`
+	Actor       string `gorm:"type:varchar(80);not null;default:'';column:actor"`
+	SourceIp    string `gorm:"type:varchar(64);not null;default:'';column:source_ip"`
+	PayloadHash string `gorm:"type:varchar(66);not null;default:'';column:payload_hash"`
+	EventTime   uint64 `gorm:"type:int(11);unsigned;not null;default:0;column:event_time"`
+}
+
+func (f inviteCodeEventV2) TableName() string {
+	return "invite_code_events"
+}
+
+type inviteCodeV3 struct {
+	ValidFrom uint64 `gorm:"type:int(11);unsigned;not null;default:0;column:valid_from"`
+	ExpiresAt uint64 `gorm:"type:int(11);unsigned;not null;default:0;column:expires_at"`
+}
+
+func (f inviteCodeV3) TableName() string {
+	return "invite_codes"
+}
+
+type inviteCodeV4 struct {
+	RevokeTime uint64 `gorm:"type:int(11);unsigned;not null;default:0;column:revoke_time"`
+}
+
+func (f inviteCodeV4) TableName() string {
+	return "invite_codes"
+}
+
+type inviteCodeBindingV4 struct {
+	db.BaseModel
+
+	InviteCode  string `gorm:"type:varchar(10);not null;default:'';column:invite_code;index"`
+	UserAddress string `gorm:"type:varchar(80);not null;default:'';column:user_address;index"`
+	DiscordId   string `gorm:"type:varchar(80);not null;default:'';column:discord_id"`
+	DiscordName string `gorm:"type:varchar(80);not null;default:'';column:discord_name"`
+	UserId      string `gorm:"type:varchar(80);not null;default:'';column:user_id"`
+	BindTime    uint64 `gorm:"type:int(11);unsigned;not null;default:0;column:bind_time"`
+
+	Action     string `gorm:"type:varchar(32);not null;default:'';column:action"`
+	Actor      string `gorm:"type:varchar(80);not null;default:'';column:actor"`
+	Reason     string `gorm:"type:varchar(255);not null;default:'';column:reason"`
+	UnbindTime uint64 `gorm:"type:int(11);unsigned;not null;default:0;column:unbind_time"`
+}
+
+func (f inviteCodeBindingV4) TableName() string {
+	return "invite_code_bindings"
+}
+
+type inviteCodeV5 struct {
+	MaxUses  uint64 `gorm:"type:int(11);unsigned;not null;default:1;column:max_uses"`
+	UseCount uint64 `gorm:"type:int(11);unsigned;not null;default:0;column:use_count"`
+}
+
+func (f inviteCodeV5) TableName() string {
+	return "invite_codes"
+}
+
+type inviteCodeRedemptionV5 struct {
+	db.BaseModel
+
+	InviteCode  string  `gorm:"type:varchar(10);not null;default:'';column:invite_code;index"`
+	UserAddress *string `gorm:"type:varchar(80);column:user_address;uniqueIndex"`
+	DiscordId   *string `gorm:"type:varchar(80);column:discord_id;uniqueIndex"`
+	DiscordName *string `gorm:"type:varchar(80);column:discord_name;"`
+	UserId      *string `gorm:"type:varchar(80);column:user_id;uniqueIndex"`
+	RedeemTime  uint64  `gorm:"type:int(11);unsigned;not null;default:0;column:redeem_time"`
+}
+
+func (f inviteCodeRedemptionV5) TableName() string {
+	return "invite_code_redemptions"
+}
+
+type inviteCodeV6 struct {
+	InviterAddress *string `gorm:"type:varchar(80);column:inviter_address;uniqueIndex:inviter_seq_index"`
+	ReferralSeq    uint8   `gorm:"type:tinyint(1);unsigned;not null;default:0;column:referral_seq;uniqueIndex:inviter_seq_index"`
+}
+
+func (f inviteCodeV6) TableName() string {
+	return "invite_codes"
+}
+
+type campaignV7 struct {
+	db.BaseModel
+
+	Name           string `gorm:"type:varchar(64);not null;default:'';column:name;uniqueIndex"`
+	ZealySubdomain string `gorm:"type:varchar(80);not null;default:'';column:zealy_subdomain"`
+
+	StartTime uint64 `gorm:"type:int(11);unsigned;not null;default:0;column:start_time"`
+	EndTime   uint64 `gorm:"type:int(11);unsigned;not null;default:0;column:end_time"`
+
+	TaskInviteCodeCount   uint64 `gorm:"type:int(11);unsigned;not null;default:0;column:task_invite_code_count"`
+	DirectInviteCodeCount uint64 `gorm:"type:int(11);unsigned;not null;default:0;column:direct_invite_code_count"`
+	DropletRound          uint8  `gorm:"type:tinyint(1);unsigned;not null;default:0;column:droplet_round"`
+}
+
+func (f campaignV7) TableName() string {
+	return "campaigns"
+}
+
+type inviteCodeV7 struct {
+	CampaignId int64 `gorm:"not null;default:0;column:campaign_id;uniqueIndex:code_user_address_index,priority:1;uniqueIndex:code_discord_id_index,priority:1;uniqueIndex:code_user_id_index,priority:1;uniqueIndex:inviter_seq_index,priority:1"`
+
+	UserAddress    *string `gorm:"type:varchar(80);column:user_address;uniqueIndex:code_user_address_index"`
+	DiscordId      *string `gorm:"type:varchar(80);column:discord_id;uniqueIndex:code_discord_id_index"`
+	UserId         *string `gorm:"type:varchar(80);column:user_id;uniqueIndex:code_user_id_index"`
+	InviterAddress *string `gorm:"type:varchar(80);column:inviter_address;uniqueIndex:inviter_seq_index"`
+	ReferralSeq    uint8   `gorm:"type:tinyint(1);unsigned;not null;default:0;column:referral_seq;uniqueIndex:inviter_seq_index"`
+}
+
+func (f inviteCodeV7) TableName() string {
+	return "invite_codes"
+}
+
+type inviteCodeRedemptionV7 struct {
+	CampaignId  int64   `gorm:"not null;default:0;column:campaign_id;uniqueIndex:redemption_user_address_index,priority:1;uniqueIndex:redemption_discord_id_index,priority:1;uniqueIndex:redemption_user_id_index,priority:1"`
+	UserAddress *string `gorm:"type:varchar(80);column:user_address;uniqueIndex:redemption_user_address_index"`
+	DiscordId   *string `gorm:"type:varchar(80);column:discord_id;uniqueIndex:redemption_discord_id_index"`
+	UserId      *string `gorm:"type:varchar(80);column:user_id;uniqueIndex:redemption_user_id_index"`
+}
+
+func (f inviteCodeRedemptionV7) TableName() string {
+	return "invite_code_redemptions"
+}
+
+type dropletCodeV7 struct {
+	CampaignId int64 `gorm:"not null;default:0;column:campaign_id;index:campaign_round_index"`
+	Round      uint8 `gorm:"type:tinyint(1);unsigned;not null;default:0;column:round;index:campaign_round_index"`
+}
+
+func (f dropletCodeV7) TableName() string {
+	return "droplet_codes"
+}
+
+type inviteCodeEventV7 struct {
+	CampaignId int64 `gorm:"not null;default:0;column:campaign_id"`
+}
+
+func (f inviteCodeEventV7) TableName() string {
+	return "invite_code_events"
+}
+
+type batchV8 struct {
+	db.BaseModel
+
+	Name string `gorm:"type:varchar(64);not null;default:'';column:name;uniqueIndex"`
+}
+
+func (f batchV8) TableName() string {
+	return "invite_code_batches"
+}
+
+type inviteCodeV8 struct {
+	BatchId int64 `gorm:"not null;default:0;column:batch_id;index"`
+}
+
+func (f inviteCodeV8) TableName() string {
+	return "invite_codes"
+}
+
+type inviteCodeV9 struct {
+	CampaignId int64  `gorm:"not null;default:0;column:campaign_id;index:alloc_key_index,priority:1"`
+	CodeType   uint8  `gorm:"type:tinyint(1);unsigned;not null;default:0;column:code_type;index:alloc_key_index,priority:2"`
+	BindTime   uint64 `gorm:"type:int(11);unsigned;not null;default:0;column:bind_time;index:alloc_key_index,priority:3"`
+	RevokeTime uint64 `gorm:"type:int(11);unsigned;not null;default:0;column:revoke_time;index:alloc_key_index,priority:4"`
+	AllocKey   int64  `gorm:"not null;default:0;column:alloc_key;index:alloc_key_index,priority:5"`
+}
+
+func (f inviteCodeV9) TableName() string {
+	return "invite_codes"
+}
+
+type batchV10 struct {
+	Owner string `gorm:"type:varchar(80);not null;default:'';column:owner"`
+	Notes string `gorm:"type:varchar(255);not null;default:'';column:notes"`
+}
+
+func (f batchV10) TableName() string {
+	return "invite_code_batches"
+}
+
+type dropletRoundV11 struct {
+	db.BaseModel
+
+	CampaignId int64  `gorm:"not null;default:0;column:campaign_id;uniqueIndex:droplet_round_index"`
+	Round      uint8  `gorm:"type:tinyint(1);unsigned;not null;default:0;column:round;uniqueIndex:droplet_round_index"`
+	OpenTime   uint64 `gorm:"type:int(11);unsigned;not null;default:0;column:open_time"`
+	CloseTime  uint64 `gorm:"type:int(11);unsigned;not null;default:0;column:close_time"`
+}
+
+func (f dropletRoundV11) TableName() string {
+	return "droplet_rounds"
+}
+
+type dropletRoundV12 struct {
+	Droplets        uint8 `gorm:"type:tinyint(1);unsigned;not null;default:0;column:droplets"`
+	CodesPerDroplet uint8 `gorm:"type:tinyint(1);unsigned;not null;default:0;column:codes_per_droplet"`
+}
+
+func (f dropletRoundV12) TableName() string {
+	return "droplet_rounds"
+}
+
+type eventCursorV13 struct {
+	db.BaseModel
+
+	Name    string `gorm:"type:varchar(128);not null;default:'';column:name;uniqueIndex"`
+	EventId int64  `gorm:"not null;default:0;column:event_id"`
+}
+
+func (f eventCursorV13) TableName() string {
+	return "invite_code_event_cursors"
+}
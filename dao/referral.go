@@ -0,0 +1,168 @@
+package dao
+
+import (
+	"errors"
+	"fmt"
+	"invite-code-service/pkg/db"
+
+	"gorm.io/gorm"
+)
+
+const maxGenRetry = 10
+
+const maxReferralCodes = 255
+
+// errReferralSeqTaken is returned when a concurrent issuer created the same
+// referral code of an owner
+var errReferralSeqTaken = errors.New("referral code issued concurrently")
+
+// IssueReferralCodes gives owner personal referral codes of spec.CampaignId up
+// to spec.Count, codes already issued are kept and spec.CodeType is ignored. The
+// unique (inviter_address, referral_seq) index makes concurrent issuing for the
+// same owner lose instead of over issuing, the loser returns the codes of the
+// winner.
+func IssueReferralCodes(db *db.WrapDb, owner string, spec GenerateSpec, meta EventMeta) ([]*InviteCode, error) {
+	err := db.Transaction(func(tx *gorm.DB) error {
+		return issueReferralCodes(tx, owner, &spec, meta)
+	})
+	if err != nil && !errors.Is(err, errReferralSeqTaken) {
+		return nil, err
+	}
+	return GetReferralCodes(db, spec.CampaignId, owner)
+}
+
+// issueReferralCodes issues the missing referral codes of owner in tx
+func issueReferralCodes(tx *gorm.DB, owner string, spec *GenerateSpec, meta EventMeta) error {
+	if spec.Count > maxReferralCodes {
+		return fmt.Errorf("over max referral codes: %d", maxReferralCodes)
+	}
+	var seqs []uint8
+	err := tx.Model(&InviteCode{}).Scopes(inCampaign(spec.CampaignId)).
+		Where("inviter_address = ? AND code_type = ?", owner, ReferralInviteCode).
+		Pluck("referral_seq", &seqs).Error
+	if err != nil {
+		return err
+	}
+	issued := make(map[uint8]bool, len(seqs))
+	for _, seq := range seqs {
+		issued[seq] = true
+	}
+
+	for seq := uint8(1); int64(seq) <= spec.Count && seq > 0; seq++ {
+		if issued[seq] {
+			continue
+		}
+		c, err := createReferralCode(tx, owner, seq, spec)
+		if err != nil {
+			return err
+		}
+		if err := createInviteCodeEvent(tx, c, EventGenerated, meta); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// createReferralCode inserts the referral code seq of owner, a code taken by a
+// concurrent insert is rolled back to a savepoint and regenerated so the
+// transaction goes on. A seq that stays taken was issued by a concurrent
+// issuer, errReferralSeqTaken is returned then.
+func createReferralCode(tx *gorm.DB, owner string, seq uint8, spec *GenerateSpec) (*InviteCode, error) {
+	const savepoint = "referral_code"
+	for retry := 0; ; retry++ {
+		code, err := genUnusedInviteCode(tx, spec)
+		if err != nil {
+			return nil, err
+		}
+		c := spec.inviteCode(code)
+		c.CodeType = ReferralInviteCode
+		c.InviterAddress = &owner
+		c.ReferralSeq = seq
+
+		if err := tx.SavePoint(savepoint).Error; err != nil {
+			return nil, err
+		}
+		err = tx.Create(c).Error
+		if err == nil {
+			return c, nil
+		}
+		if !errors.Is(err, gorm.ErrDuplicatedKey) {
+			return nil, err
+		}
+		if err := tx.RollbackTo(savepoint).Error; err != nil {
+			return nil, err
+		}
+		if retry >= maxGenRetry {
+			return nil, errReferralSeqTaken
+		}
+	}
+}
+
+// issueBoundReferralCodes issues the referral codes of the user just bound to
+// c in the bind transaction, none if referral is nil
+func issueBoundReferralCodes(tx *gorm.DB, c *InviteCode, referral *GenerateSpec, meta EventMeta) error {
+	if referral == nil || c.UserAddress == nil || len(*c.UserAddress) == 0 {
+		return nil
+	}
+	return issueReferralCodes(tx, *c.UserAddress, referral, meta)
+}
+
+// genUnusedInviteCode generates a code of spec not taken yet
+func genUnusedInviteCode(tx *gorm.DB, spec *GenerateSpec) (string, error) {
+	for i := 0; i < maxGenRetry; i++ {
+		code, err := spec.newCode()
+		if err != nil {
+			return "", err
+		}
+		err = tx.Take(&InviteCode{}, "invite_code = ?", code).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return code, nil
+		}
+		if err != nil {
+			return "", err
+		}
+	}
+	return "", fmt.Errorf("no unused invite code after %d tries", maxGenRetry)
+}
+
+// GetReferralCodes returns the referral codes owned by owner in a campaign
+func GetReferralCodes(db *db.WrapDb, campaignId int64, owner string) (list []*InviteCode, err error) {
+	err = db.Scopes(inCampaign(campaignId)).Where("inviter_address = ? AND code_type = ?", owner, ReferralInviteCode).
+		Order("referral_seq ASC").
+		Find(&list).Error
+	return
+}
+
+type ReferralRank struct {
+	InviterAddress string `gorm:"column:inviter_address"`
+	Invites        int64  `gorm:"column:invites"`
+}
+
+// GetUnreferredUserAddresses returns the users bound in a campaign that own
+// fewer than count referral codes, e.g. the ones bound before referral codes
+// were configured
+func GetUnreferredUserAddresses(db *db.WrapDb, campaignId int64, count int64) (list []string, err error) {
+	referred := db.Model(&InviteCode{}).Scopes(inCampaign(campaignId)).
+		Select("inviter_address").
+		Where("code_type = ? AND inviter_address IS NOT NULL", ReferralInviteCode).
+		Group("inviter_address").
+		Having("COUNT(*) >= ?", count)
+	err = db.Model(&InviteCodeRedemption{}).Scopes(inCampaign(campaignId)).
+		Where("user_address IS NOT NULL AND user_address NOT IN (?)", referred).
+		Distinct("user_address").
+		Order("user_address ASC").
+		Pluck("user_address", &list).Error
+	return
+}
+
+// GetReferralLeaderboard ranks the inviters of a campaign by how many users redeemed their codes
+func GetReferralLeaderboard(db *db.WrapDb, campaignId int64, limit int) (list []*ReferralRank, err error) {
+	err = db.Model(&InviteCode{}).Scopes(inCampaign(campaignId)).
+		Select("inviter_address, SUM(use_count) AS invites").
+		Where("code_type = ? AND inviter_address IS NOT NULL AND use_count > 0", ReferralInviteCode).
+		Group("inviter_address").
+		Order("invites DESC, inviter_address ASC").
+		Limit(limit).
+		Scan(&list).Error
+	return
+}
@@ -0,0 +1,88 @@
+package dao
+
+import (
+	"fmt"
+	"invite-code-service/pkg/db"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// SearchFilter selects the codes of an admin search, zero fields match everything
+type SearchFilter struct {
+	ExportFilter
+
+	// CodePrefix matches the codes starting with it
+	CodePrefix string
+	// the user fields match codes with a redemption by the user, so multi-use
+	// codes are found by any of their users
+	UserAddress string
+	DiscordId   string
+	// DiscordName matches the names containing it
+	DiscordName string
+}
+
+// SearchSort orders search results by Field, ties are broken by id
+type SearchSort struct {
+	Field string
+	Desc  bool
+}
+
+// SearchSortFields are the fields a search can be sorted by
+var SearchSortFields = []string{"id", "create_time", "bind_time", "use_count", "expires_at"}
+
+// Check reports a Field that isn't in SearchSortFields
+func (s SearchSort) Check() error {
+	for _, f := range SearchSortFields {
+		if f == s.Field {
+			return nil
+		}
+	}
+	return fmt.Errorf("can't sort by %q", s.Field)
+}
+
+// likeEscaper escapes the LIKE wildcards of user input, with ! as the escape
+// character. \ would be taken as an escape inside the string literal by mysql
+// unless NO_BACKSLASH_ESCAPES is set, ! means the same to mysql and sqlite.
+var likeEscaper = strings.NewReplacer(`!`, `!!`, `%`, `!%`, `_`, `!_`)
+
+func (f *SearchFilter) scope(tx *gorm.DB) *gorm.DB {
+	tx = tx.Scopes(f.ExportFilter.scope)
+	if len(f.CodePrefix) > 0 {
+		tx = tx.Where(`invite_code LIKE ? ESCAPE '!'`, likeEscaper.Replace(f.CodePrefix)+"%")
+	}
+	if len(f.UserAddress) > 0 {
+		tx = tx.Where("invite_code IN (SELECT invite_code FROM invite_code_redemptions WHERE user_address = ?)", f.UserAddress)
+	}
+	if len(f.DiscordId) > 0 {
+		tx = tx.Where("invite_code IN (SELECT invite_code FROM invite_code_redemptions WHERE discord_id = ?)", f.DiscordId)
+	}
+	if len(f.DiscordName) > 0 {
+		tx = tx.Where(`invite_code IN (SELECT invite_code FROM invite_code_redemptions WHERE discord_name LIKE ? ESCAPE '!')`,
+			"%"+likeEscaper.Replace(f.DiscordName)+"%")
+	}
+	return tx
+}
+
+// SearchInviteCodes returns a page of the codes matching filter and how many match in total
+func SearchInviteCodes(db *db.WrapDb, filter SearchFilter, order SearchSort, offset, limit int) ([]*InviteCode, int64, error) {
+	if err := order.Check(); err != nil {
+		return nil, 0, err
+	}
+	var total int64
+	if err := db.Model(&InviteCode{}).Scopes(filter.scope).Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	direction := "ASC"
+	if order.Desc {
+		direction = "DESC"
+	}
+	var list []*InviteCode
+	err := db.Scopes(filter.scope).Order(fmt.Sprintf("%s %s, id %s", order.Field, direction, direction)).
+		Offset(offset).Limit(limit).Find(&list).Error
+	if err != nil {
+		return nil, 0, err
+	}
+	return list, total, nil
+}
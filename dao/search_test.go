@@ -0,0 +1,92 @@
+package dao_test
+
+import (
+	"invite-code-service/dao"
+	"testing"
+)
+
+func TestSearchInviteCodes(t *testing.T) {
+	store := dao.NewMemStore()
+	batch, err := store.GetOrCreateBatch(dao.Batch{Name: "kol-a"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range []*dao.InviteCode{
+		{InviteCode: "AB000001", CodeType: dao.DirectInviteCode, BatchId: batch.ID},
+		{InviteCode: "AB000002", CodeType: dao.DirectInviteCode, BatchId: batch.ID},
+		{InviteCode: "AB_00003", CodeType: dao.DirectInviteCode, MaxUses: 2},
+		{InviteCode: "CD000001", CodeType: dao.TaskInviteCode},
+	} {
+		if err := store.CreateInviteCode(c, dao.SystemEventMeta); err != nil {
+			t.Fatal(err)
+		}
+	}
+	redeem := func(code, address, discordId, discordName string, bindTime uint64) {
+		c, err := store.GetInviteCode(code)
+		if err != nil {
+			t.Fatal(err)
+		}
+		c.UserAddress, c.DiscordId, c.DiscordName = &address, &discordId, &discordName
+		c.BindTime = bindTime
+		if err := store.CheckBondAndUpdateInviteCode(c, dao.EventBoundBind, dao.SystemEventMeta, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	redeem("AB000002", "0xaaa", "d1", "Alice", 1000)
+	redeem("AB_00003", "0xbbb", "d2", "bo!b_50%", 2000)
+	redeem("AB_00003", "0xccc", "d3", "Carol", 3000)
+
+	search := func(filter dao.SearchFilter, order dao.SearchSort, offset, limit int) ([]string, int64) {
+		list, total, err := store.SearchInviteCodes(filter, order, offset, limit)
+		if err != nil {
+			t.Fatal(err)
+		}
+		codes := make([]string, 0, len(list))
+		for _, c := range list {
+			codes = append(codes, c.InviteCode)
+		}
+		return codes, total
+	}
+	byId := dao.SearchSort{Field: "id"}
+
+	codes, total := search(dao.SearchFilter{}, byId, 1, 2)
+	if total != 4 || len(codes) != 2 || codes[0] != "AB000002" || codes[1] != "AB_00003" {
+		t.Fatalf("unexpected page: %v of %d", codes, total)
+	}
+	// _ is not a wildcard
+	if codes, total := search(dao.SearchFilter{CodePrefix: "AB_"}, byId, 0, 10); total != 1 || codes[0] != "AB_00003" {
+		t.Fatalf("unexpected prefix match: %v", codes)
+	}
+	// multi-use codes are found by any of their users
+	if codes, _ := search(dao.SearchFilter{UserAddress: "0xccc"}, byId, 0, 10); len(codes) != 1 || codes[0] != "AB_00003" {
+		t.Fatalf("unexpected address match: %v", codes)
+	}
+	if codes, _ := search(dao.SearchFilter{DiscordId: "d1"}, byId, 0, 10); len(codes) != 1 || codes[0] != "AB000002" {
+		t.Fatalf("unexpected discord id match: %v", codes)
+	}
+	if codes, _ := search(dao.SearchFilter{DiscordName: "aro"}, byId, 0, 10); len(codes) != 1 || codes[0] != "AB_00003" {
+		t.Fatalf("unexpected discord name match: %v", codes)
+	}
+	// the escape character and the wildcards match themselves only
+	if codes, _ := search(dao.SearchFilter{DiscordName: "!b_50%"}, byId, 0, 10); len(codes) != 1 || codes[0] != "AB_00003" {
+		t.Fatalf("unexpected discord name match: %v", codes)
+	}
+	for _, name := range []string{"o_b", "o%0", "b!_", "!!"} {
+		if codes, _ := search(dao.SearchFilter{DiscordName: name}, byId, 0, 10); len(codes) != 0 {
+			t.Fatalf("expect no discord name match for %q, got %v", name, codes)
+		}
+	}
+	if codes, _ := search(dao.SearchFilter{ExportFilter: dao.ExportFilter{BatchId: batch.ID}}, byId, 0, 10); len(codes) != 2 {
+		t.Fatalf("unexpected batch match: %v", codes)
+	}
+
+	yes := true
+	codes, _ = search(dao.SearchFilter{ExportFilter: dao.ExportFilter{Bound: &yes}}, dao.SearchSort{Field: "use_count", Desc: true}, 0, 10)
+	if len(codes) != 2 || codes[0] != "AB_00003" || codes[1] != "AB000002" {
+		t.Fatalf("unexpected sorted bound codes: %v", codes)
+	}
+
+	if _, _, err := store.SearchInviteCodes(dao.SearchFilter{}, dao.SearchSort{Field: "invite_code; DROP TABLE"}, 0, 10); err == nil {
+		t.Fatal("expect unknown sort field rejected")
+	}
+}
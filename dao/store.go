@@ -0,0 +1,244 @@
+package dao
+
+import (
+	"invite-code-service/pkg/db"
+)
+
+// InviteCodeStore covers every invite code query used by handlers, services and commands
+type InviteCodeStore interface {
+	CreateInviteCode(c *InviteCode, meta EventMeta) error
+	GenerateInviteCodes(spec GenerateSpec, meta EventMeta) ([]*InviteCode, error)
+	GetExistingInviteCodes(codes []string) ([]string, error)
+	ImportInviteCodes(codes []ImportedCode, batchInfo Batch, meta EventMeta) error
+	CheckBondAndUpdateInviteCode(c *InviteCode, eventType string, meta EventMeta, referral *GenerateSpec) error
+	GetInviteCode(code string) (*InviteCode, error)
+	GetInviteCodeCount(campaignId int64, codeType uint8) (int64, error)
+	GetInviteCodeByUserAddress(campaignId int64, user string) (*InviteCode, error)
+	GetInviteCodeByDiscordId(campaignId int64, discordId string) (*InviteCode, error)
+	GetAvailableTaskInviteCode(campaignId int64) (*InviteCode, error)
+	ClaimTaskInviteCode(campaignId int64, claim TaskCodeClaim, meta EventMeta, referral *GenerateSpec) (*InviteCode, error)
+	GetTaskInviteCodeStats(campaignId int64) (*InviteCodeStats, error)
+	GetAllInviteCodeStats(campaignId int64) (*InviteCodeStats, error)
+	GetInviteCodeTypeStats(campaignId int64, codeType uint8) (*InviteCodeStats, error)
+	UnbindInviteCode(code string, revoke bool, reason string, meta EventMeta) (*InviteCode, []*InviteCodeRedemption, error)
+	RebindInviteCode(code string, user CodeUser, reason string, meta EventMeta, referral *GenerateSpec) (*InviteCode, []*InviteCodeRedemption, error)
+	GetInviteCodeRedemptions(code string) ([]*InviteCodeRedemption, error)
+	ExportInviteCodes(filter ExportFilter, fn func(*ExportedCode) error) error
+	SearchInviteCodes(filter SearchFilter, order SearchSort, offset, limit int) ([]*InviteCode, int64, error)
+	GetInviteCodeBindings(code string) ([]*InviteCodeBinding, error)
+	GetInviteCodeEvents(key string) ([]*InviteCodeEvent, error)
+	GetInviteCodeEventsAfter(afterId int64, eventTypes []string, limit int) ([]*InviteCodeEvent, error)
+	GetEventCursor(name string) (int64, error)
+	SaveEventCursor(name string, eventId int64) error
+	IssueReferralCodes(owner string, spec GenerateSpec, meta EventMeta) ([]*InviteCode, error)
+	GetReferralCodes(campaignId int64, owner string) ([]*InviteCode, error)
+	GetUnreferredUserAddresses(campaignId int64, count int64) ([]string, error)
+	GetReferralLeaderboard(campaignId int64, limit int) ([]*ReferralRank, error)
+}
+
+// DropletStore covers every droplet code query
+type DropletStore interface {
+	GetLatestDropletCodesWithStatus(campaignId int64) (*DropletRound, []*DropletCodeWithStatus, error)
+	GetInviteCodeDroplets(code string) ([]*DropletCode, error)
+	GetDropletRounds(campaignId int64) ([]*DropletRound, error)
+	GetOpenDropletRound(campaignId int64) (*DropletRound, error)
+	OpenDropletRound(campaignId int64, round uint8, shape DropletShape) (*DropletRound, error)
+	CloseDropletRound(campaignId int64) (*DropletRound, error)
+}
+
+// CampaignStore covers every campaign query
+type CampaignStore interface {
+	GetCampaign(name string) (*Campaign, error)
+	GetCampaigns() ([]*Campaign, error)
+	SaveCampaign(c *Campaign) error
+}
+
+// BatchStore covers every batch query
+type BatchStore interface {
+	GetBatch(name string) (*Batch, error)
+	GetBatches() ([]*Batch, error)
+	GetOrCreateBatch(b Batch) (*Batch, error)
+	GetBatchStats(batchId int64, interval uint64) (*BatchStats, error)
+}
+
+type Store interface {
+	InviteCodeStore
+	DropletStore
+	CampaignStore
+	BatchStore
+}
+
+// DbStore is the Store backed by mysql or sqlite
+type DbStore struct {
+	db *db.WrapDb
+}
+
+var _ Store = (*DbStore)(nil)
+
+func NewDbStore(db *db.WrapDb) *DbStore {
+	return &DbStore{db: db}
+}
+
+func (s *DbStore) CreateInviteCode(c *InviteCode, meta EventMeta) error {
+	return CreateInviteCode(s.db, c, meta)
+}
+
+func (s *DbStore) GenerateInviteCodes(spec GenerateSpec, meta EventMeta) ([]*InviteCode, error) {
+	return GenerateInviteCodes(s.db, spec, meta)
+}
+
+func (s *DbStore) CheckBondAndUpdateInviteCode(c *InviteCode, eventType string, meta EventMeta, referral *GenerateSpec) error {
+	return CheckBondAndUpdateInviteCode(s.db, c, eventType, meta, referral)
+}
+
+func (s *DbStore) GetInviteCode(code string) (*InviteCode, error) {
+	return GetInviteCode(s.db, code)
+}
+
+func (s *DbStore) GetInviteCodeCount(campaignId int64, codeType uint8) (int64, error) {
+	return GetInviteCodeCount(s.db, campaignId, codeType)
+}
+
+func (s *DbStore) GetInviteCodeByUserAddress(campaignId int64, user string) (*InviteCode, error) {
+	return GetInviteCodeByUserAddress(s.db, campaignId, user)
+}
+
+func (s *DbStore) GetInviteCodeByDiscordId(campaignId int64, discordId string) (*InviteCode, error) {
+	return GetInviteCodeByDiscordId(s.db, campaignId, discordId)
+}
+
+func (s *DbStore) GetAvailableTaskInviteCode(campaignId int64) (*InviteCode, error) {
+	return GetAvailableTaskInviteCode(s.db, campaignId)
+}
+
+func (s *DbStore) ClaimTaskInviteCode(campaignId int64, claim TaskCodeClaim, meta EventMeta, referral *GenerateSpec) (*InviteCode, error) {
+	return ClaimTaskInviteCode(s.db, campaignId, claim, meta, referral)
+}
+
+func (s *DbStore) GetTaskInviteCodeStats(campaignId int64) (*InviteCodeStats, error) {
+	return GetTaskInviteCodeStats(s.db, campaignId)
+}
+
+func (s *DbStore) GetAllInviteCodeStats(campaignId int64) (*InviteCodeStats, error) {
+	return GetAllInviteCodeStats(s.db, campaignId)
+}
+
+func (s *DbStore) GetInviteCodeTypeStats(campaignId int64, codeType uint8) (*InviteCodeStats, error) {
+	return GetInviteCodeTypeStats(s.db, campaignId, codeType)
+}
+
+func (s *DbStore) GetInviteCodeEvents(key string) ([]*InviteCodeEvent, error) {
+	return GetInviteCodeEvents(s.db, key)
+}
+
+func (s *DbStore) UnbindInviteCode(code string, revoke bool, reason string, meta EventMeta) (*InviteCode, []*InviteCodeRedemption, error) {
+	return UnbindInviteCode(s.db, code, revoke, reason, meta)
+}
+
+func (s *DbStore) RebindInviteCode(code string, user CodeUser, reason string, meta EventMeta, referral *GenerateSpec) (*InviteCode, []*InviteCodeRedemption, error) {
+	return RebindInviteCode(s.db, code, user, reason, meta, referral)
+}
+
+func (s *DbStore) GetInviteCodeRedemptions(code string) ([]*InviteCodeRedemption, error) {
+	return GetInviteCodeRedemptions(s.db, code)
+}
+
+func (s *DbStore) GetExistingInviteCodes(codes []string) ([]string, error) {
+	return GetExistingInviteCodes(s.db, codes)
+}
+
+func (s *DbStore) ImportInviteCodes(codes []ImportedCode, batchInfo Batch, meta EventMeta) error {
+	return ImportInviteCodes(s.db, codes, batchInfo, meta)
+}
+
+func (s *DbStore) ExportInviteCodes(filter ExportFilter, fn func(*ExportedCode) error) error {
+	return ExportInviteCodes(s.db, filter, fn)
+}
+
+func (s *DbStore) SearchInviteCodes(filter SearchFilter, order SearchSort, offset, limit int) ([]*InviteCode, int64, error) {
+	return SearchInviteCodes(s.db, filter, order, offset, limit)
+}
+
+func (s *DbStore) GetInviteCodeBindings(code string) ([]*InviteCodeBinding, error) {
+	return GetInviteCodeBindings(s.db, code)
+}
+
+func (s *DbStore) GetInviteCodeEventsAfter(afterId int64, eventTypes []string, limit int) ([]*InviteCodeEvent, error) {
+	return GetInviteCodeEventsAfter(s.db, afterId, eventTypes, limit)
+}
+
+func (s *DbStore) GetEventCursor(name string) (int64, error) {
+	return GetEventCursor(s.db, name)
+}
+
+func (s *DbStore) SaveEventCursor(name string, eventId int64) error {
+	return SaveEventCursor(s.db, name, eventId)
+}
+
+func (s *DbStore) IssueReferralCodes(owner string, spec GenerateSpec, meta EventMeta) ([]*InviteCode, error) {
+	return IssueReferralCodes(s.db, owner, spec, meta)
+}
+
+func (s *DbStore) GetReferralCodes(campaignId int64, owner string) ([]*InviteCode, error) {
+	return GetReferralCodes(s.db, campaignId, owner)
+}
+
+func (s *DbStore) GetUnreferredUserAddresses(campaignId int64, count int64) ([]string, error) {
+	return GetUnreferredUserAddresses(s.db, campaignId, count)
+}
+
+func (s *DbStore) GetReferralLeaderboard(campaignId int64, limit int) ([]*ReferralRank, error) {
+	return GetReferralLeaderboard(s.db, campaignId, limit)
+}
+
+func (s *DbStore) GetLatestDropletCodesWithStatus(campaignId int64) (*DropletRound, []*DropletCodeWithStatus, error) {
+	return GetLatestDropletCodesWithStatus(s.db, campaignId)
+}
+
+func (s *DbStore) GetInviteCodeDroplets(code string) ([]*DropletCode, error) {
+	return GetInviteCodeDroplets(s.db, code)
+}
+
+func (s *DbStore) GetDropletRounds(campaignId int64) ([]*DropletRound, error) {
+	return GetDropletRounds(s.db, campaignId)
+}
+
+func (s *DbStore) GetOpenDropletRound(campaignId int64) (*DropletRound, error) {
+	return GetOpenDropletRound(s.db, campaignId)
+}
+
+func (s *DbStore) OpenDropletRound(campaignId int64, round uint8, shape DropletShape) (*DropletRound, error) {
+	return OpenDropletRound(s.db, campaignId, round, shape)
+}
+
+func (s *DbStore) CloseDropletRound(campaignId int64) (*DropletRound, error) {
+	return CloseDropletRound(s.db, campaignId)
+}
+
+func (s *DbStore) GetCampaign(name string) (*Campaign, error) {
+	return GetCampaign(s.db, name)
+}
+
+func (s *DbStore) GetCampaigns() ([]*Campaign, error) {
+	return GetCampaigns(s.db)
+}
+
+func (s *DbStore) SaveCampaign(c *Campaign) error {
+	return SaveCampaign(s.db, c)
+}
+
+func (s *DbStore) GetBatch(name string) (*Batch, error) {
+	return GetBatch(s.db, name)
+}
+
+func (s *DbStore) GetBatches() ([]*Batch, error) {
+	return GetBatches(s.db)
+}
+
+func (s *DbStore) GetOrCreateBatch(b Batch) (*Batch, error) {
+	return GetOrCreateBatch(s.db, b)
+}
+
+func (s *DbStore) GetBatchStats(batchId int64, interval uint64) (*BatchStats, error) {
+	return GetBatchStats(s.db, batchId, interval)
+}
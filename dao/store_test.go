@@ -0,0 +1,702 @@
+package dao_test
+
+import (
+	"errors"
+	"invite-code-service/dao"
+	"invite-code-service/pkg/utils"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+func TestStoreInviteCode(t *testing.T) {
+	store := dao.NewMemStore()
+	for _, code := range []string{"TASK0001", "TASK0002"} {
+		if err := store.CreateInviteCode(&dao.InviteCode{InviteCode: code, CodeType: dao.TaskInviteCode}, dao.SystemEventMeta); err != nil {
+			t.Fatal(err)
+		}
+	}
+	err := store.CreateInviteCode(&dao.InviteCode{InviteCode: "TASK0001", CodeType: dao.DirectInviteCode}, dao.SystemEventMeta)
+	if !errors.Is(err, gorm.ErrDuplicatedKey) {
+		t.Fatalf("expect ErrDuplicatedKey, got %v", err)
+	}
+
+	inviteCode, err := store.GetAvailableTaskInviteCode(dao.DefaultCampaignId)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	address := "0xabc"
+	inviteCode.UserAddress = &address
+	inviteCode.BindTime = 1
+	meta := dao.EventMeta{Actor: address, SourceIp: "127.0.0.1", PayloadHash: "0x01"}
+	if err := store.CheckBondAndUpdateInviteCode(inviteCode, dao.EventBoundBind, meta, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.CheckBondAndUpdateInviteCode(inviteCode, dao.EventBoundBind, meta, nil); !errors.Is(err, dao.ErrAlreadyBond) {
+		t.Fatalf("expect ErrAlreadyBond, got %v", err)
+	}
+
+	// the other code can't take the same address
+	other, err := store.GetAvailableTaskInviteCode(dao.DefaultCampaignId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if other.InviteCode == inviteCode.InviteCode {
+		t.Fatal("bound code should not be available")
+	}
+	other.UserAddress = &address
+	other.BindTime = 1
+	if err := store.CheckBondAndUpdateInviteCode(other, dao.EventBoundGen, meta, nil); !errors.Is(err, gorm.ErrDuplicatedKey) {
+		t.Fatalf("expect ErrDuplicatedKey, got %v", err)
+	}
+
+	stats, err := store.GetTaskInviteCodeStats(dao.DefaultCampaignId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.TotalCodes != 2 || stats.RemainCodes != 1 {
+		t.Fatalf("unexpected stats: %+v", stats)
+	}
+
+	bound, err := store.GetInviteCodeByUserAddress(dao.DefaultCampaignId, address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bound.InviteCode != inviteCode.InviteCode {
+		t.Fatalf("expect %s, got %s", inviteCode.InviteCode, bound.InviteCode)
+	}
+
+	events, err := store.GetInviteCodeEvents(address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 1 || events[0].EventType != dao.EventBoundBind || events[0].InviteCode != inviteCode.InviteCode || events[0].SourceIp != "127.0.0.1" {
+		t.Fatalf("unexpected address events: %+v", events)
+	}
+	events, err = store.GetInviteCodeEvents(inviteCode.InviteCode)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 2 || events[0].EventType != dao.EventGenerated || events[0].Actor != dao.ActorSystem {
+		t.Fatalf("unexpected code events: %+v", events)
+	}
+
+	if _, err := store.GetInviteCodeByDiscordId(dao.DefaultCampaignId, "nobody"); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Fatalf("expect ErrRecordNotFound, got %v", err)
+	}
+}
+
+func TestStoreDropletCodes(t *testing.T) {
+	store := dao.NewMemStore()
+	_, codes, err := store.GetLatestDropletCodesWithStatus(dao.DefaultCampaignId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(codes) != 0 {
+		t.Fatalf("expect no droplet codes, got %d", len(codes))
+	}
+
+	for i := 0; i < dao.DefaultDropletShape.Codes(); i++ {
+		code, err := utils.GenerateInviteCode()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := store.CreateInviteCode(&dao.InviteCode{InviteCode: code, CodeType: dao.WaterInviteCode}, dao.SystemEventMeta); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if _, err := store.OpenDropletRound(dao.DefaultCampaignId, 0, dao.DropletShape{}); err != nil {
+		t.Fatal(err)
+	}
+
+	_, codes, err = store.GetLatestDropletCodesWithStatus(dao.DefaultCampaignId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(codes) != dao.DefaultDropletShape.Codes() {
+		t.Fatalf("expect %d droplet codes, got %d", dao.DefaultDropletShape.Codes(), len(codes))
+	}
+}
+
+func TestStoreDropletRounds(t *testing.T) {
+	store := dao.NewMemStore()
+	perRound := dao.DefaultDropletShape.Codes()
+	if _, err := store.OpenDropletRound(dao.DefaultCampaignId, 0, dao.DropletShape{}); !errors.Is(err, dao.ErrNotEnoughDropletCodes) {
+		t.Fatalf("expect ErrNotEnoughDropletCodes, got %v", err)
+	}
+	if _, err := store.GetOpenDropletRound(dao.DefaultCampaignId); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Fatalf("expect no open round, got %v", err)
+	}
+	if _, err := store.CloseDropletRound(dao.DefaultCampaignId); !errors.Is(err, dao.ErrNoOpenDropletRound) {
+		t.Fatalf("expect ErrNoOpenDropletRound, got %v", err)
+	}
+
+	if _, err := store.GenerateInviteCodes(dao.GenerateSpec{CodeType: dao.WaterInviteCode, Count: int64(perRound)}, dao.SystemEventMeta); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.OpenDropletRound(dao.DefaultCampaignId, 0, dao.DropletShape{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.OpenDropletRound(dao.DefaultCampaignId, 0, dao.DropletShape{}); !errors.Is(err, dao.ErrDropletRoundOrder) {
+		t.Fatalf("expect ErrDropletRoundOrder, got %v", err)
+	}
+	if open, err := store.GetOpenDropletRound(dao.DefaultCampaignId); err != nil || open.Round != 0 {
+		t.Fatalf("expect round 0 still open, got %+v %v", open, err)
+	}
+
+	shape := dao.DropletShape{Droplets: 2, CodesPerDroplet: 3}
+	opened, err := store.OpenDropletRound(dao.DefaultCampaignId, 1, shape)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if opened.Round != 1 || !opened.IsOpen() || opened.Shape() != shape {
+		t.Fatalf("unexpected round: %+v", opened)
+	}
+	open, codes, err := store.GetLatestDropletCodesWithStatus(dao.DefaultCampaignId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if open == nil || open.Round != 1 || open.Shape() != shape {
+		t.Fatalf("expect round 1 open, got %+v", open)
+	}
+	if len(codes) != shape.Codes() || codes[0].Round != 1 {
+		t.Fatalf("expect the droplet codes of round 1, got %d", len(codes))
+	}
+	perDroplet := map[uint8]int{}
+	for _, c := range codes {
+		perDroplet[c.DropletIndex]++
+	}
+	if len(perDroplet) != 2 || perDroplet[0] != 3 || perDroplet[1] != 3 {
+		t.Fatalf("unexpected droplets: %v", perDroplet)
+	}
+
+	closed, err := store.CloseDropletRound(dao.DefaultCampaignId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if closed.Round != 1 || closed.IsOpen() {
+		t.Fatalf("unexpected round: %+v", closed)
+	}
+	_, codes, err = store.GetLatestDropletCodesWithStatus(dao.DefaultCampaignId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(codes) != 0 {
+		t.Fatalf("expect no droplet codes while closed, got %d", len(codes))
+	}
+
+	rounds, err := store.GetDropletRounds(dao.DefaultCampaignId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rounds) != 2 || rounds[0].Round != 0 || rounds[0].IsOpen() || rounds[1].Round != 1 || rounds[1].IsOpen() ||
+		rounds[0].Shape() != dao.DefaultDropletShape || rounds[1].Shape() != shape {
+		t.Fatalf("unexpected rounds: %+v", rounds)
+	}
+}
+
+func TestStoreExpiredInviteCode(t *testing.T) {
+	store := dao.NewMemStore()
+	now := uint64(time.Now().Unix())
+	codes := []*dao.InviteCode{
+		{InviteCode: "EXPIRED1", CodeType: dao.TaskInviteCode, ExpiresAt: now - 10},
+		{InviteCode: "FUTURE01", CodeType: dao.TaskInviteCode, ValidFrom: now + 3600},
+		{InviteCode: "VALID001", CodeType: dao.TaskInviteCode, ExpiresAt: now + 3600},
+	}
+	for _, c := range codes {
+		if err := store.CreateInviteCode(c, dao.SystemEventMeta); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for i := 0; i < 10; i++ {
+		inviteCode, err := store.GetAvailableTaskInviteCode(dao.DefaultCampaignId)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if inviteCode.InviteCode != "VALID001" {
+			t.Fatalf("expect VALID001, got %s", inviteCode.InviteCode)
+		}
+	}
+
+	stats, err := store.GetAllInviteCodeStats(dao.DefaultCampaignId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.TotalCodes != 3 || stats.RemainCodes != 2 || stats.ExpiredCodes != 1 {
+		t.Fatalf("unexpected stats: %+v", stats)
+	}
+}
+
+func TestStoreUnbindInviteCode(t *testing.T) {
+	store := dao.NewMemStore()
+	for _, code := range []string{"DIRECT01", "DIRECT02"} {
+		if err := store.CreateInviteCode(&dao.InviteCode{InviteCode: code, CodeType: dao.DirectInviteCode}, dao.SystemEventMeta); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, _, err := store.UnbindInviteCode("DIRECT01", false, "", dao.SystemEventMeta); !errors.Is(err, dao.ErrNotBound) {
+		t.Fatalf("expect ErrNotBound, got %v", err)
+	}
+
+	address, discordId := "0xabc", "123"
+	inviteCode, err := store.GetInviteCode("DIRECT01")
+	if err != nil {
+		t.Fatal(err)
+	}
+	inviteCode.UserAddress = &address
+	inviteCode.DiscordId = &discordId
+	inviteCode.BindTime = 1
+	if err := store.CheckBondAndUpdateInviteCode(inviteCode, dao.EventBoundCli, dao.SystemEventMeta, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	meta := dao.EventMeta{Actor: "admin:ops"}
+	prev, removed, err := store.UnbindInviteCode("DIRECT01", false, "wrong user", meta)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if prev.UserAddress == nil || *prev.UserAddress != address || len(removed) != 1 {
+		t.Fatalf("unexpected previous binding: %+v %+v", prev, removed)
+	}
+	if _, err := store.GetInviteCodeByUserAddress(dao.DefaultCampaignId, address); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Fatalf("expect address unbound, got %v", err)
+	}
+
+	// the address can bind again
+	other, err := store.GetInviteCode("DIRECT02")
+	if err != nil {
+		t.Fatal(err)
+	}
+	other.UserAddress = &address
+	other.BindTime = 2
+	if err := store.CheckBondAndUpdateInviteCode(other, dao.EventBoundCli, dao.SystemEventMeta, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, _, err := store.UnbindInviteCode("DIRECT01", true, "leaked", meta); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := store.UnbindInviteCode("DIRECT01", true, "leaked", meta); !errors.Is(err, dao.ErrAlreadyRevoked) {
+		t.Fatalf("expect ErrAlreadyRevoked, got %v", err)
+	}
+	revoked, err := store.GetInviteCode("DIRECT01")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.CheckBondAndUpdateInviteCode(revoked, dao.EventBoundCli, dao.SystemEventMeta, nil); !errors.Is(err, dao.ErrAlreadyBond) {
+		t.Fatalf("expect revoked code not bindable, got %v", err)
+	}
+
+	bindings, err := store.GetInviteCodeBindings("DIRECT01")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(bindings) != 1 || bindings[0].UserAddress != address || bindings[0].DiscordId != discordId || bindings[0].Reason != "wrong user" {
+		t.Fatalf("unexpected bindings: %+v", bindings)
+	}
+
+	events, err := store.GetInviteCodeEventsAfter(0, []string{dao.EventUnbound, dao.EventRevoked}, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 2 || events[0].DiscordId != discordId || events[1].EventType != dao.EventRevoked {
+		t.Fatalf("unexpected events: %+v", events)
+	}
+
+	cursor, err := store.GetEventCursor("discord-role")
+	if err != nil || cursor != 0 {
+		t.Fatalf("unexpected cursor: %d, %v", cursor, err)
+	}
+	for _, id := range []int64{events[0].ID, events[1].ID} {
+		if err := store.SaveEventCursor("discord-role", id); err != nil {
+			t.Fatal(err)
+		}
+	}
+	cursor, err = store.GetEventCursor("discord-role")
+	if err != nil || cursor != events[1].ID {
+		t.Fatalf("unexpected cursor: %d, %v", cursor, err)
+	}
+
+	stats, err := store.GetAllInviteCodeStats(dao.DefaultCampaignId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.TotalCodes != 2 || stats.RemainCodes != 0 || stats.RevokedCodes != 1 {
+		t.Fatalf("unexpected stats: %+v", stats)
+	}
+}
+
+func TestStoreRebindInviteCode(t *testing.T) {
+	store := dao.NewMemStore()
+	bind := func(code, address string, maxUses uint64) {
+		if err := store.CreateInviteCode(&dao.InviteCode{InviteCode: code, CodeType: dao.DirectInviteCode, MaxUses: maxUses}, dao.SystemEventMeta); err != nil {
+			t.Fatal(err)
+		}
+		if len(address) == 0 {
+			return
+		}
+		inviteCode, err := store.GetInviteCode(code)
+		if err != nil {
+			t.Fatal(err)
+		}
+		inviteCode.UserAddress = &address
+		inviteCode.BindTime = 1
+		if err := store.CheckBondAndUpdateInviteCode(inviteCode, dao.EventBoundCli, dao.SystemEventMeta, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	bind("DIRECT01", "0x01", 0)
+	bind("DIRECT02", "0x02", 0)
+	bind("DIRECT03", "", 0)
+	bind("PARTNER2", "0x03", 2)
+
+	meta := dao.EventMeta{Actor: "admin:ops"}
+	discordId := "123"
+	user := dao.CodeUser{UserAddress: "0x04", DiscordId: &discordId}
+	if _, _, err := store.RebindInviteCode("DIRECT03", user, "", meta, nil); !errors.Is(err, dao.ErrNotBound) {
+		t.Fatalf("expect ErrNotBound, got %v", err)
+	}
+	if _, _, err := store.RebindInviteCode("PARTNER2", user, "", meta, nil); !errors.Is(err, dao.ErrMultiUse) {
+		t.Fatalf("expect ErrMultiUse, got %v", err)
+	}
+	// a user bound elsewhere fails and leaves the code bound
+	taken := dao.CodeUser{UserAddress: "0x02"}
+	if _, _, err := store.RebindInviteCode("DIRECT01", taken, "", meta, nil); !errors.Is(err, gorm.ErrDuplicatedKey) {
+		t.Fatalf("expect ErrDuplicatedKey, got %v", err)
+	}
+	if c, err := store.GetInviteCodeByUserAddress(dao.DefaultCampaignId, "0x01"); err != nil || c.InviteCode != "DIRECT01" {
+		t.Fatalf("expect DIRECT01 still bound, got %+v %v", c, err)
+	}
+
+	prev, removed, err := store.RebindInviteCode("DIRECT01", user, "wrong address", meta, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *prev.UserAddress != "0x01" || len(removed) != 1 || *removed[0].UserAddress != "0x01" {
+		t.Fatalf("unexpected previous binding: %+v %+v", prev, removed)
+	}
+	if _, err := store.GetInviteCodeByUserAddress(dao.DefaultCampaignId, "0x01"); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Fatalf("expect 0x01 unbound, got %v", err)
+	}
+	inviteCode, err := store.GetInviteCodeByDiscordId(dao.DefaultCampaignId, discordId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if inviteCode.InviteCode != "DIRECT01" || *inviteCode.UserAddress != "0x04" || inviteCode.UseCount != 1 || inviteCode.BindTime == 0 {
+		t.Fatalf("unexpected rebound code: %+v", inviteCode)
+	}
+
+	bindings, err := store.GetInviteCodeBindings("DIRECT01")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(bindings) != 1 || bindings[0].UserAddress != "0x01" || bindings[0].Reason != "wrong address" {
+		t.Fatalf("unexpected bindings: %+v", bindings)
+	}
+	events, err := store.GetInviteCodeEventsAfter(0, []string{dao.EventUnbound, dao.EventBoundAdmin}, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 2 || events[0].UserAddress != "0x01" || events[1].UserAddress != "0x04" || events[1].Actor != "admin:ops" {
+		t.Fatalf("unexpected events: %+v", events)
+	}
+}
+
+func TestStoreMultiUseInviteCode(t *testing.T) {
+	store := dao.NewMemStore()
+	if err := store.CreateInviteCode(&dao.InviteCode{InviteCode: "PARTNER2", CodeType: dao.DirectInviteCode, MaxUses: 2}, dao.SystemEventMeta); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.CreateInviteCode(&dao.InviteCode{InviteCode: "SINGLE01", CodeType: dao.DirectInviteCode}, dao.SystemEventMeta); err != nil {
+		t.Fatal(err)
+	}
+
+	redeem := func(code, address string) error {
+		inviteCode, err := store.GetInviteCode(code)
+		if err != nil {
+			t.Fatal(err)
+		}
+		inviteCode.UserAddress = &address
+		inviteCode.BindTime = uint64(time.Now().Unix())
+		return store.CheckBondAndUpdateInviteCode(inviteCode, dao.EventBoundBind, dao.SystemEventMeta, nil)
+	}
+
+	if err := redeem("PARTNER2", "0x01"); err != nil {
+		t.Fatal(err)
+	}
+	if err := redeem("PARTNER2", "0x01"); !errors.Is(err, gorm.ErrDuplicatedKey) {
+		t.Fatalf("expect ErrDuplicatedKey, got %v", err)
+	}
+	// an address redeems one code overall
+	if err := redeem("SINGLE01", "0x01"); !errors.Is(err, gorm.ErrDuplicatedKey) {
+		t.Fatalf("expect ErrDuplicatedKey, got %v", err)
+	}
+
+	partner, err := store.GetInviteCode("PARTNER2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if partner.UseCount != 1 || partner.BindTime != 0 || partner.UserAddress != nil {
+		t.Fatalf("unexpected partner code: %+v", partner)
+	}
+
+	if err := redeem("PARTNER2", "0x02"); err != nil {
+		t.Fatal(err)
+	}
+	if err := redeem("PARTNER2", "0x03"); !errors.Is(err, dao.ErrAlreadyBond) {
+		t.Fatalf("expect ErrAlreadyBond, got %v", err)
+	}
+	if err := redeem("SINGLE01", "0x03"); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, address := range []string{"0x01", "0x02"} {
+		inviteCode, err := store.GetInviteCodeByUserAddress(dao.DefaultCampaignId, address)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if inviteCode.InviteCode != "PARTNER2" || inviteCode.UseCount != 2 || inviteCode.BindTime == 0 {
+			t.Fatalf("unexpected code of %s: %+v", address, inviteCode)
+		}
+	}
+
+	stats, err := store.GetAllInviteCodeStats(dao.DefaultCampaignId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.TotalCodes != 2 || stats.RemainCodes != 0 || stats.Redemptions != 3 || stats.RemainUses != 0 {
+		t.Fatalf("unexpected stats: %+v", stats)
+	}
+
+	_, removed, err := store.UnbindInviteCode("PARTNER2", false, "", dao.SystemEventMeta)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(removed) != 2 {
+		t.Fatalf("expect 2 removed redemptions, got %d", len(removed))
+	}
+	stats, err = store.GetAllInviteCodeStats(dao.DefaultCampaignId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.RemainCodes != 1 || stats.Redemptions != 1 || stats.RemainUses != 2 {
+		t.Fatalf("unexpected stats: %+v", stats)
+	}
+}
+
+func TestStoreReferralCodes(t *testing.T) {
+	store := dao.NewMemStore()
+	inviter := "0xinviter"
+	codes, err := store.IssueReferralCodes(inviter, dao.GenerateSpec{Count: 2, MaxUses: 2}, dao.SystemEventMeta)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(codes) != 2 || codes[0].ReferralSeq != 1 || codes[1].ReferralSeq != 2 {
+		t.Fatalf("unexpected referral codes: %+v", codes)
+	}
+	for _, c := range codes {
+		if c.CodeType != dao.ReferralInviteCode || c.InviterAddress == nil || *c.InviterAddress != inviter || c.MaxUses != 2 {
+			t.Fatalf("unexpected referral code: %+v", c)
+		}
+	}
+
+	// issuing again only tops up to the count
+	again, err := store.IssueReferralCodes(inviter, dao.GenerateSpec{Count: 3, MaxUses: 2}, dao.SystemEventMeta)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(again) != 3 || again[0].InviteCode != codes[0].InviteCode || again[1].InviteCode != codes[1].InviteCode {
+		t.Fatalf("unexpected referral codes: %+v", again)
+	}
+
+	other := "0xother"
+	otherCodes, err := store.IssueReferralCodes(other, dao.GenerateSpec{Count: 1, MaxUses: 1}, dao.SystemEventMeta)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	redeem := func(code, address string) {
+		t.Helper()
+		c, err := store.GetInviteCode(code)
+		if err != nil {
+			t.Fatal(err)
+		}
+		c.UserAddress = &address
+		c.BindTime = 1
+		if err := store.CheckBondAndUpdateInviteCode(c, dao.EventBoundBind, dao.EventMeta{Actor: address}, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	redeem(codes[0].InviteCode, "0x01")
+	redeem(codes[0].InviteCode, "0x02")
+	redeem(codes[1].InviteCode, "0x03")
+	redeem(otherCodes[0].InviteCode, "0x04")
+
+	redeemed, err := store.GetInviteCode(otherCodes[0].InviteCode)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if redeemed.InviterAddress == nil || *redeemed.InviterAddress != other {
+		t.Fatalf("bind should keep the inviter: %+v", redeemed)
+	}
+
+	ranks, err := store.GetReferralLeaderboard(dao.DefaultCampaignId, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ranks) != 2 || ranks[0].InviterAddress != inviter || ranks[0].Invites != 3 ||
+		ranks[1].InviterAddress != other || ranks[1].Invites != 1 {
+		t.Fatalf("unexpected leaderboard: %+v", ranks)
+	}
+	ranks, err = store.GetReferralLeaderboard(dao.DefaultCampaignId, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ranks) != 1 {
+		t.Fatalf("leaderboard should be limited: %+v", ranks)
+	}
+}
+
+func TestStoreBindIssuesReferralCodes(t *testing.T) {
+	store := dao.NewMemStore()
+	for _, code := range []string{"TASK0001", "TASK0002", "TASK0003"} {
+		if err := store.CreateInviteCode(&dao.InviteCode{InviteCode: code, CodeType: dao.TaskInviteCode}, dao.SystemEventMeta); err != nil {
+			t.Fatal(err)
+		}
+	}
+	referral := &dao.GenerateSpec{Count: 2, MaxUses: 1}
+	bind := func(code, address string, referral *dao.GenerateSpec) error {
+		c, err := store.GetInviteCode(code)
+		if err != nil {
+			t.Fatal(err)
+		}
+		c.UserAddress = &address
+		c.BindTime = 1
+		return store.CheckBondAndUpdateInviteCode(c, dao.EventBoundBind, dao.EventMeta{Actor: address}, referral)
+	}
+	referralCodes := func(address string) int {
+		codes, err := store.GetReferralCodes(dao.DefaultCampaignId, address)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return len(codes)
+	}
+
+	if err := bind("TASK0001", "0xbound", referral); err != nil {
+		t.Fatal(err)
+	}
+	if n := referralCodes("0xbound"); n != 2 {
+		t.Fatalf("expect 2 referral codes issued with the bind, got %d", n)
+	}
+	// a failed bind issues nothing
+	if err := bind("TASK0001", "0xlate", referral); !errors.Is(err, dao.ErrAlreadyBond) {
+		t.Fatalf("expect ErrAlreadyBond, got %v", err)
+	}
+	if n := referralCodes("0xlate"); n != 0 {
+		t.Fatalf("expect no referral codes without a bind, got %d", n)
+	}
+
+	// users bound without referral codes are left to the backfill
+	if err := bind("TASK0002", "0xearly", nil); err != nil {
+		t.Fatal(err)
+	}
+	addresses, err := store.GetUnreferredUserAddresses(dao.DefaultCampaignId, referral.Count)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(addresses) != 1 || addresses[0] != "0xearly" {
+		t.Fatalf("unexpected unreferred users: %v", addresses)
+	}
+	addresses, err = store.GetUnreferredUserAddresses(dao.DefaultCampaignId, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(addresses) != 2 || addresses[0] != "0xbound" || addresses[1] != "0xearly" {
+		t.Fatalf("expect users under a raised count unreferred, got %v", addresses)
+	}
+	if _, err := store.IssueReferralCodes("0xearly", *referral, dao.SystemEventMeta); err != nil {
+		t.Fatal(err)
+	}
+	addresses, err = store.GetUnreferredUserAddresses(dao.DefaultCampaignId, referral.Count)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(addresses) != 0 {
+		t.Fatalf("expect every user referred, got %v", addresses)
+	}
+}
+
+func TestStoreCampaigns(t *testing.T) {
+	store := dao.NewMemStore()
+	defaultCampaign, err := store.GetCampaign(dao.DefaultCampaignName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if defaultCampaign.ID != dao.DefaultCampaignId {
+		t.Fatalf("unexpected default campaign: %+v", defaultCampaign)
+	}
+
+	launch := &dao.Campaign{Name: "launch2", TaskInviteCodeCount: 1}
+	if err := store.SaveCampaign(launch); err != nil {
+		t.Fatal(err)
+	}
+	launch.TaskInviteCodeCount = 2
+	if err := store.SaveCampaign(launch); err != nil {
+		t.Fatal(err)
+	}
+	campaigns, err := store.GetCampaigns()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(campaigns) != 2 || campaigns[1].Name != "launch2" || campaigns[1].TaskInviteCodeCount != 2 {
+		t.Fatalf("unexpected campaigns: %+v", campaigns)
+	}
+
+	codes := map[int64]string{dao.DefaultCampaignId: "DEFCODE1", launch.ID: "NEWCODE1"}
+	for campaignId, code := range codes {
+		err := store.CreateInviteCode(&dao.InviteCode{InviteCode: code, CampaignId: campaignId, CodeType: dao.TaskInviteCode}, dao.SystemEventMeta)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// the same user binds once in every campaign
+	address := "0xabc"
+	for campaignId, code := range codes {
+		inviteCode, err := store.GetAvailableTaskInviteCode(campaignId)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if inviteCode.InviteCode != code {
+			t.Fatalf("campaign %d picked code %s", campaignId, inviteCode.InviteCode)
+		}
+		inviteCode.UserAddress = &address
+		inviteCode.BindTime = 1
+		if err := store.CheckBondAndUpdateInviteCode(inviteCode, dao.EventBoundGen, dao.EventMeta{Actor: address}, nil); err != nil {
+			t.Fatal(err)
+		}
+
+		bound, err := store.GetInviteCodeByUserAddress(campaignId, address)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if bound.InviteCode != code {
+			t.Fatalf("campaign %d bound code %s", campaignId, bound.InviteCode)
+		}
+	}
+
+	stats, err := store.GetAllInviteCodeStats(launch.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.TotalCodes != 1 || stats.Redemptions != 1 {
+		t.Fatalf("unexpected stats: %+v", stats)
+	}
+	if _, err := store.GetAvailableTaskInviteCode(launch.ID); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Fatalf("expect ErrRecordNotFound, got %v", err)
+	}
+}
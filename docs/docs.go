@@ -15,58 +15,217 @@ const docTemplate = `{
     "host": "{{.Host}}",
     "basePath": "{{.BasePath}}",
     "paths": {
-        "/v1/invite/bind": {
-            "post": {
-                "description": "The exact message format to sign is here:\nhttps://github.com/stafiprotocol/invite-code-service/blob/main/pkg/utils/signature.go",
-                "consumes": [
+        "/admin/v1/batch/stats": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "The summary of the codes of a batch and how they got bound over time",
+                "produces": [
                     "application/json"
                 ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "get batch stats",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "batch name",
+                        "name": "name",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "timeline interval in seconds, default 86400, min 60",
+                        "name": "interval",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/utils.Rsp"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "$ref": "#/definitions/api.RspAdminBatchStats"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/v1/batches": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Every batch with the summary of its codes",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "v1"
+                    "admin"
                 ],
-                "summary": "bind user address and invite code",
+                "summary": "list batches",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/utils.Rsp"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "$ref": "#/definitions/api.RspAdminBatches"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/v1/droplet/round/close": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Closes the open droplet round of a campaign, no droplets are served until the next one opens",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "close the open droplet round",
                 "parameters": [
                     {
-                        "description": "bind",
-                        "name": "param",
-                        "in": "body",
-                        "required": true,
+                        "type": "string",
+                        "description": "campaign name, the default campaign if empty",
+                        "name": "campaign",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/api.ReqBind"
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/utils.Rsp"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "$ref": "#/definitions/api.DropletRound"
+                                        }
+                                    }
+                                }
+                            ]
                         }
                     }
+                }
+            }
+        },
+        "/admin/v1/droplet/round/open": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Closes the open droplet round of a campaign and opens the next one\nwith droplets of available water codes. The shape defaults to the configured DropletShape.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "open the next droplet round",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "campaign name, the default campaign if empty",
+                        "name": "campaign",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "droplets of the round",
+                        "name": "droplets",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "codes of each droplet",
+                        "name": "codes_per_droplet",
+                        "in": "query"
+                    }
                 ],
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/utils.Rsp"
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/utils.Rsp"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "$ref": "#/definitions/api.DropletRound"
+                                        }
+                                    }
+                                }
+                            ]
                         }
                     }
                 }
             }
         },
-        "/v1/invite/droplets": {
+        "/admin/v1/droplet/rounds": {
             "get": {
-                "description": "get droplets",
-                "consumes": [
-                    "application/json"
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
                 ],
+                "description": "Every droplet round of a campaign, at most one is open",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "v1"
+                    "admin"
                 ],
-                "summary": "get droplets",
+                "summary": "list droplet rounds",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "droplet",
-                        "name": "droplet",
+                        "description": "campaign name, the default campaign if empty",
+                        "name": "campaign",
                         "in": "query"
                     }
                 ],
@@ -82,7 +241,7 @@ const docTemplate = `{
                                     "type": "object",
                                     "properties": {
                                         "data": {
-                                            "$ref": "#/definitions/api.RspDroplets"
+                                            "$ref": "#/definitions/api.RspAdminDropletRounds"
                                         }
                                     }
                                 }
@@ -92,9 +251,14 @@ const docTemplate = `{
                 }
             }
         },
-        "/v1/invite/genInviteCode": {
+        "/admin/v1/droplet/tier/token": {
             "post": {
-                "description": "The exact message format to sign is here:\nhttps://github.com/stafiprotocol/invite-code-service/blob/main/pkg/utils/signature.go",
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "A token selecting a tier of the droplets shown, passed as tier_token to GetDroplets.\nThe tier must be configured in DropletReveal and signing needs its TierSecret.",
                 "consumes": [
                     "application/json"
                 ],
@@ -102,17 +266,17 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "v1"
+                    "admin"
                 ],
-                "summary": "gen invite code",
+                "summary": "sign a droplet tier token",
                 "parameters": [
                     {
-                        "description": "gen",
+                        "description": "tier",
                         "name": "param",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/api.ReqGen"
+                            "$ref": "#/definitions/api.ReqAdminTierToken"
                         }
                     }
                 ],
@@ -128,7 +292,7 @@ const docTemplate = `{
                                     "type": "object",
                                     "properties": {
                                         "data": {
-                                            "$ref": "#/definitions/api.RspGen"
+                                            "$ref": "#/definitions/api.RspAdminTierToken"
                                         }
                                     }
                                 }
@@ -138,9 +302,14 @@ const docTemplate = `{
                 }
             }
         },
-        "/v1/invite/summary": {
-            "get": {
-                "description": "get codes info and zealy task",
+        "/admin/v1/invite/bind": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Binds a direct invite code to a user with the checks of the bind command,\nwithout a signature. The discord fields are optional.",
                 "consumes": [
                     "application/json"
                 ],
@@ -148,9 +317,60 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "v1"
+                    "admin"
+                ],
+                "summary": "bind invite code to user",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "campaign name, the default campaign if empty",
+                        "name": "campaign",
+                        "in": "query"
+                    },
+                    {
+                        "description": "bind",
+                        "name": "param",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/api.ReqAdminBind"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/utils.Rsp"
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/v1/invite/code": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "An invite code with its redemptions and the droplets it is assigned to",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "get invite code detail",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "invite code",
+                        "name": "code",
+                        "in": "query",
+                        "required": true
+                    }
                 ],
-                "summary": "get codes info and zealy task",
                 "responses": {
                     "200": {
                         "description": "OK",
@@ -163,7 +383,7 @@ const docTemplate = `{
                                     "type": "object",
                                     "properties": {
                                         "data": {
-                                            "$ref": "#/definitions/api.RspSummary"
+                                            "$ref": "#/definitions/api.RspAdminCode"
                                         }
                                     }
                                 }
@@ -173,26 +393,105 @@ const docTemplate = `{
                 }
             }
         },
-        "/v1/invite/userStatus": {
+        "/admin/v1/invite/codes": {
             "get": {
-                "description": "get user status",
-                "consumes": [
-                    "application/json"
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
                 ],
+                "description": "Pages through the invite codes matching every given filter.\naddress, discord_id and discord_name match any user who redeemed the code.",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "v1"
+                    "admin"
                 ],
-                "summary": "get user status",
+                "summary": "search invite codes",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "address",
+                        "description": "campaign name, every campaign if empty",
+                        "name": "campaign",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "code prefix",
+                        "name": "code",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "user address",
                         "name": "address",
-                        "in": "query",
-                        "required": true
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "discord id",
+                        "name": "discord_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "part of the discord name",
+                        "name": "discord_name",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "code type: task, direct, water or referral",
+                        "name": "type",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "redeemed at least once, or never",
+                        "name": "bound",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "batch name",
+                        "name": "batch",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "bind time from, unix seconds",
+                        "name": "bind_from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "bind time before, unix seconds",
+                        "name": "bind_to",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "id (default), create_time, bind_time, use_count or expires_at",
+                        "name": "sort",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "asc (default) or desc",
+                        "name": "order",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "page from 1, default 1",
+                        "name": "page",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "page size, default 10, max 50",
+                        "name": "page_size",
+                        "in": "query"
                     }
                 ],
                 "responses": {
@@ -207,7 +506,7 @@ const docTemplate = `{
                                     "type": "object",
                                     "properties": {
                                         "data": {
-                                            "$ref": "#/definitions/api.RspUserStatus"
+                                            "$ref": "#/definitions/api.RspAdminCodes"
                                         }
                                     }
                                 }
@@ -216,14 +515,547 @@ const docTemplate = `{
                     }
                 }
             }
-        }
-    },
-    "definitions": {
-        "api.Droplet": {
-            "type": "object",
-            "properties": {
-                "available_count": {
-                    "type": "integer"
+        },
+        "/admin/v1/invite/rebind": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Moves a bound single-use invite code to another user in one step.\nThe previous binding is kept in history like an unbind. The discord fields are optional.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "rebind invite code to another user",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "campaign name, the default campaign if empty",
+                        "name": "campaign",
+                        "in": "query"
+                    },
+                    {
+                        "description": "rebind",
+                        "name": "param",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/api.ReqAdminRebind"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/utils.Rsp"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "$ref": "#/definitions/api.RspAdminRebind"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/v1/invite/unbind": {
+            "post": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "Clears every redemption of an invite code so it can be bound again,\nor with revoke marks it permanently unusable. The removed redemptions are kept in history.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "unbind or revoke invite code",
+                "parameters": [
+                    {
+                        "description": "unbind",
+                        "name": "param",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/api.ReqAdminUnbind"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/utils.Rsp"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "$ref": "#/definitions/api.RspAdminUnbind"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/v1/metrics": {
+            "get": {
+                "security": [
+                    {
+                        "ApiKeyAuth": []
+                    }
+                ],
+                "description": "The expvar variables of the server, invite_code_stock holds the remaining codes\nper campaign/type watched by the stock monitor, invite_code_low_stock is 1 while\na pool is below its threshold and invite_code_stock_top_ups counts the codes it generated.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "admin"
+                ],
+                "summary": "runtime metrics",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    }
+                }
+            }
+        },
+        "/v1/invite/bind": {
+            "post": {
+                "description": "The exact message format to sign is here:\nhttps://github.com/stafiprotocol/invite-code-service/blob/main/pkg/utils/signature.go",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "v1"
+                ],
+                "summary": "bind user address and invite code",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "campaign name, the default campaign if empty",
+                        "name": "campaign",
+                        "in": "query"
+                    },
+                    {
+                        "description": "bind",
+                        "name": "param",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/api.ReqBind"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/utils.Rsp"
+                        }
+                    }
+                }
+            }
+        },
+        "/v1/invite/droplets": {
+            "get": {
+                "description": "get droplets of the open round. When none has a code left, next_round_start_time\nis when the next scheduled round opens, for a countdown. The droplets shown are\ndrawn once per user and time slot, next_draw_time is when the slot ends. The user is the\nsession of the droplet_session cookie or session param, issued from the client ip without one.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "v1"
+                ],
+                "summary": "get droplets",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "campaign name, the default campaign if empty",
+                        "name": "campaign",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "session of an earlier response, used without the droplet_session cookie",
+                        "name": "session",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "signed tier token selecting the droplets shown, the default tier if empty",
+                        "name": "tier_token",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/utils.Rsp"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "$ref": "#/definitions/api.RspDroplets"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    }
+                }
+            }
+        },
+        "/v1/invite/genInviteCode": {
+            "post": {
+                "description": "The exact message format to sign is here:\nhttps://github.com/stafiprotocol/invite-code-service/blob/main/pkg/utils/signature.go",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "v1"
+                ],
+                "summary": "gen invite code",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "campaign name, the default campaign if empty",
+                        "name": "campaign",
+                        "in": "query"
+                    },
+                    {
+                        "description": "gen",
+                        "name": "param",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/api.ReqGen"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/utils.Rsp"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "$ref": "#/definitions/api.RspGen"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    }
+                }
+            }
+        },
+        "/v1/invite/referralLeaderboard": {
+            "get": {
+                "description": "inviters ranked by how many users redeemed their referral codes",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "v1"
+                ],
+                "summary": "get referral leaderboard",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "campaign name, the default campaign if empty",
+                        "name": "campaign",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "limit, default 10, max 50",
+                        "name": "limit",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/utils.Rsp"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "$ref": "#/definitions/api.RspReferralLeaderboard"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    }
+                }
+            }
+        },
+        "/v1/invite/summary": {
+            "get": {
+                "description": "get codes info and zealy task",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "v1"
+                ],
+                "summary": "get codes info and zealy task",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "campaign name, the default campaign if empty",
+                        "name": "campaign",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/utils.Rsp"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "$ref": "#/definitions/api.RspSummary"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    }
+                }
+            }
+        },
+        "/v1/invite/userStatus": {
+            "get": {
+                "description": "get user status, a bound user also gets their referral codes and who used them",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "v1"
+                ],
+                "summary": "get user status",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "campaign name, the default campaign if empty",
+                        "name": "campaign",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "address",
+                        "name": "address",
+                        "in": "query",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "allOf": [
+                                {
+                                    "$ref": "#/definitions/utils.Rsp"
+                                },
+                                {
+                                    "type": "object",
+                                    "properties": {
+                                        "data": {
+                                            "$ref": "#/definitions/api.RspUserStatus"
+                                        }
+                                    }
+                                }
+                            ]
+                        }
+                    }
+                }
+            }
+        }
+    },
+    "definitions": {
+        "api.AdminCode": {
+            "type": "object",
+            "properties": {
+                "batch": {
+                    "type": "string"
+                },
+                "bind_time": {
+                    "type": "integer"
+                },
+                "campaign": {
+                    "type": "string"
+                },
+                "code_type": {
+                    "type": "string"
+                },
+                "create_time": {
+                    "type": "integer"
+                },
+                "discord_id": {
+                    "type": "string"
+                },
+                "discord_name": {
+                    "type": "string"
+                },
+                "expires_at": {
+                    "type": "integer"
+                },
+                "invite_code": {
+                    "type": "string"
+                },
+                "inviter_address": {
+                    "type": "string"
+                },
+                "max_uses": {
+                    "type": "integer"
+                },
+                "revoke_time": {
+                    "type": "integer"
+                },
+                "use_count": {
+                    "type": "integer"
+                },
+                "user_address": {
+                    "type": "string"
+                },
+                "user_id": {
+                    "type": "string"
+                },
+                "valid_from": {
+                    "type": "integer"
+                }
+            }
+        },
+        "api.BatchSummary": {
+            "type": "object",
+            "properties": {
+                "bind_rate": {
+                    "description": "bound_codes / total_codes",
+                    "type": "number"
+                },
+                "bound_codes": {
+                    "type": "integer"
+                },
+                "created_at": {
+                    "type": "integer"
+                },
+                "expired_codes": {
+                    "type": "integer"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "notes": {
+                    "type": "string"
+                },
+                "owner": {
+                    "type": "string"
+                },
+                "redemptions": {
+                    "type": "integer"
+                },
+                "remaining_codes": {
+                    "type": "integer"
+                },
+                "revoked_codes": {
+                    "type": "integer"
+                },
+                "total_codes": {
+                    "type": "integer"
+                }
+            }
+        },
+        "api.BindPoint": {
+            "type": "object",
+            "properties": {
+                "bind_rate": {
+                    "description": "total_bound / total_codes",
+                    "type": "number"
+                },
+                "bound_codes": {
+                    "type": "integer"
+                },
+                "start": {
+                    "type": "integer"
+                },
+                "total_bound": {
+                    "type": "integer"
+                }
+            }
+        },
+        "api.Droplet": {
+            "type": "object",
+            "properties": {
+                "available_count": {
+                    "type": "integer"
                 },
                 "invite_code": {
                     "type": "string"
@@ -236,6 +1068,151 @@ const docTemplate = `{
                 }
             }
         },
+        "api.DropletAssignment": {
+            "type": "object",
+            "properties": {
+                "droplet_index": {
+                    "type": "integer"
+                },
+                "round": {
+                    "type": "integer"
+                }
+            }
+        },
+        "api.DropletRound": {
+            "type": "object",
+            "properties": {
+                "close_time": {
+                    "type": "integer"
+                },
+                "codes_per_droplet": {
+                    "type": "integer"
+                },
+                "droplets": {
+                    "type": "integer"
+                },
+                "open": {
+                    "type": "boolean"
+                },
+                "open_time": {
+                    "type": "integer"
+                },
+                "round": {
+                    "type": "integer"
+                }
+            }
+        },
+        "api.Redemption": {
+            "type": "object",
+            "properties": {
+                "discord_id": {
+                    "type": "string"
+                },
+                "discord_name": {
+                    "type": "string"
+                },
+                "redeem_time": {
+                    "type": "integer"
+                },
+                "user_address": {
+                    "type": "string"
+                }
+            }
+        },
+        "api.ReferralCode": {
+            "type": "object",
+            "properties": {
+                "invite_code": {
+                    "type": "string"
+                },
+                "max_uses": {
+                    "type": "integer"
+                },
+                "use_count": {
+                    "type": "integer"
+                },
+                "used_by": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                }
+            }
+        },
+        "api.ReferralRank": {
+            "type": "object",
+            "properties": {
+                "inviter_address": {
+                    "type": "string"
+                },
+                "invites": {
+                    "type": "integer"
+                }
+            }
+        },
+        "api.ReqAdminBind": {
+            "type": "object",
+            "properties": {
+                "discord_id": {
+                    "type": "string"
+                },
+                "discord_name": {
+                    "type": "string"
+                },
+                "invite_code": {
+                    "type": "string"
+                },
+                "user_address": {
+                    "type": "string"
+                }
+            }
+        },
+        "api.ReqAdminRebind": {
+            "type": "object",
+            "properties": {
+                "discord_id": {
+                    "type": "string"
+                },
+                "discord_name": {
+                    "type": "string"
+                },
+                "invite_code": {
+                    "type": "string"
+                },
+                "reason": {
+                    "type": "string"
+                },
+                "user_address": {
+                    "type": "string"
+                }
+            }
+        },
+        "api.ReqAdminTierToken": {
+            "type": "object",
+            "properties": {
+                "expires_at": {
+                    "description": "unix seconds, 0 never expires",
+                    "type": "integer"
+                },
+                "tier": {
+                    "type": "string"
+                }
+            }
+        },
+        "api.ReqAdminUnbind": {
+            "type": "object",
+            "properties": {
+                "invite_code": {
+                    "type": "string"
+                },
+                "reason": {
+                    "type": "string"
+                },
+                "revoke": {
+                    "type": "boolean"
+                }
+            }
+        },
         "api.ReqBind": {
             "type": "object",
             "properties": {
@@ -273,6 +1250,127 @@ const docTemplate = `{
                 }
             }
         },
+        "api.RspAdminBatchStats": {
+            "type": "object",
+            "properties": {
+                "batch": {
+                    "$ref": "#/definitions/api.BatchSummary"
+                },
+                "interval": {
+                    "type": "integer"
+                },
+                "timeline": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/api.BindPoint"
+                    }
+                }
+            }
+        },
+        "api.RspAdminBatches": {
+            "type": "object",
+            "properties": {
+                "list": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/api.BatchSummary"
+                    }
+                }
+            }
+        },
+        "api.RspAdminCode": {
+            "type": "object",
+            "properties": {
+                "code": {
+                    "$ref": "#/definitions/api.AdminCode"
+                },
+                "droplets": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/api.DropletAssignment"
+                    }
+                },
+                "redemptions": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/api.Redemption"
+                    }
+                }
+            }
+        },
+        "api.RspAdminCodes": {
+            "type": "object",
+            "properties": {
+                "list": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/api.AdminCode"
+                    }
+                },
+                "page": {
+                    "type": "integer"
+                },
+                "page_size": {
+                    "type": "integer"
+                },
+                "total": {
+                    "type": "integer"
+                }
+            }
+        },
+        "api.RspAdminDropletRounds": {
+            "type": "object",
+            "properties": {
+                "list": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/api.DropletRound"
+                    }
+                }
+            }
+        },
+        "api.RspAdminRebind": {
+            "type": "object",
+            "properties": {
+                "invite_code": {
+                    "type": "string"
+                },
+                "removed": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/api.Redemption"
+                    }
+                },
+                "user_address": {
+                    "type": "string"
+                }
+            }
+        },
+        "api.RspAdminTierToken": {
+            "type": "object",
+            "properties": {
+                "token": {
+                    "type": "string"
+                }
+            }
+        },
+        "api.RspAdminUnbind": {
+            "type": "object",
+            "properties": {
+                "invite_code": {
+                    "type": "string"
+                },
+                "removed": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/api.Redemption"
+                    }
+                },
+                "revoked": {
+                    "type": "boolean"
+                }
+            }
+        },
         "api.RspDroplets": {
             "type": "object",
             "properties": {
@@ -281,6 +1379,18 @@ const docTemplate = `{
                     "items": {
                         "$ref": "#/definitions/api.Droplet"
                     }
+                },
+                "next_draw_time": {
+                    "description": "NextDrawTime is when the droplets shown are drawn again, 0 if no round is open",
+                    "type": "integer"
+                },
+                "next_round_start_time": {
+                    "description": "NextRoundStartTime is the start time of the next scheduled round when no\ndroplet has a code left, 0 if none is scheduled",
+                    "type": "integer"
+                },
+                "session": {
+                    "description": "Session is the signed session the draw is for, to pass back as the session\nparam where the droplet_session cookie isn't kept, empty if no round is open",
+                    "type": "string"
                 }
             }
         },
@@ -292,15 +1402,38 @@ const docTemplate = `{
                 }
             }
         },
+        "api.RspReferralLeaderboard": {
+            "type": "object",
+            "properties": {
+                "list": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/api.ReferralRank"
+                    }
+                }
+            }
+        },
         "api.RspSummary": {
             "type": "object",
             "properties": {
+                "expired_codes": {
+                    "type": "integer"
+                },
+                "expired_task_codes": {
+                    "type": "integer"
+                },
+                "redemptions": {
+                    "type": "integer"
+                },
                 "remaining_codes": {
                     "type": "integer"
                 },
                 "remaining_task_codes": {
                     "type": "integer"
                 },
+                "remaining_uses": {
+                    "type": "integer"
+                },
                 "tasks": {
                     "type": "array",
                     "items": {
@@ -321,11 +1454,23 @@ const docTemplate = `{
                 "invite_code": {
                     "type": "string"
                 },
+                "max_uses": {
+                    "type": "integer"
+                },
+                "referral_codes": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/api.ReferralCode"
+                    }
+                },
                 "tasks": {
                     "type": "array",
                     "items": {
                         "$ref": "#/definitions/api.Task"
                     }
+                },
+                "use_count": {
+                    "type": "integer"
                 }
             }
         },
@@ -358,6 +1503,13 @@ const docTemplate = `{
                 }
             }
         }
+    },
+    "securityDefinitions": {
+        "ApiKeyAuth": {
+            "type": "apiKey",
+            "name": "X-Api-Key",
+            "in": "header"
+        }
     }
 }`
 
@@ -368,7 +1520,7 @@ var SwaggerInfo = &swag.Spec{
 	BasePath:         "/api",
 	Schemes:          []string{},
 	Title:            "invite code API",
-	Description:      "invite code api document.\nError Codes:\n80001 Invalid parameters\n80002 Internal server error\n80003 User already bound\n80004 Invite code already bound\n80005 Signature verification failed\n80006 Task verification failed\n80007 Invite code does not exist\n80008 Invite code type mismatch\n80009 Invite codes not enough\n80010 Discord already bound",
+	Description:      "invite code api document.\nError Codes:\n80001 Invalid parameters\n80002 Internal server error\n80003 User already bound\n80004 Invite code already bound\n80005 Signature verification failed\n80006 Task verification failed\n80007 Invite code does not exist\n80008 Invite code type mismatch\n80009 Invite codes not enough\n80010 Discord already bound\n80011 Invite code expired\n80012 Invite code not yet valid\n80013 Invite code revoked\n80014 Invite code not bound\n80015 Campaign does not exist\n80016 Campaign not open\n80017 Invite code malformed, e.g. a typo caught by the check character\n80018 Batch does not exist\n80019 Invite code is multi-use\n80020 No open droplet round\n80021 Invalid or expired tier token",
 	InfoInstanceName: "swagger",
 	SwaggerTemplate:  docTemplate,
 	LeftDelim:        "{{",
@@ -18,7 +18,21 @@ import (
 // @description  80008 Invite code type mismatch
 // @description  80009 Invite codes not enough
 // @description  80010 Discord already bound
+// @description  80011 Invite code expired
+// @description  80012 Invite code not yet valid
+// @description  80013 Invite code revoked
+// @description  80014 Invite code not bound
+// @description  80015 Campaign does not exist
+// @description  80016 Campaign not open
+// @description  80017 Invite code malformed, e.g. a typo caught by the check character
+// @description  80018 Batch does not exist
+// @description  80019 Invite code is multi-use
+// @description  80020 No open droplet round
+// @description  80021 Invalid or expired tier token
 // @BasePath /api
+// @securityDefinitions.apikey ApiKeyAuth
+// @in header
+// @name X-Api-Key
 func main() {
 	cmd.Execute()
 }
@@ -2,6 +2,8 @@ package config
 
 import (
 	"fmt"
+	"invite-code-service/pkg/utils"
+	"time"
 
 	"github.com/BurntSushi/toml"
 )
@@ -10,17 +12,230 @@ type ConfigApi struct {
 	LogFileDir string
 	ListenAddr string
 
+	// AutoGenerateCodes tops the code pools up to TaskInviteCodeCount and
+	// DirectInviteCodeCount, and the water pool to the droplet round, on startup.
+	// Without it the pools are only filled by the gen-codes command.
+	AutoGenerateCodes     bool
 	TaskInviteCodeCount   uint64
 	DirectInviteCodeCount uint64
+	// how many users can redeem each generated direct code, default 1. gen-codes
+	// --max-uses and the max_uses column of import-codes override it per code
+	DirectInviteCodeMaxUses uint64
+	// StockMonitor warns about code pools running low while the server runs
+	StockMonitor StockMonitor
 
+	// referral codes issued to every user with their bind, 0 disables referrals.
+	// issue-referrals gives them to the users bound before they were configured
+	ReferralCodeCount   uint8
+	ReferralCodeMaxUses uint64
+
+	// validity of generated codes, per code type
+	TaskCodeValidity   Validity
+	DirectCodeValidity Validity
+	WaterCodeValidity  Validity
+
+	// format of generated codes, also checked by bind before any lookup
+	CodeFormat CodeFormat
+
+	// DropletRound opens on start if the campaign has no round yet or it is the one after
+	// the last, later rounds are opened at runtime
 	DropletRound uint8
+	// DropletShape of the rounds opened by DropletRound, the admin api and the cli,
+	// 5 droplets of 5 codes for zero fields
+	DropletShape DropletShape
+	// DropletSchedule opens the droplet rounds of the default campaign on time
+	DropletSchedule DropletSchedule
+	// how often the droplet schedules are checked, default 10s
+	DropletScheduleInterval time.Duration
+	// DropletReveal is how many droplets of the open round GetDroplets shows and
+	// how often a user gets a new draw
+	DropletReveal DropletReveal
 
 	ZealyApiKey    string
 	ZealySubdomain string
 
+	// keys accepted by the /api/admin routes in the X-Api-Key header
+	AdminApiKeys []AdminApiKey
+
+	// campaigns besides the default one, which the top level counts, ZealySubdomain,
+	// DropletRound, DropletShape and DropletSchedule configure
+	Campaigns []Campaign
+
 	Db Db
 }
 
+// Campaign is saved into the campaigns table on startup
+type Campaign struct {
+	Name           string
+	ZealySubdomain string // the top level one if empty
+
+	// codes can be bound inside the window in unix seconds, 0 means unbounded
+	StartTime uint64
+	EndTime   uint64
+
+	TaskInviteCodeCount   uint64
+	DirectInviteCodeCount uint64
+	DropletRound          uint8
+	DropletShape          DropletShape // the top level one for zero fields
+	DropletSchedule       DropletSchedule
+}
+
+// CampaignDropletShape returns the droplet shape of the campaign called name
+func (c *ConfigApi) CampaignDropletShape(name string) DropletShape {
+	for _, campaign := range c.Campaigns {
+		if campaign.Name == name {
+			return campaign.DropletShape.Or(c.DropletShape)
+		}
+	}
+	return c.DropletShape
+}
+
+// CampaignDropletSchedule returns the droplet schedule of the campaign called name
+func (c *ConfigApi) CampaignDropletSchedule(name string) DropletSchedule {
+	for _, campaign := range c.Campaigns {
+		if campaign.Name == name {
+			return campaign.DropletSchedule
+		}
+	}
+	return c.DropletSchedule
+}
+
+// DropletSchedule opens each of Rounds at its StartTime and closes it at its
+// EndTime. DropletRound isn't opened on start if a campaign has a schedule.
+type DropletSchedule struct {
+	// AutoAdvance opens the next round before its StartTime once no code of the
+	// open round is left to bind
+	AutoAdvance bool
+	// Rounds in round and time order
+	Rounds []ScheduledRound
+}
+
+// DropletShape is how many droplets a round has and how many codes each holds
+type DropletShape struct {
+	Droplets        uint8
+	CodesPerDroplet uint8
+}
+
+// Or fills the zero fields of s from def
+func (s DropletShape) Or(def DropletShape) DropletShape {
+	if s.Droplets == 0 {
+		s.Droplets = def.Droplets
+	}
+	if s.CodesPerDroplet == 0 {
+		s.CodesPerDroplet = def.CodesPerDroplet
+	}
+	return s
+}
+
+// ScheduledRound is a droplet round opened by the schedule, the campaign
+// DropletShape fills the zero fields of its shape
+type ScheduledRound struct {
+	Round     uint8
+	StartTime uint64 // unix seconds
+	EndTime   uint64 // unix seconds, 0 keeps the round open until the next one
+	DropletShape
+}
+
+// DropletReveal draws how many droplets are shown from the counts of a tier.
+// Requests use the "default" tier unless they carry a tier token signed with
+// TierSecret, see utils.SignTierToken. A user sees the same draw of a round
+// for DrawSlot.
+type DropletReveal struct {
+	TierSecret string // tier tokens are refused if empty
+	// DrawSecret seeds the draws and signs the draw sessions, required. Keep it
+	// the same on every instance and across restarts.
+	DrawSecret string
+	// how long a user sees the same draw, default 1h
+	DrawSlot time.Duration
+	// Tiers replace the built in default and sp tiers when set, a name can
+	// repeat for different rounds
+	Tiers []RevealTier
+}
+
+// RevealTier is a named distribution of the droplets shown
+type RevealTier struct {
+	Name   string
+	Rounds []uint8 // rounds the tier applies to, every round without one of its own if empty
+	Counts []RevealCount
+}
+
+// RevealCount shows between Min and Max droplets, uniformly, with a chance of
+// Weight out of the weights of its tier
+type RevealCount struct {
+	Min    uint8
+	Max    uint8
+	Weight uint
+}
+
+// Tier returns the tier called name for round, nil if there is none
+func (r DropletReveal) Tier(name string, round uint8) *RevealTier {
+	var every *RevealTier
+	for i := range r.Tiers {
+		t := &r.Tiers[i]
+		if t.Name != name {
+			continue
+		}
+		if len(t.Rounds) == 0 {
+			if every == nil {
+				every = t
+			}
+			continue
+		}
+		for _, r := range t.Rounds {
+			if r == round {
+				return t
+			}
+		}
+	}
+	return every
+}
+
+// Find returns the scheduled round, nil if the round isn't scheduled
+func (s DropletSchedule) Find(round uint8) *ScheduledRound {
+	for i := range s.Rounds {
+		if s.Rounds[i].Round == round {
+			return &s.Rounds[i]
+		}
+	}
+	return nil
+}
+
+// Next returns the first scheduled round after round that hasn't ended at now,
+// nil if there is none. round is -1 before the first round.
+func (s DropletSchedule) Next(round int, now uint64) *ScheduledRound {
+	for i := range s.Rounds {
+		r := &s.Rounds[i]
+		if int(r.Round) > round && (r.EndTime == 0 || r.EndTime > now) {
+			return r
+		}
+	}
+	return nil
+}
+
+// StockMonitor checks the remaining codes of every campaign per code type, it
+// is disabled when Interval is 0
+type StockMonitor struct {
+	Interval time.Duration
+	// warnings are also posted to this discord webhook if set
+	DiscordWebhookUrl string `json:"-"`
+
+	Task   StockLimits
+	Direct StockLimits
+	Water  StockLimits
+}
+
+// StockLimits of a code pool, a pool with a zero Threshold isn't watched
+type StockLimits struct {
+	Threshold uint64 // warn when fewer codes remain
+	Ceiling   uint64 // below Threshold generate codes until this many remain, 0 only warns
+}
+
+// AdminApiKey names the holder of a key, the name is recorded as the actor of admin changes
+type AdminApiKey struct {
+	Name string
+	Key  string `json:"-"`
+}
+
 type ConfigDiscordBot struct {
 	LogFileDir string
 
@@ -29,15 +244,76 @@ type ConfigDiscordBot struct {
 	DiscordGuidId    string
 	DiscordRoleId    string
 
+	// how often to remove the role of unbound or revoked discord users, default 1m
+	RoleSyncInterval time.Duration
+
+	// the role is given to users bound in this campaign, the default campaign if empty
+	Campaign string
+
 	Db Db
 }
 
 type ConfigBindCode struct {
 	FilePath string
-	Db       Db
+	// ApiConfigPath is the config of the api server, the bound users are issued
+	// its referral codes. Without it they are left to the issue-referrals command.
+	ApiConfigPath string
+	Db            Db
+}
+
+// ConfigCli is used by the commands that only need a database
+type ConfigCli struct {
+	Db Db
+}
+
+// Validity is the validity window given to a batch of generated codes
+type Validity struct {
+	ValidFrom uint64        // unix seconds, 0 means valid immediately
+	ExpiresAt uint64        // unix seconds, 0 means never expires
+	ValidFor  time.Duration // expire this long after generation, used when ExpiresAt is 0
+}
+
+// Window returns the valid_from and expires_at of codes generated at now
+func (v Validity) Window(now time.Time) (validFrom, expiresAt uint64) {
+	expiresAt = v.ExpiresAt
+	if expiresAt == 0 && v.ValidFor > 0 {
+		expiresAt = uint64(now.Add(v.ValidFor).Unix())
+	}
+	return v.ValidFrom, expiresAt
+}
+
+// CodeFormat configures generated codes. Codes not matching the format of any
+// code type are rejected by bind, so changing it on an existing pool locks
+// out the codes already given out.
+type CodeFormat struct {
+	Length    int    // random characters, default 8
+	Alphabet  string // "default", "unambiguous" or the characters to draw from
+	CheckChar bool   // append a Luhn mod N check character
+
+	TaskPrefix     string
+	DirectPrefix   string
+	WaterPrefix    string
+	ReferralPrefix string
+}
+
+// Format returns the format of codes with prefix
+func (f CodeFormat) Format(prefix string) utils.CodeFormat {
+	length := f.Length
+	if length == 0 {
+		length = utils.DefaultCodeFormat.Length
+	}
+	return utils.CodeFormat{
+		Prefix:    prefix,
+		Length:    length,
+		Alphabet:  utils.ResolveAlphabet(f.Alphabet),
+		CheckChar: f.CheckChar,
+	}
 }
 
 type Db struct {
+	Dialect string // mysql(default) or sqlite
+	Path    string // sqlite file path or :memory:
+
 	Host string
 	Port string
 	Name string
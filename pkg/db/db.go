@@ -3,6 +3,7 @@ package db
 import (
 	"fmt"
 
+	"github.com/glebarez/sqlite"
 	"github.com/sirupsen/logrus"
 	"gorm.io/driver/mysql"
 	"gorm.io/gorm"
@@ -14,8 +15,26 @@ const (
 	maxIdleConn = 30
 )
 
+const (
+	DialectMysql  = "mysql"
+	DialectSqlite = "sqlite"
+
+	// SqliteMemory is the sqlite path of a private in-memory database
+	SqliteMemory = ":memory:"
+)
+
 type Config struct {
 	Host, Port, User, Pass, DBName, Mode string
+
+	// Dialect is one of DialectMysql(default) or DialectSqlite
+	Dialect string
+	// Path is the sqlite database file, or SqliteMemory
+	Path string
+	// MaxOpenConns caps the connection pool, 0 means 10 for mysql and 1 for
+	// sqlite. A sqlite file can take more, in WAL mode with transactions
+	// waiting up to busy_timeout for the write lock when they begin.
+	// SqliteMemory always keeps one.
+	MaxOpenConns int
 }
 
 // don't use soft delete
@@ -26,9 +45,6 @@ type BaseModel struct {
 }
 
 func NewDB(cfg *Config) (wrapDb *WrapDb, err error) {
-	dsn := fmt.Sprintf("%s:%s@tcp(%s:%s)/%s?charset=utf8&parseTime=True",
-		cfg.User, cfg.Pass, cfg.Host, cfg.Port, cfg.DBName)
-
 	logLevel := logger.Error
 	if cfg.Mode == "debug" {
 		logLevel = logger.Info
@@ -37,8 +53,17 @@ func NewDB(cfg *Config) (wrapDb *WrapDb, err error) {
 		logLevel = logger.Silent
 	}
 
-	db, err := gorm.Open(
-		mysql.New(
+	var dialector gorm.Dialector
+	openConn := maxOpenConn
+	if cfg.MaxOpenConns > 0 {
+		openConn = cfg.MaxOpenConns
+	}
+	switch cfg.Dialect {
+	case "", DialectMysql:
+		dsn := fmt.Sprintf("%s:%s@tcp(%s:%s)/%s?charset=utf8&parseTime=True",
+			cfg.User, cfg.Pass, cfg.Host, cfg.Port, cfg.DBName)
+
+		dialector = mysql.New(
 			mysql.Config{
 				DSN:                       dsn,   // data source name
 				DefaultStringSize:         256,   // default size for string fields
@@ -46,10 +71,31 @@ func NewDB(cfg *Config) (wrapDb *WrapDb, err error) {
 				DontSupportRenameIndex:    true,  // drop & create when rename index, rename index not supported before MySQL 5.7, MariaDB
 				DontSupportRenameColumn:   true,  // `change` when rename column, rename column not supported before MySQL 8, MariaDB
 				SkipInitializeWithVersion: false, // auto configure based on currently MySQL version
-			}),
-		&gorm.Config{
-			Logger: logger.Default.LogMode(logLevel)})
+			})
+	case DialectSqlite:
+		if len(cfg.Path) == 0 {
+			return nil, fmt.Errorf("sqlite path empty")
+		}
+		// sqlite allows a single writer, and every connection to :memory: opens
+		// a different database, so keep exactly one connection unless asked.
+		// With more a transaction takes the write lock when it begins, one
+		// reading before it writes would otherwise fail at once instead of
+		// waiting on busy_timeout when another one holds the lock.
+		dsn := cfg.Path + "?_pragma=busy_timeout(5000)&_pragma=foreign_keys(1)"
+		if cfg.MaxOpenConns == 0 || cfg.Path == SqliteMemory {
+			openConn = 1
+		} else {
+			dsn += "&_pragma=journal_mode(WAL)&_txlock=immediate"
+		}
+		dialector = sqlite.Open(dsn)
+	default:
+		return nil, fmt.Errorf("unsupported db dialect: %s", cfg.Dialect)
+	}
 
+	db, err := gorm.Open(dialector, &gorm.Config{
+		Logger:         logger.Default.LogMode(logLevel),
+		TranslateError: true, // unique index violations become gorm.ErrDuplicatedKey
+	})
 	if err != nil {
 		return nil, err
 	}
@@ -58,8 +104,8 @@ func NewDB(cfg *Config) (wrapDb *WrapDb, err error) {
 	if err != nil {
 		return nil, err
 	}
-	sqlDb.SetMaxIdleConns(maxIdleConn)
-	sqlDb.SetMaxOpenConns(maxOpenConn)
+	sqlDb.SetMaxIdleConns(min(maxIdleConn, openConn))
+	sqlDb.SetMaxOpenConns(openConn)
 	wrapDb = NewWrapDb(db)
 	logrus.Debug("new DB success")
 	return
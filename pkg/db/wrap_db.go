@@ -2,6 +2,7 @@ package db
 
 import (
 	"fmt"
+
 	"gorm.io/gorm"
 )
 
@@ -40,3 +41,8 @@ func (d *WrapDb) CommitTransaction() error {
 	}
 	return fmt.Errorf("is not transaction tx")
 }
+
+// Dialect returns the name of the underlying driver, DialectMysql or DialectSqlite
+func (d *WrapDb) Dialect() string {
+	return d.Dialector.Name()
+}
@@ -0,0 +1,17 @@
+package utils
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+)
+
+// HashPayload returns the hex sha256 of the json encoding of v
+func HashPayload(v any) string {
+	bts, err := json.Marshal(v)
+	if err != nil {
+		return ""
+	}
+	sum := sha256.Sum256(bts)
+	return "0x" + hex.EncodeToString(sum[:])
+}
@@ -2,31 +2,162 @@ package utils
 
 import (
 	"crypto/rand"
+	"errors"
+	"fmt"
 	"math/big"
+	"strings"
+	"unicode"
 )
 
 const (
-	DropletCount    = 5
-	CodesPerDroplet = 5
+	// MaxCodeLength is the size of the invite_code columns
+	MaxCodeLength = 10
+
+	AlphabetDefault = "ABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+	// AlphabetUnambiguous leaves out 0/O, 1/I/L, 5/S, 2/Z, 8/B and U/V which users mix up
+	AlphabetUnambiguous = "ACDEFGHJKMNPQRTVWXY34679"
+
+	defaultCodeLength = 8
 )
 
-const charset = "ABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
-const codeLength = 8
+var ErrMalformedCode = errors.New("malformed invite code")
 
-var charsetLen *big.Int
+// CodeFormat is the shape of generated codes: Prefix, Length random characters
+// of Alphabet and, with CheckChar, a Luhn mod N check character over them
+type CodeFormat struct {
+	Prefix    string
+	Length    int
+	Alphabet  string
+	CheckChar bool
+}
 
-func init() {
-	charsetLen = big.NewInt(int64(len(charset)))
+var DefaultCodeFormat = CodeFormat{Length: defaultCodeLength, Alphabet: AlphabetDefault}
+
+// ResolveAlphabet maps the preset names "default" and "unambiguous" to their
+// characters, an empty name is the default preset and anything else is used as is
+func ResolveAlphabet(name string) string {
+	switch name {
+	case "", "default":
+		return AlphabetDefault
+	case "unambiguous":
+		return AlphabetUnambiguous
+	}
+	return name
 }
 
-func GenerateInviteCode() (string, error) {
-	code := make([]byte, codeLength)
-	for i := range code {
-		num, err := rand.Int(rand.Reader, charsetLen)
+// Check reports a format that can't generate valid codes
+func (f CodeFormat) Check() error {
+	if f.Length <= 0 {
+		return fmt.Errorf("code length must be positive")
+	}
+	if len(f.Alphabet) < 2 {
+		return fmt.Errorf("code alphabet needs at least 2 characters")
+	}
+	seen := make(map[rune]bool, len(f.Alphabet))
+	for _, r := range f.Alphabet {
+		if r > unicode.MaxASCII || unicode.IsSpace(r) || seen[r] {
+			return fmt.Errorf("code alphabet must be distinct printable ascii characters")
+		}
+		seen[r] = true
+	}
+	// doubling only permutes an alphabet of even size, odd ones let typos through
+	if f.CheckChar && len(f.Alphabet)%2 != 0 {
+		return fmt.Errorf("code alphabet with check character needs an even number of characters")
+	}
+	for _, r := range f.Prefix {
+		if r > unicode.MaxASCII || unicode.IsSpace(r) {
+			return fmt.Errorf("code prefix must be printable ascii characters")
+		}
+	}
+	if f.CodeLength() > MaxCodeLength {
+		return fmt.Errorf("code length %d over max %d", f.CodeLength(), MaxCodeLength)
+	}
+	return nil
+}
+
+// CodeLength is the length of whole codes
+func (f CodeFormat) CodeLength() int {
+	length := len(f.Prefix) + f.Length
+	if f.CheckChar {
+		length++
+	}
+	return length
+}
+
+func (f CodeFormat) Generate() (string, error) {
+	alphabetLen := big.NewInt(int64(len(f.Alphabet)))
+	body := make([]byte, f.Length)
+	for i := range body {
+		num, err := rand.Int(rand.Reader, alphabetLen)
 		if err != nil {
 			return "", err
 		}
-		code[i] = charset[num.Int64()]
+		body[i] = f.Alphabet[num.Int64()]
+	}
+	code := f.Prefix + string(body)
+	if f.CheckChar {
+		code += string(luhnCheckChar(f.Alphabet, body))
 	}
-	return string(code), nil
+	return code, nil
+}
+
+// Normalize drops whitespace and, for alphabets without lower case letters, upper cases
+func (f CodeFormat) Normalize(code string) string {
+	code = strings.Map(func(r rune) rune {
+		if unicode.IsSpace(r) {
+			return -1
+		}
+		return r
+	}, code)
+	if strings.ToUpper(f.Alphabet+f.Prefix) == f.Alphabet+f.Prefix {
+		code = strings.ToUpper(code)
+	}
+	return code
+}
+
+// Validate checks the prefix, length, alphabet and check character of a normalized code
+func (f CodeFormat) Validate(code string) error {
+	if len(code) != f.CodeLength() || !strings.HasPrefix(code, f.Prefix) {
+		return ErrMalformedCode
+	}
+	body := []byte(code[len(f.Prefix):])
+	for _, c := range body {
+		if strings.IndexByte(f.Alphabet, c) < 0 {
+			return ErrMalformedCode
+		}
+	}
+	if f.CheckChar && !luhnValid(f.Alphabet, body) {
+		return ErrMalformedCode
+	}
+	return nil
+}
+
+// luhnCheckChar computes the Luhn mod N check character of payload
+func luhnCheckChar(alphabet string, payload []byte) byte {
+	n := len(alphabet)
+	factor := 2
+	sum := 0
+	for i := len(payload) - 1; i >= 0; i-- {
+		addend := factor * strings.IndexByte(alphabet, payload[i])
+		factor = 3 - factor
+		sum += addend/n + addend%n
+	}
+	return alphabet[(n-sum%n)%n]
+}
+
+// luhnValid checks a payload ending with its Luhn mod N check character
+func luhnValid(alphabet string, payload []byte) bool {
+	n := len(alphabet)
+	factor := 1
+	sum := 0
+	for i := len(payload) - 1; i >= 0; i-- {
+		addend := factor * strings.IndexByte(alphabet, payload[i])
+		factor = 3 - factor
+		sum += addend/n + addend%n
+	}
+	return sum%n == 0
+}
+
+func GenerateInviteCode() (string, error) {
+	return DefaultCodeFormat.Generate()
 }
@@ -0,0 +1,78 @@
+package utils_test
+
+import (
+	"invite-code-service/pkg/utils"
+	"testing"
+)
+
+func TestCodeFormatCheckChar(t *testing.T) {
+	format := utils.CodeFormat{Prefix: "R", Length: 7, Alphabet: utils.AlphabetUnambiguous, CheckChar: true}
+	if err := format.Check(); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 100; i++ {
+		code, err := format.Generate()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(code) != format.CodeLength() {
+			t.Fatalf("code %s length %d, expect %d", code, len(code), format.CodeLength())
+		}
+		if err := format.Validate(code); err != nil {
+			t.Fatalf("generated code %s invalid: %s", code, err)
+		}
+
+		// every single character substitution after the prefix is caught
+		for pos := len(format.Prefix); pos < len(code); pos++ {
+			for j := 0; j < len(format.Alphabet); j++ {
+				if format.Alphabet[j] == code[pos] {
+					continue
+				}
+				typo := code[:pos] + string(format.Alphabet[j]) + code[pos+1:]
+				if format.Validate(typo) == nil {
+					t.Fatalf("typo %s of %s passed validation", typo, code)
+				}
+			}
+		}
+	}
+}
+
+func TestCodeFormatNormalize(t *testing.T) {
+	format := utils.CodeFormat{Prefix: "D", Length: 4, Alphabet: utils.AlphabetDefault}
+	if got := format.Normalize(" d ab\t1c\n"); got != "DAB1C" {
+		t.Fatalf("normalize got %q", got)
+	}
+	if err := format.Validate("DAB1C"); err != nil {
+		t.Fatal(err)
+	}
+	for _, code := range []string{"DAB1", "XAB1C", "DAB1C2", "DAB-C"} {
+		if err := format.Validate(code); err != utils.ErrMalformedCode {
+			t.Fatalf("code %s: expect ErrMalformedCode, got %v", code, err)
+		}
+	}
+
+	// mixed case alphabets are kept as typed
+	lower := utils.CodeFormat{Length: 4, Alphabet: "abcdEFGH"}
+	if got := lower.Normalize("abEF"); got != "abEF" {
+		t.Fatalf("normalize got %q", got)
+	}
+}
+
+func TestCodeFormatCheck(t *testing.T) {
+	for _, format := range []utils.CodeFormat{
+		{Length: 0, Alphabet: utils.AlphabetDefault},
+		{Length: 8, Alphabet: "A"},
+		{Length: 8, Alphabet: "AAB"},
+		{Length: 8, Alphabet: "A B"},
+		{Length: 8, Alphabet: "ABC", CheckChar: true},
+		{Prefix: "TASK", Length: 7, Alphabet: utils.AlphabetDefault},
+		{Prefix: "T", Length: 9, Alphabet: utils.AlphabetDefault, CheckChar: true},
+	} {
+		if err := format.Check(); err == nil {
+			t.Fatalf("format %+v passed check", format)
+		}
+	}
+	if err := utils.DefaultCodeFormat.Check(); err != nil {
+		t.Fatal(err)
+	}
+}
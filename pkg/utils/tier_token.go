@@ -0,0 +1,57 @@
+package utils
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+var (
+	ErrTierTokenInvalid = errors.New("invalid tier token")
+	ErrTierTokenExpired = errors.New("tier token expired")
+)
+
+// SignTierToken returns a token of the form tier.expiresAt.signature selecting
+// tier until expiresAt in unix seconds, 0 never expires. tier must not contain '.'.
+func SignTierToken(secret, tier string, expiresAt uint64) string {
+	payload := fmt.Sprintf("%s.%d", tier, expiresAt)
+	return payload + "." + tierTokenSig(secret, payload)
+}
+
+// VerifyTierToken returns the tier of a token signed with secret and not
+// expired at now
+func VerifyTierToken(secret, token string, now uint64) (string, error) {
+	if len(secret) == 0 {
+		return "", ErrTierTokenInvalid
+	}
+	i := strings.LastIndexByte(token, '.')
+	if i < 0 {
+		return "", ErrTierTokenInvalid
+	}
+	payload, sig := token[:i], token[i+1:]
+	if !hmac.Equal([]byte(sig), []byte(tierTokenSig(secret, payload))) {
+		return "", ErrTierTokenInvalid
+	}
+	tier, expires, ok := strings.Cut(payload, ".")
+	if !ok {
+		return "", ErrTierTokenInvalid
+	}
+	expiresAt, err := strconv.ParseUint(expires, 10, 64)
+	if err != nil {
+		return "", ErrTierTokenInvalid
+	}
+	if expiresAt != 0 && expiresAt <= now {
+		return "", ErrTierTokenExpired
+	}
+	return tier, nil
+}
+
+func tierTokenSig(secret, payload string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
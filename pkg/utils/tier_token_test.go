@@ -0,0 +1,38 @@
+package utils_test
+
+import (
+	"errors"
+	"invite-code-service/pkg/utils"
+	"testing"
+)
+
+func TestTierToken(t *testing.T) {
+	token := utils.SignTierToken("secret", "sp", 200)
+	if tier, err := utils.VerifyTierToken("secret", token, 100); err != nil || tier != "sp" {
+		t.Fatalf("expect tier sp, got %s %v", tier, err)
+	}
+	if _, err := utils.VerifyTierToken("secret", token, 200); !errors.Is(err, utils.ErrTierTokenExpired) {
+		t.Fatalf("expect ErrTierTokenExpired, got %v", err)
+	}
+	if tier, err := utils.VerifyTierToken("secret", utils.SignTierToken("secret", "vip", 0), 1<<40); err != nil || tier != "vip" {
+		t.Fatalf("expect a token without expiry, got %s %v", tier, err)
+	}
+
+	for _, bad := range []string{
+		"",
+		"sp",
+		"sp.200",
+		"vip" + token[2:],
+		token[:len(token)-1],
+	} {
+		if _, err := utils.VerifyTierToken("secret", bad, 100); !errors.Is(err, utils.ErrTierTokenInvalid) {
+			t.Fatalf("expect %q invalid, got %v", bad, err)
+		}
+	}
+	if _, err := utils.VerifyTierToken("other", token, 100); !errors.Is(err, utils.ErrTierTokenInvalid) {
+		t.Fatalf("expect a token of another secret invalid, got %v", err)
+	}
+	if _, err := utils.VerifyTierToken("", utils.SignTierToken("", "sp", 0), 100); !errors.Is(err, utils.ErrTierTokenInvalid) {
+		t.Fatalf("expect tokens refused without a secret, got %v", err)
+	}
+}
@@ -0,0 +1,31 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// PostDiscordWebhook posts content as a message through a discord webhook url
+func PostDiscordWebhook(url, content string) error {
+	client := &http.Client{Timeout: 10 * time.Second}
+
+	body, err := json.Marshal(map[string]string{"content": content})
+	if err != nil {
+		return err
+	}
+
+	resp, err := client.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	// discord answers 204 without ?wait=true
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNoContent {
+		return fmt.Errorf("status code: %d", resp.StatusCode)
+	}
+	return nil
+}
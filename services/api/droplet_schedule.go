@@ -0,0 +1,138 @@
+package api
+
+import (
+	"fmt"
+	"invite-code-service/dao"
+	"invite-code-service/pkg/config"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+const defaultDropletScheduleInterval = 10 * time.Second
+
+// checkDropletSchedule reports scheduled rounds out of order
+func checkDropletSchedule(campaign string, schedule config.DropletSchedule) error {
+	for i, r := range schedule.Rounds {
+		if r.EndTime != 0 && r.EndTime <= r.StartTime {
+			return fmt.Errorf("campaign %s droplet round %d: end time not after start time", campaign, r.Round)
+		}
+		if i == 0 {
+			continue
+		}
+		prev := schedule.Rounds[i-1]
+		if r.Round <= prev.Round || r.StartTime < prev.StartTime {
+			return fmt.Errorf("campaign %s droplet round %d: not after round %d", campaign, r.Round, prev.Round)
+		}
+	}
+	return nil
+}
+
+// scheduledWaterCodes is the water pool needed by every round of a schedule
+func scheduledWaterCodes(schedule config.DropletSchedule, shape config.DropletShape) uint64 {
+	var count uint64
+	for _, r := range schedule.Rounds {
+		count += uint64(dao.DropletShape(r.DropletShape.Or(shape)).OrDefault().Codes())
+	}
+	return count
+}
+
+func (svr *Service) hasDropletSchedule() bool {
+	for _, campaign := range svr.campaigns {
+		if len(svr.cfg.CampaignDropletSchedule(campaign.Name).Rounds) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func (svr *Service) dropletScheduleHandler() {
+	interval := svr.cfg.DropletScheduleInterval
+	if interval == 0 {
+		interval = defaultDropletScheduleInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		for _, campaign := range svr.campaigns {
+			schedule := svr.cfg.CampaignDropletSchedule(campaign.Name)
+			if len(schedule.Rounds) == 0 {
+				continue
+			}
+			err := svr.scheduleDroplets(campaign, schedule, uint64(time.Now().Unix()))
+			if err != nil {
+				logrus.Errorf("scheduleDroplets of campaign %s error: %s", campaign.Name, err.Error())
+			}
+		}
+
+		select {
+		case <-svr.stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// scheduleDroplets closes the open round of a campaign at its end time and
+// opens the next scheduled round at its start time, or earlier with AutoAdvance
+// once the open round has no code left
+func (svr *Service) scheduleDroplets(campaign *dao.Campaign, schedule config.DropletSchedule, now uint64) error {
+	rounds, err := svr.store.GetDropletRounds(campaign.ID)
+	if err != nil {
+		return err
+	}
+	// rounds open in order, so only the last one can be open
+	last := -1
+	var open *dao.DropletRound
+	if len(rounds) > 0 {
+		last = int(rounds[len(rounds)-1].Round)
+		if rounds[len(rounds)-1].IsOpen() {
+			open = rounds[len(rounds)-1]
+		}
+	}
+
+	advance := false
+	if open != nil {
+		if scheduled := schedule.Find(open.Round); scheduled != nil && scheduled.EndTime != 0 && scheduled.EndTime <= now {
+			if _, err := svr.store.CloseDropletRound(campaign.ID); err != nil {
+				return err
+			}
+			logrus.Infof("closed droplet round %d of campaign %s", open.Round, campaign.Name)
+		} else if schedule.AutoAdvance {
+			advance, err = svr.dropletsGone(campaign.ID)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	next := schedule.Next(last, now)
+	if next == nil || (next.StartTime > now && !advance) {
+		return nil
+	}
+	shape := next.DropletShape.Or(svr.cfg.CampaignDropletShape(campaign.Name))
+	if _, err := svr.store.OpenDropletRound(campaign.ID, next.Round, dao.DropletShape(shape)); err != nil {
+		return fmt.Errorf("open droplet round %d: %w", next.Round, err)
+	}
+	if advance && next.StartTime > now {
+		logrus.Infof("opened droplet round %d of campaign %s early, round %d has no code left", next.Round, campaign.Name, last)
+	} else {
+		logrus.Infof("opened droplet round %d of campaign %s", next.Round, campaign.Name)
+	}
+	return nil
+}
+
+// dropletsGone tells if every code of the open round is used or expired
+func (svr *Service) dropletsGone(campaignId int64) (bool, error) {
+	_, codes, err := svr.store.GetLatestDropletCodesWithStatus(campaignId)
+	if err != nil {
+		return false, err
+	}
+	for _, c := range codes {
+		if !c.Used && !c.Expired {
+			return false, nil
+		}
+	}
+	return len(codes) > 0, nil
+}
@@ -0,0 +1,133 @@
+package api
+
+import (
+	"invite-code-service/dao"
+	"invite-code-service/pkg/config"
+	"testing"
+)
+
+func TestScheduleDroplets(t *testing.T) {
+	store := dao.NewMemStore()
+	svr, err := NewService(&config.ConfigApi{DropletReveal: config.DropletReveal{DrawSecret: "seed"}}, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	campaign, err := store.GetCampaign(dao.DefaultCampaignName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.GenerateInviteCodes(dao.GenerateSpec{CodeType: dao.WaterInviteCode, Count: 50}, dao.SystemEventMeta); err != nil {
+		t.Fatal(err)
+	}
+	schedule := config.DropletSchedule{Rounds: []config.ScheduledRound{
+		{Round: 0, StartTime: 100, EndTime: 200, DropletShape: config.DropletShape{Droplets: 2}},
+		{Round: 1, StartTime: 300},
+		{Round: 2, StartTime: 400},
+	}}
+
+	openRound := func() int {
+		round, err := store.GetOpenDropletRound(campaign.ID)
+		if err != nil {
+			return -1
+		}
+		return int(round.Round)
+	}
+	for _, step := range []struct {
+		now  uint64
+		open int
+	}{{50, -1}, {100, 0}, {150, 0}, {200, -1}, {350, 1}, {360, 1}} {
+		if err := svr.scheduleDroplets(campaign, schedule, step.now); err != nil {
+			t.Fatal(err)
+		}
+		if open := openRound(); open != step.open {
+			t.Fatalf("at %d: expect round %d open, got %d", step.now, step.open, open)
+		}
+	}
+
+	// once every code of round 1 is bound round 2 opens early
+	schedule.AutoAdvance = true
+	_, droplets, err := store.GetLatestDropletCodesWithStatus(campaign.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, d := range droplets {
+		inviteCode, err := store.GetInviteCode(d.InviteCode)
+		if err != nil {
+			t.Fatal(err)
+		}
+		address := string(rune('a' + i))
+		inviteCode.UserAddress = &address
+		inviteCode.BindTime = 1
+		if err := store.CheckBondAndUpdateInviteCode(inviteCode, dao.EventBoundBind, dao.SystemEventMeta, nil); err != nil {
+			t.Fatal(err)
+		}
+		if err := svr.scheduleDroplets(campaign, schedule, 370); err != nil {
+			t.Fatal(err)
+		}
+		if i < len(droplets)-1 && openRound() != 1 {
+			t.Fatalf("expect round 1 open with codes left, got %d", openRound())
+		}
+	}
+	if open := openRound(); open != 2 {
+		t.Fatalf("expect round 2 open early, got %d", open)
+	}
+}
+
+func TestCheckDropletSchedule(t *testing.T) {
+	for _, rounds := range [][]config.ScheduledRound{
+		{{Round: 0, StartTime: 100, EndTime: 100}},
+		{{Round: 1, StartTime: 100}, {Round: 1, StartTime: 200}},
+		{{Round: 0, StartTime: 200}, {Round: 1, StartTime: 100}},
+	} {
+		if err := checkDropletSchedule("default", config.DropletSchedule{Rounds: rounds}); err == nil {
+			t.Fatalf("expect %+v rejected", rounds)
+		}
+	}
+	err := checkDropletSchedule("default", config.DropletSchedule{Rounds: []config.ScheduledRound{
+		{Round: 0, StartTime: 100, EndTime: 200}, {Round: 2, StartTime: 200},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestScheduledWaterCodes(t *testing.T) {
+	schedule := config.DropletSchedule{Rounds: []config.ScheduledRound{
+		{Round: 0},
+		{Round: 1, DropletShape: config.DropletShape{Droplets: 2}},
+		{Round: 2, DropletShape: config.DropletShape{Droplets: 1, CodesPerDroplet: 1}},
+	}}
+	// 5x3 + 2x3 + 1x1
+	if count := scheduledWaterCodes(schedule, config.DropletShape{CodesPerDroplet: 3}); count != 22 {
+		t.Fatalf("expect 22 water codes, got %d", count)
+	}
+}
+
+func TestPrepareCampaignShortWaterPool(t *testing.T) {
+	store := dao.NewMemStore()
+	cfg := &config.ConfigApi{DropletReveal: config.DropletReveal{DrawSecret: "seed"}}
+	svr, err := NewService(cfg, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	campaigns, err := svr.saveCampaigns()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// without auto generation an empty pool leaves the round for droplet-round
+	if err := svr.prepareCampaign(campaigns[0]); err != nil {
+		t.Fatalf("expect start on an empty pool, got %v", err)
+	}
+	if _, err := store.GetOpenDropletRound(campaigns[0].ID); err == nil {
+		t.Fatal("expect no round opened")
+	}
+
+	cfg.AutoGenerateCodes = true
+	if err := svr.prepareCampaign(campaigns[0]); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.GetOpenDropletRound(campaigns[0].ID); err != nil {
+		t.Fatalf("expect the round opened on the generated pool, got %v", err)
+	}
+}
@@ -0,0 +1,41 @@
+package api
+
+import (
+	"invite-code-service/api"
+	"invite-code-service/dao"
+	"invite-code-service/pkg/config"
+	"time"
+)
+
+// MaxGenCount bounds the codes generated in one run
+const MaxGenCount = 100000
+
+// NewGenerateSpec returns the spec of count new codes of codeType with the
+// configured format, validity and max uses
+func NewGenerateSpec(cfg *config.ConfigApi, campaignId int64, codeType uint8, count int64) dao.GenerateSpec {
+	validFrom, expiresAt := codeValidity(cfg, codeType).Window(time.Now())
+	spec := dao.GenerateSpec{
+		CampaignId: campaignId,
+		CodeType:   codeType,
+		Count:      count,
+		ValidFrom:  validFrom,
+		ExpiresAt:  expiresAt,
+		NewCode:    api.CodeFormat(cfg, codeType).Generate,
+	}
+	if codeType == dao.DirectInviteCode {
+		spec.MaxUses = cfg.DirectInviteCodeMaxUses
+	}
+	return spec
+}
+
+func codeValidity(cfg *config.ConfigApi, codeType uint8) config.Validity {
+	switch codeType {
+	case dao.TaskInviteCode:
+		return cfg.TaskCodeValidity
+	case dao.DirectInviteCode:
+		return cfg.DirectCodeValidity
+	case dao.WaterInviteCode:
+		return cfg.WaterCodeValidity
+	}
+	return config.Validity{}
+}
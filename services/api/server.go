@@ -1,36 +1,67 @@
 package api
 
 import (
+	"errors"
 	"fmt"
 	"invite-code-service/api"
 	"invite-code-service/dao"
 	"invite-code-service/pkg/config"
-	"invite-code-service/pkg/db"
 	"invite-code-service/pkg/utils"
 	"net/http"
 	"time"
 
 	"github.com/sirupsen/logrus"
-	"gorm.io/gorm"
 )
 
-const maxGenCount = 100000
-
 type Service struct {
 	cfg *config.ConfigApi
 
 	httpServer *http.Server
-	db         *db.WrapDb
+	store      dao.Store
+
+	// the configured campaigns, saved on start
+	campaigns []*dao.Campaign
+	// pools below their threshold by campaign/type, only used by the stock monitor
+	lowStock map[string]bool
+	stop     chan struct{}
 }
 
-func NewService(cfg *config.ConfigApi, dao *db.WrapDb) (*Service, error) {
-	if cfg.TaskInviteCodeCount > maxGenCount || cfg.DirectInviteCodeCount > maxGenCount {
-		return nil, fmt.Errorf("over max gen count: %d", maxGenCount)
+func NewService(cfg *config.ConfigApi, store dao.Store) (*Service, error) {
+	if cfg.TaskInviteCodeCount > MaxGenCount || cfg.DirectInviteCodeCount > MaxGenCount {
+		return nil, fmt.Errorf("over max gen count: %d", MaxGenCount)
+	}
+	if err := api.CheckCodeFormats(cfg); err != nil {
+		return nil, err
+	}
+	if err := api.CheckDropletReveal(cfg); err != nil {
+		return nil, err
+	}
+	names := map[string]bool{dao.DefaultCampaignName: true}
+	for _, campaign := range cfg.Campaigns {
+		if len(campaign.Name) == 0 || names[campaign.Name] {
+			return nil, fmt.Errorf("campaign name empty or duplicate: %q", campaign.Name)
+		}
+		names[campaign.Name] = true
+		if campaign.TaskInviteCodeCount > MaxGenCount || campaign.DirectInviteCodeCount > MaxGenCount {
+			return nil, fmt.Errorf("campaign %s over max gen count: %d", campaign.Name, MaxGenCount)
+		}
+		if err := checkDropletSchedule(campaign.Name, campaign.DropletSchedule); err != nil {
+			return nil, err
+		}
+	}
+	if err := checkDropletSchedule(dao.DefaultCampaignName, cfg.DropletSchedule); err != nil {
+		return nil, err
+	}
+
+	if err := checkStockMonitor(cfg.StockMonitor); err != nil {
+		return nil, err
 	}
 
 	s := &Service{
-		cfg: cfg,
-		db:  dao,
+		cfg:      cfg,
+		store:    store,
+		lowStock: make(map[string]bool),
+		stop:     make(chan struct{}),
 	}
 
 	handler := s.InitHandler()
@@ -46,7 +77,7 @@ func NewService(cfg *config.ConfigApi, dao *db.WrapDb) (*Service, error) {
 }
 
 func (svr *Service) InitHandler() http.Handler {
-	return api.InitRouters(svr.db, svr.cfg)
+	return api.InitRouters(svr.store, svr.cfg)
 }
 
 func (svr *Service) ApiServer() {
@@ -61,108 +92,149 @@ func (svr *Service) ApiServer() {
 }
 
 func (svr *Service) Start() error {
-	taskInviteCodeCount, err := dao.GetInviteCodeCount(svr.db, dao.TaskInviteCode)
+	campaigns, err := svr.saveCampaigns()
 	if err != nil {
 		return err
 	}
-	directInviteCodeCount, err := dao.GetInviteCodeCount(svr.db, dao.DirectInviteCode)
-	if err != nil {
-		return err
+	for _, campaign := range campaigns {
+		err := svr.prepareCampaign(campaign)
+		if err != nil {
+			return fmt.Errorf("campaign %s: %w", campaign.Name, err)
+		}
 	}
-	waterInviteCodeCount, err := dao.GetInviteCodeCount(svr.db, dao.WaterInviteCode)
-	if err != nil {
-		return err
+	svr.campaigns = campaigns
+
+	utils.SafeGoWithRestart(svr.ApiServer)
+	if svr.cfg.StockMonitor.Interval > 0 {
+		utils.SafeGoWithRestart(svr.stockMonitorHandler)
+	}
+	if svr.hasDropletSchedule() {
+		utils.SafeGoWithRestart(svr.dropletScheduleHandler)
 	}
+	return nil
+}
 
-	if taskInviteCodeCount < int64(svr.cfg.TaskInviteCodeCount) {
-		genCount := int64(svr.cfg.TaskInviteCodeCount) - taskInviteCodeCount
+// saveCampaigns writes the configured campaigns into the campaigns table
+func (svr *Service) saveCampaigns() ([]*dao.Campaign, error) {
+	campaigns := []*dao.Campaign{{
+		Name:                  dao.DefaultCampaignName,
+		TaskInviteCodeCount:   svr.cfg.TaskInviteCodeCount,
+		DirectInviteCodeCount: svr.cfg.DirectInviteCodeCount,
+		DropletRound:          svr.cfg.DropletRound,
+	}}
+	for _, c := range svr.cfg.Campaigns {
+		campaigns = append(campaigns, &dao.Campaign{
+			Name:                  c.Name,
+			ZealySubdomain:        c.ZealySubdomain,
+			StartTime:             c.StartTime,
+			EndTime:               c.EndTime,
+			TaskInviteCodeCount:   c.TaskInviteCodeCount,
+			DirectInviteCodeCount: c.DirectInviteCodeCount,
+			DropletRound:          c.DropletRound,
+		})
+	}
 
-		logrus.Infof("need generate %d task invite code", genCount)
-		err := svr.genInviteCode(genCount, dao.TaskInviteCode)
+	for _, campaign := range campaigns {
+		err := svr.store.SaveCampaign(campaign)
 		if err != nil {
-			return err
+			return nil, err
 		}
-		logrus.Infof("generate success")
 	}
+	return campaigns, nil
+}
 
-	if directInviteCodeCount < int64(svr.cfg.DirectInviteCodeCount) {
-		genCount := int64(svr.cfg.DirectInviteCodeCount) - directInviteCodeCount
-		logrus.Infof("need generate %d direct invite code", genCount)
-		err := svr.genInviteCode(genCount, dao.DirectInviteCode)
-		if err != nil {
-			return err
-		}
-		logrus.Infof("generate success")
+// prepareCampaign tops up the code pools of a campaign and assigns its droplet round
+func (svr *Service) prepareCampaign(campaign *dao.Campaign) error {
+	err := svr.topUp(campaign.ID, dao.TaskInviteCode, int64(campaign.TaskInviteCodeCount))
+	if err != nil {
+		return err
+	}
+	err = svr.topUp(campaign.ID, dao.DirectInviteCode, int64(campaign.DirectInviteCodeCount))
+	if err != nil {
+		return err
 	}
 
-	if svr.cfg.DropletRound > 0 {
-		var maxRound uint8
-		err = svr.db.Model(&dao.DropletCode{}).
-			Select("MAX(round)").
-			Scan(&maxRound).Error
-		if err != nil {
-			return fmt.Errorf("failed to get max round: %w", err)
-		}
+	// scheduled rounds are opened by the droplet scheduler
+	if schedule := svr.cfg.CampaignDropletSchedule(campaign.Name); len(schedule.Rounds) > 0 {
+		shape := svr.cfg.CampaignDropletShape(campaign.Name)
+		return svr.topUp(campaign.ID, dao.WaterInviteCode, int64(scheduledWaterCodes(schedule, shape)))
+	}
 
-		if svr.cfg.DropletRound > maxRound+1 {
-			return fmt.Errorf("exist max round: %d", maxRound)
+	// runtime rounds take over once opened, the configured round only opens the
+	// first round or the one after the last
+	rounds, err := svr.store.GetDropletRounds(campaign.ID)
+	if err != nil {
+		return fmt.Errorf("failed to get droplet rounds: %w", err)
+	}
+	if len(rounds) > 0 {
+		last := rounds[len(rounds)-1]
+		if campaign.DropletRound <= last.Round {
+			return nil
+		}
+		if campaign.DropletRound > last.Round+1 {
+			return fmt.Errorf("exist max round: %d", last.Round)
 		}
 	}
 
-	needWaterInviteCodeCount := uint64(svr.cfg.DropletRound+1) * utils.DropletCount * utils.CodesPerDroplet
-
-	if waterInviteCodeCount < int64(needWaterInviteCodeCount) {
-		genCount := int64(needWaterInviteCodeCount) - waterInviteCodeCount
+	shape := dao.DropletShape(svr.cfg.CampaignDropletShape(campaign.Name)).OrDefault()
+	needWaterInviteCodeCount := uint64(campaign.DropletRound+1) * uint64(shape.Codes())
+	err = svr.topUp(campaign.ID, dao.WaterInviteCode, int64(needWaterInviteCodeCount))
+	if err != nil {
+		return err
+	}
 
-		logrus.Infof("need generate %d water invite code", genCount)
-		err := svr.genInviteCode(genCount, dao.WaterInviteCode)
-		if err != nil {
-			return err
+	round, err := svr.store.OpenDropletRound(campaign.ID, campaign.DropletRound, shape)
+	if err != nil {
+		// without auto generation the pool is filled by hand, the round is
+		// opened with droplet-round once it is
+		if errors.Is(err, dao.ErrNotEnoughDropletCodes) && !svr.cfg.AutoGenerateCodes {
+			logrus.Warnf("droplet round %d of campaign %s not opened: %s, add water codes with gen-codes and open it with droplet-round",
+				campaign.DropletRound, campaign.Name, err)
+			return nil
 		}
-		logrus.Infof("generate success")
+		return fmt.Errorf("OpenDropletRound failed: %w", err)
 	}
+	logrus.Infof("opened droplet round %d of campaign %s", round.Round, campaign.Name)
+	return nil
+}
 
-	err = dao.GenerateDropletCodes(svr.db, svr.cfg.DropletRound)
+// topUp generates the codes of codeType missing to reach want when
+// AutoGenerateCodes is set, otherwise it only warns about them
+func (svr *Service) topUp(campaignId int64, codeType uint8, want int64) error {
+	count, err := svr.store.GetInviteCodeCount(campaignId, codeType)
 	if err != nil {
-		return fmt.Errorf("GenerateDropletCodes failed: %s", err.Error())
+		return err
+	}
+	if count >= want {
+		return nil
+	}
+	genCount := want - count
+	if !svr.cfg.AutoGenerateCodes {
+		logrus.Warnf("%d %s invite codes short of %d, add them with gen-codes", genCount, dao.CodeTypeName(codeType), want)
+		return nil
 	}
 
-	utils.SafeGoWithRestart(svr.ApiServer)
+	logrus.Infof("need generate %d %s invite code", genCount, dao.CodeTypeName(codeType))
+	err = svr.genInviteCode(campaignId, genCount, codeType)
+	if err != nil {
+		return err
+	}
+	logrus.Infof("generate success")
 	return nil
 }
 
-func (svr *Service) genInviteCode(genCount int64, codeType uint8) error {
-	for i := int64(0); i < genCount; i++ {
-		inviteCode, err := utils.GenerateInviteCode()
-		if err != nil {
-			return err
-		}
-
-		_, err = dao.GetInviteCode(svr.db, inviteCode)
-		if err != nil {
-			if err != gorm.ErrRecordNotFound {
-				return err
-			}
-			// pass
-		} else {
-			i--
-			continue
-		}
-
-		newInviteCode := dao.InviteCode{
-			InviteCode: inviteCode,
-			CodeType:   codeType,
-		}
-
-		err = dao.CreateInviteCode(svr.db, &newInviteCode)
-		if err != nil {
-			return err
-		}
+func (svr *Service) genInviteCode(campaignId int64, genCount int64, codeType uint8) error {
+	spec := NewGenerateSpec(svr.cfg, campaignId, codeType, genCount)
+	spec.Progress = func(done, total int64) {
+		logrus.Infof("generated %d/%d invite codes of type %d", done, total, codeType)
 	}
-	return nil
+
+	_, err := svr.store.GenerateInviteCodes(spec, dao.SystemEventMeta)
+	return err
 }
 
 func (svr *Service) Stop() {
+	close(svr.stop)
 	if svr.httpServer != nil {
 		err := svr.httpServer.Close()
 		if err != nil {
@@ -0,0 +1,137 @@
+package api
+
+import (
+	"expvar"
+	"fmt"
+	"invite-code-service/dao"
+	"invite-code-service/pkg/config"
+	"invite-code-service/pkg/utils"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// metrics of the watched pools, keyed by campaign/type
+var (
+	stockVar       = expvar.NewMap("invite_code_stock")
+	lowStockVar    = expvar.NewMap("invite_code_low_stock")
+	stockTopUpsVar = expvar.NewMap("invite_code_stock_top_ups")
+)
+
+type stockPool struct {
+	codeType uint8
+	limits   config.StockLimits
+}
+
+func (svr *Service) stockPools() []stockPool {
+	monitor := svr.cfg.StockMonitor
+	return []stockPool{
+		{dao.TaskInviteCode, monitor.Task},
+		{dao.DirectInviteCode, monitor.Direct},
+		{dao.WaterInviteCode, monitor.Water},
+	}
+}
+
+// checkStockMonitor reports limits that can't be applied
+func checkStockMonitor(monitor config.StockMonitor) error {
+	for name, limits := range map[string]config.StockLimits{"Task": monitor.Task, "Direct": monitor.Direct, "Water": monitor.Water} {
+		if limits.Ceiling == 0 {
+			continue
+		}
+		if limits.Ceiling <= limits.Threshold {
+			return fmt.Errorf("stock monitor %s: ceiling %d not above threshold %d", name, limits.Ceiling, limits.Threshold)
+		}
+		if limits.Ceiling > MaxGenCount {
+			return fmt.Errorf("stock monitor %s: ceiling over max gen count: %d", name, MaxGenCount)
+		}
+	}
+	return nil
+}
+
+func (svr *Service) stockMonitorHandler() {
+	ticker := time.NewTicker(svr.cfg.StockMonitor.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-svr.stop:
+			return
+		case <-ticker.C:
+			for _, campaign := range svr.campaigns {
+				err := svr.checkStock(campaign)
+				if err != nil {
+					logrus.Errorf("checkStock of campaign %s error: %s", campaign.Name, err.Error())
+				}
+			}
+		}
+	}
+}
+
+// checkStock warns about the watched pools of a campaign below their threshold,
+// once per dip on the discord webhook, and tops up those with a ceiling
+func (svr *Service) checkStock(campaign *dao.Campaign) error {
+	if campaign.EndTime != 0 && campaign.EndTime <= uint64(time.Now().Unix()) {
+		return nil
+	}
+
+	for _, pool := range svr.stockPools() {
+		if pool.limits.Threshold == 0 {
+			continue
+		}
+		key := fmt.Sprintf("%s/%s", campaign.Name, dao.CodeTypeName(pool.codeType))
+		stats, err := svr.store.GetInviteCodeTypeStats(campaign.ID, pool.codeType)
+		if err != nil {
+			return err
+		}
+		remain := uint64(stats.RemainCodes)
+		setVar(stockVar, key, int64(remain))
+
+		if remain >= pool.limits.Threshold {
+			if svr.lowStock[key] {
+				logrus.Infof("%s invite codes back to %d", key, remain)
+				delete(svr.lowStock, key)
+			}
+			setVar(lowStockVar, key, 0)
+			continue
+		}
+
+		setVar(lowStockVar, key, 1)
+		msg := fmt.Sprintf("%s invite codes low: %d remain, threshold %d", key, remain, pool.limits.Threshold)
+		if pool.limits.Ceiling > 0 {
+			msg += fmt.Sprintf(", generating %d", pool.limits.Ceiling-remain)
+		}
+		logrus.Warn(msg)
+		if !svr.lowStock[key] {
+			svr.lowStock[key] = true
+			svr.notifyStock(msg)
+		}
+
+		if pool.limits.Ceiling > 0 {
+			genCount := int64(pool.limits.Ceiling - remain)
+			err = svr.genInviteCode(campaign.ID, genCount, pool.codeType)
+			if err != nil {
+				return fmt.Errorf("top up %s: %w", key, err)
+			}
+			stockTopUpsVar.Add(key, genCount)
+			logrus.Infof("topped up %s invite codes to %d", key, pool.limits.Ceiling)
+		}
+	}
+	return nil
+}
+
+func (svr *Service) notifyStock(msg string) {
+	url := svr.cfg.StockMonitor.DiscordWebhookUrl
+	if len(url) == 0 {
+		return
+	}
+	err := utils.PostDiscordWebhook(url, msg)
+	if err != nil {
+		logrus.Errorf("PostDiscordWebhook error: %s", err.Error())
+	}
+}
+
+func setVar(m *expvar.Map, key string, value int64) {
+	v := new(expvar.Int)
+	v.Set(value)
+	m.Set(key, v)
+}
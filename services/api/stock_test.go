@@ -0,0 +1,80 @@
+package api
+
+import (
+	"encoding/json"
+	"invite-code-service/dao"
+	"invite-code-service/pkg/config"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+func TestCheckStock(t *testing.T) {
+	var mu sync.Mutex
+	var messages []string
+	webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct{ Content string }
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Error(err)
+		}
+		mu.Lock()
+		messages = append(messages, body.Content)
+		mu.Unlock()
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer webhook.Close()
+
+	cfg := &config.ConfigApi{StockMonitor: config.StockMonitor{
+		DiscordWebhookUrl: webhook.URL,
+		Task:              config.StockLimits{Threshold: 10, Ceiling: 30},
+		Direct:            config.StockLimits{Threshold: 5},
+	}, DropletReveal: config.DropletReveal{DrawSecret: "seed"}}
+	store := dao.NewMemStore()
+	svr, err := NewService(cfg, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	campaign := &dao.Campaign{Name: dao.DefaultCampaignName}
+	if err := store.SaveCampaign(campaign); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.GenerateInviteCodes(dao.GenerateSpec{CodeType: dao.TaskInviteCode, Count: 4}, dao.SystemEventMeta); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := svr.checkStock(campaign); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// the task pool is topped up once, the direct pool stays low and is only notified once
+	count, err := store.GetInviteCodeCount(campaign.ID, dao.TaskInviteCode)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 30 {
+		t.Fatalf("expect 30 task codes, got %d", count)
+	}
+	if len(messages) != 2 {
+		t.Fatalf("expect 2 messages, got %q", messages)
+	}
+	if stockTopUpsVar.Get("default/task").String() != "26" || lowStockVar.Get("default/task").String() != "0" {
+		t.Fatalf("unexpected vars: %s %s", stockTopUpsVar, lowStockVar)
+	}
+	if lowStockVar.Get("default/direct").String() != "1" {
+		t.Fatalf("unexpected vars: %s", lowStockVar)
+	}
+}
+
+func TestCheckStockMonitor(t *testing.T) {
+	err := checkStockMonitor(config.StockMonitor{Water: config.StockLimits{Threshold: 10, Ceiling: 10}})
+	if err == nil {
+		t.Fatal("expect ceiling not above threshold rejected")
+	}
+	err = checkStockMonitor(config.StockMonitor{Water: config.StockLimits{Threshold: 10, Ceiling: 20}})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
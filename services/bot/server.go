@@ -1,32 +1,52 @@
 package bot
 
 import (
+	"errors"
 	"fmt"
 	"invite-code-service/dao"
 	"invite-code-service/pkg/config"
-	"invite-code-service/pkg/db"
+	"invite-code-service/pkg/utils"
+	"net/http"
 	"regexp"
+	"time"
 
 	"github.com/bwmarrin/discordgo"
 	"github.com/sirupsen/logrus"
 	"gorm.io/gorm"
 )
 
+const (
+	defaultRoleSyncInterval = time.Minute
+	roleSyncBatch           = 100
+	// ticks an event failing with a transient discord error is retried before
+	// it is skipped
+	maxRoleSyncRetries = 10
+)
+
 type Service struct {
 	cfg *config.ConfigDiscordBot
 
-	db *db.WrapDb
+	store dao.Store
+	// the campaign whose users get the role
+	campaignId int64
 
 	discordClient *discordgo.Session
+
+	// id of the latest unbind/revoke event whose role was removed, saved as
+	// the roleSyncCursor event cursor
+	lastEventId int64
+	// transient failures of the event after lastEventId
+	retries int
+	stop    chan struct{}
 }
 
-func NewService(cfg *config.ConfigDiscordBot, dao *db.WrapDb) (*Service, error) {
+func NewService(cfg *config.ConfigDiscordBot, store dao.Store) (*Service, error) {
 	dg, err := discordgo.New("Bot " + cfg.DiscordBotToken)
 	if err != nil {
 		return nil, err
 	}
 
-	return &Service{cfg: cfg, db: dao, discordClient: dg}, nil
+	return &Service{cfg: cfg, store: store, discordClient: dg, stop: make(chan struct{})}, nil
 }
 
 func (svr *Service) Start() error {
@@ -34,13 +54,142 @@ func (svr *Service) Start() error {
 
 	svr.discordClient.Identify.Intents = discordgo.IntentsGuildMessages
 
-	return svr.discordClient.Open()
+	campaignName := svr.cfg.Campaign
+	if len(campaignName) == 0 {
+		campaignName = dao.DefaultCampaignName
+	}
+	campaign, err := svr.store.GetCampaign(campaignName)
+	if err != nil {
+		return fmt.Errorf("GetCampaign %s error: %w", campaignName, err)
+	}
+	svr.campaignId = campaign.ID
+
+	// resume after the last event synced, a first start replays every unbind,
+	// users bound again keep their role
+	lastEventId, err := svr.store.GetEventCursor(svr.roleSyncCursor())
+	if err != nil {
+		return err
+	}
+	svr.lastEventId = lastEventId
+
+	err = svr.discordClient.Open()
+	if err != nil {
+		return err
+	}
+
+	utils.SafeGoWithRestart(svr.roleSyncHandler)
+	return nil
 }
 
 func (svr *Service) Stop() {
+	close(svr.stop)
 	svr.discordClient.Close()
 }
 
+func (svr *Service) roleSyncHandler() {
+	interval := svr.cfg.RoleSyncInterval
+	if interval <= 0 {
+		interval = defaultRoleSyncInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-svr.stop:
+			return
+		case <-ticker.C:
+			err := svr.syncRoles()
+			if err != nil {
+				logrus.Errorf("syncRoles error: %s", err.Error())
+			}
+		}
+	}
+}
+
+// roleSyncCursor names the event cursor of the role sync, per guild, role and campaign
+func (svr *Service) roleSyncCursor() string {
+	return fmt.Sprintf("discord-role:%s:%s:%d", svr.cfg.DiscordGuidId, svr.cfg.DiscordRoleId, svr.campaignId)
+}
+
+// syncRoles removes the role of discord users whose code was unbound or revoked.
+// An event failing for good is skipped, one failing with a transient error
+// stops the sync until the next tick, up to maxRoleSyncRetries times.
+func (svr *Service) syncRoles() error {
+	synced := svr.lastEventId
+	defer func() {
+		if svr.lastEventId == synced {
+			return
+		}
+		if err := svr.store.SaveEventCursor(svr.roleSyncCursor(), svr.lastEventId); err != nil {
+			logrus.Errorf("SaveEventCursor error: %s", err.Error())
+		}
+	}()
+
+	for {
+		events, err := svr.store.GetInviteCodeEventsAfter(svr.lastEventId, []string{dao.EventUnbound, dao.EventRevoked}, roleSyncBatch)
+		if err != nil {
+			return err
+		}
+
+		for _, event := range events {
+			if len(event.DiscordId) > 0 && event.CampaignId == svr.campaignId {
+				err := svr.removeRole(event)
+				if err != nil && !isPermanentDiscordErr(err) {
+					svr.retries++
+					if svr.retries < maxRoleSyncRetries {
+						return err
+					}
+					logrus.Errorf("skip event %d after %d tries: %s", event.ID, svr.retries, err.Error())
+				} else if err != nil {
+					logrus.Warnf("skip event %d: %s", event.ID, err.Error())
+				}
+			}
+			svr.lastEventId = event.ID
+			svr.retries = 0
+		}
+
+		if len(events) < roleSyncBatch {
+			return nil
+		}
+	}
+}
+
+// removeRole removes the role of the discord user of an unbind or revoke
+// event, unless the user bound another code since
+func (svr *Service) removeRole(event *dao.InviteCodeEvent) error {
+	_, err := svr.store.GetInviteCodeByDiscordId(svr.campaignId, event.DiscordId)
+	if err == nil {
+		logrus.Infof("discord user %s bound again, keep role", event.DiscordId)
+		return nil
+	}
+	if err != gorm.ErrRecordNotFound {
+		return err
+	}
+	err = svr.discordClient.GuildMemberRoleRemove(svr.cfg.DiscordGuidId, event.DiscordId, svr.cfg.DiscordRoleId)
+	if err != nil {
+		return fmt.Errorf("GuildMemberRoleRemove %s error: %w", event.DiscordId, err)
+	}
+	logrus.Infof("removed role of discord user %s, code: %s", event.DiscordId, event.InviteCode)
+	return nil
+}
+
+// isPermanentDiscordErr reports discord errors retrying won't fix, e.g. a
+// member that left the guild or an unknown id
+func isPermanentDiscordErr(err error) bool {
+	var restErr *discordgo.RESTError
+	if !errors.As(err, &restErr) {
+		return false
+	}
+	if restErr.Message != nil {
+		switch restErr.Message.Code {
+		case discordgo.ErrCodeUnknownMember, discordgo.ErrCodeUnknownUser:
+			return true
+		}
+	}
+	return restErr.Response != nil && restErr.Response.StatusCode == http.StatusNotFound
+}
+
 func (svr *Service) claimRoleHandler(s *discordgo.Session, m *discordgo.MessageCreate) {
 	// Ignore all messages created by the bot itself
 	if m.Author.ID == s.State.User.ID {
@@ -61,7 +210,7 @@ func (svr *Service) claimRoleHandler(s *discordgo.Session, m *discordgo.MessageC
 		return
 	}
 
-	_, err := dao.GetInviteCodeByDiscordId(svr.db, m.Author.ID)
+	_, err := svr.store.GetInviteCodeByDiscordId(svr.campaignId, m.Author.ID)
 	if err != nil {
 		if err != gorm.ErrRecordNotFound {
 			logrus.Errorf("GetInviteCodeByDiscordId error: %s", err.Error())